@@ -0,0 +1,78 @@
+package feed
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+const AtomNS = "http://www.w3.org/2005/Atom"
+
+// Atom 1.0（RFC 4287）
+type AtomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []AtomLink  `xml:"link"`
+	Icon    string      `xml:"icon,omitempty"`
+	Entries []AtomEntry `xml:"entry"`
+}
+
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type AtomEntry struct {
+	Title     string     `xml:"title"`
+	ID        string     `xml:"id"`
+	Links     []AtomLink `xml:"link"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published,omitempty"`
+	Content   AtomText   `xml:"content"`
+}
+
+type AtomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// 将 RSS 订阅源转换为 Atom，feedURL 为订阅源自身的地址（同时作为 id）
+func NewAtomFeed(f RSSFeed, feedURL string) AtomFeed {
+	ch := f.Channel
+	updated := time.Now()
+	if t, err := time.Parse(time.RFC1123Z, ch.LastBuildDate); err == nil {
+		updated = t
+	}
+
+	af := AtomFeed{
+		Title:   ch.Title,
+		ID:      feedURL,
+		Updated: updated.Format(time.RFC3339),
+		Links:   []AtomLink{{Href: ch.Link, Rel: "alternate"}, {Href: feedURL, Rel: "self"}},
+		Entries: make([]AtomEntry, 0, len(ch.Items)),
+	}
+	// 不知道订阅源自身地址时（如 fetch 命令）用网站地址作为 ID
+	if feedURL == "" {
+		af.ID, af.Links = ch.Link, af.Links[:1]
+	}
+	if ch.Image != nil {
+		af.Icon = ch.Image.URL
+	}
+
+	for _, item := range ch.Items {
+		entry := AtomEntry{
+			Title:   item.Title,
+			ID:      item.GUID,
+			Links:   []AtomLink{{Href: item.Link, Rel: "alternate"}},
+			Updated: af.Updated,
+			Content: AtomText{Type: "html", Body: item.Description},
+		}
+		if t, err := time.ParseInLocation(PubDateLayout, item.PubDate, time.Local); err == nil {
+			entry.Published = t.Format(time.RFC3339)
+			entry.Updated = entry.Published
+		}
+		af.Entries = append(af.Entries, entry)
+	}
+	return af
+}
@@ -0,0 +1,111 @@
+// Package feed 定义 RSS 2.0、Atom 1.0 和 JSON Feed 1.1 的数据结构，以及它们之间的转换和编码。
+// 订阅源统一以 RSSFeed 表示，输出时再转换为其他格式
+package feed
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+)
+
+// RSS数据结构定义
+type RSSFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	MediaNS string   `xml:"xmlns:media,attr,omitempty"`
+	AtomNS  string   `xml:"xmlns:atom,attr,omitempty"`
+	Channel Channel  `xml:"channel"`
+}
+
+type Channel struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	Description string        `xml:"description"`
+	Image       *ChannelImage `xml:"image,omitempty"`
+	// 条目内容最后一次变化的时间，内容不变时刷新也不会更新
+	LastBuildDate string `xml:"lastBuildDate,omitempty"`
+	// WebSub 的 self 和 hub 链接，只在输出时添加
+	AtomLinks []AtomLink `xml:"atom:link,omitempty"`
+	Items     []Item     `xml:"item"`
+}
+
+// 频道图片，显示为订阅源的图标
+type ChannelImage struct {
+	URL   string `xml:"url"`
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+}
+
+type Item struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	PubDate     string `xml:"pubDate,omitempty"`
+	GUID        string `xml:"guid"`
+
+	Thumbnail *MediaThumbnail `xml:"media:thumbnail,omitempty"`
+
+	// 条目来自的网站，只在合并订阅源中输出
+	Source *ItemSource `xml:"source,omitempty"`
+}
+
+// RSS <source>：条目所属的订阅源
+type ItemSource struct {
+	URL  string `xml:"url,attr"`
+	Name string `xml:",chardata"`
+}
+
+// Media RSS 缩略图
+type MediaThumbnail struct {
+	URL string `xml:"url,attr"`
+}
+
+const MediaRSSNamespace = "http://search.yahoo.com/mrss/"
+
+// 条目发布时间的格式，按本地时区解析
+const PubDateLayout = "2006-01-02 15:04:05"
+
+// 订阅源的输出格式
+const (
+	FormatRSS  = "rss"
+	FormatAtom = "atom"
+	FormatJSON = "json"
+)
+
+// 各格式的 Content-Type
+var ContentTypes = map[string]string{
+	FormatRSS:  "application/rss+xml",
+	FormatAtom: "application/atom+xml",
+	FormatJSON: "application/feed+json",
+}
+
+// 按格式编码订阅源，feedURL 为订阅源自身的地址，hub 不为空时声明 WebSub hub。
+// RSS 不带 XML 声明，Atom 带 XML 声明
+func Encode(f RSSFeed, format, feedURL, hub string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case FormatAtom:
+		af := NewAtomFeed(f, feedURL)
+		if hub != "" {
+			af.Links = append(af.Links, AtomLink{Href: hub, Rel: "hub"})
+		}
+		buf.WriteString(xml.Header)
+		err = xml.NewEncoder(&buf).Encode(af)
+	case FormatJSON:
+		jf := NewJSONFeed(f, feedURL)
+		if hub != "" {
+			jf.Hubs = []JSONFeedHub{{Type: "WebSub", URL: hub}}
+		}
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		err = enc.Encode(jf)
+	default:
+		if hub != "" {
+			f.AtomNS = AtomNS
+			f.Channel.AtomLinks = []AtomLink{{Href: feedURL, Rel: "self"}, {Href: hub, Rel: "hub"}}
+		}
+		err = xml.NewEncoder(&buf).Encode(f)
+	}
+	return buf.Bytes(), err
+}
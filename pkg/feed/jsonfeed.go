@@ -0,0 +1,69 @@
+package feed
+
+import "time"
+
+// JSON Feed 1.1（https://jsonfeed.org/version/1.1）
+type JSONFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url,omitempty"`
+	FeedURL     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Icon        string         `json:"icon,omitempty"`
+	Hubs        []JSONFeedHub  `json:"hubs,omitempty"`
+	Items       []JSONFeedItem `json:"items"`
+}
+
+type JSONFeedHub struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type JSONFeedItem struct {
+	ID            string `json:"id"`
+	URL           string `json:"url,omitempty"`
+	Title         string `json:"title,omitempty"`
+	ContentHTML   string `json:"content_html"`
+	Image         string `json:"image,omitempty"`
+	DatePublished string `json:"date_published,omitempty"`
+}
+
+const JSONFeedVersion = "https://jsonfeed.org/version/1.1"
+
+// 将 RSS 订阅源转换为 JSON Feed，feedURL 为空时省略
+func NewJSONFeed(f RSSFeed, feedURL string) JSONFeed {
+	ch := f.Channel
+	jf := JSONFeed{
+		Version:     JSONFeedVersion,
+		Title:       ch.Title,
+		HomePageURL: ch.Link,
+		FeedURL:     feedURL,
+		Description: ch.Description,
+		Items:       make([]JSONFeedItem, 0, len(ch.Items)),
+	}
+	if ch.Image != nil {
+		jf.Icon = ch.Image.URL
+	}
+
+	for _, item := range ch.Items {
+		jf.Items = append(jf.Items, NewJSONFeedItem(item))
+	}
+	return jf
+}
+
+// 将 RSS 条目转换为 JSON Feed 条目
+func NewJSONFeedItem(item Item) JSONFeedItem {
+	ji := JSONFeedItem{
+		ID:          item.GUID,
+		URL:         item.Link,
+		Title:       item.Title,
+		ContentHTML: item.Description,
+	}
+	if item.Thumbnail != nil {
+		ji.Image = item.Thumbnail.URL
+	}
+	if t, err := time.ParseInLocation(PubDateLayout, item.PubDate, time.Local); err == nil {
+		ji.DatePublished = t.Format(time.RFC3339)
+	}
+	return ji
+}
@@ -0,0 +1,88 @@
+// Package hooks 定义扩展 rss-zhuaqu 的接口：条目后处理钩子、抓取中间件和 HTTP 中间件。
+// pkg/scraper 通过 WithItemHook、WithFetchMiddleware 选项使用它们；嵌入服务时通过 pkg/server 中
+// New 的同名选项和 WithHTTPMiddleware 注册，见 README“扩展接口”
+package hooks
+
+import (
+	"context"
+	"net/http"
+
+	"rss-zhuaqu/pkg/feed"
+)
+
+// 条目后处理钩子，在条目提取之后、写入订阅源之前调用。可以修改条目，
+// 返回 keep 为 false 时丢弃条目。返回错误时保留条目的原值并记录日志。
+// site 为网站名称，在 pkg/scraper 中为页面地址
+type ItemHook interface {
+	ProcessItem(ctx context.Context, site string, item *feed.Item) (keep bool, err error)
+}
+
+// 函数形式的 ItemHook
+type ItemHookFunc func(ctx context.Context, site string, item *feed.Item) (bool, error)
+
+func (f ItemHookFunc) ProcessItem(ctx context.Context, site string, item *feed.Item) (bool, error) {
+	return f(ctx, site, item)
+}
+
+// 抓取中间件，包装抓取网页使用的 Transport，可以添加请求头、认证、缓存、改写响应或记录指标
+type FetchMiddleware func(next http.RoundTripper) http.RoundTripper
+
+// 函数形式的 http.RoundTripper，便于编写 FetchMiddleware
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// HTTP 中间件，包装服务的所有路由，可以添加认证、改写请求或响应、记录指标
+type HTTPMiddleware func(next http.Handler) http.Handler
+
+// 依次应用抓取中间件，第一个在最外层；base 为 nil 时使用 http.DefaultTransport
+func ChainFetch(base http.RoundTripper, mws ...FetchMiddleware) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	for i := len(mws) - 1; i >= 0; i-- {
+		base = mws[i](base)
+	}
+	return base
+}
+
+// 依次应用 HTTP 中间件，第一个在最外层
+func ChainHTTP(h http.Handler, mws ...HTTPMiddleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// 依次执行条目钩子，返回保留的条目和第一个错误。钩子出错时条目保持该钩子执行前的值
+func ApplyItemHooks(ctx context.Context, site string, items []feed.Item, itemHooks []ItemHook) ([]feed.Item, error) {
+	if len(itemHooks) == 0 {
+		return items, nil
+	}
+	var firstErr error
+	kept := make([]feed.Item, 0, len(items))
+	for _, item := range items {
+		keep := true
+		for _, h := range itemHooks {
+			next := item
+			ok, err := h.ProcessItem(ctx, site, &next)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			if !ok {
+				keep = false
+				break
+			}
+			item = next
+		}
+		if keep {
+			kept = append(kept, item)
+		}
+	}
+	return kept, firstErr
+}
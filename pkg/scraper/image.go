@@ -0,0 +1,152 @@
+package scraper
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// 懒加载图片常用的属性，按优先级排列
+var lazyImageAttrs = []string{"data-src", "data-original", "data-lazy-src", "data-url"}
+
+// 提取图片地址，兼容懒加载属性与 srcset
+func ExtractImage(s *goquery.Selection, baseURL string) string {
+	img := s.First()
+	if !img.Is("img") {
+		if inner := img.Find("img").First(); inner.Length() > 0 {
+			img = inner
+		}
+	}
+
+	var src string
+	for _, attr := range lazyImageAttrs {
+		if v, ok := img.Attr(attr); ok && strings.TrimSpace(v) != "" {
+			src = v
+			break
+		}
+	}
+	if src == "" {
+		for _, attr := range []string{"data-srcset", "srcset"} {
+			if v, ok := img.Attr(attr); ok {
+				if src = pickSrcset(v); src != "" {
+					break
+				}
+			}
+		}
+	}
+	if src == "" {
+		src, _ = img.Attr("src")
+	}
+
+	src = strings.TrimSpace(src)
+	// 占位图通常是内联的 data URI，没有意义
+	if src == "" || strings.HasPrefix(src, "data:") {
+		return ""
+	}
+
+	return ResolveURL(baseURL, src)
+}
+
+// 从 srcset 中选出宽度最大的候选图片，没有宽度描述（w）时选像素密度（x）最大的。
+// 两种描述的数值不能比较，只在同一种描述之间选择
+func pickSrcset(srcset string) string {
+	var bestW, bestX string
+	var maxW, maxX float64
+	for _, c := range parseSrcset(srcset) {
+		kind, size := c.descriptor()
+		switch {
+		case kind == 'w' && (bestW == "" || size > maxW):
+			bestW, maxW = c.url, size
+		case kind == 'x' && (bestX == "" || size > maxX):
+			bestX, maxX = c.url, size
+		}
+	}
+	if bestW != "" {
+		return bestW
+	}
+	return bestX
+}
+
+type srcsetCandidate struct {
+	url         string
+	descriptors []string
+}
+
+// 按 HTML 规范切分 srcset：地址是一段不含空白的文本，地址末尾的逗号结束该候选，
+// 否则描述一直到括号外的下一个逗号。地址中间可以包含逗号
+func parseSrcset(srcset string) []srcsetCandidate {
+	var candidates []srcsetCandidate
+	isSpace := func(r byte) bool { return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' }
+	i := 0
+	for i < len(srcset) {
+		for i < len(srcset) && (isSpace(srcset[i]) || srcset[i] == ',') {
+			i++
+		}
+		start := i
+		for i < len(srcset) && !isSpace(srcset[i]) {
+			i++
+		}
+		if start == i {
+			break
+		}
+		c := srcsetCandidate{url: srcset[start:i]}
+		if trimmed := strings.TrimRight(c.url, ","); trimmed != c.url {
+			c.url = trimmed
+		} else {
+			start, depth := i, 0
+			for i < len(srcset) && (srcset[i] != ',' || depth > 0) {
+				switch srcset[i] {
+				case '(':
+					depth++
+				case ')':
+					depth--
+				}
+				i++
+			}
+			c.descriptors = strings.Fields(srcset[start:i])
+		}
+		if c.url != "" {
+			candidates = append(candidates, c)
+		}
+	}
+	return candidates
+}
+
+// 候选图片的描述类型（'w' 或 'x'）和数值，没有描述时为 1x，无法解析时类型为 0
+func (c srcsetCandidate) descriptor() (byte, float64) {
+	kind, size := byte('x'), 1.0
+	for _, d := range c.descriptors {
+		if len(d) < 2 {
+			return 0, 0
+		}
+		last := d[len(d)-1]
+		n, err := strconv.ParseFloat(d[:len(d)-1], 64)
+		if err != nil {
+			return 0, 0
+		}
+		switch last {
+		case 'w', 'x':
+			kind, size = last, n
+		case 'h':
+			// 高度只在有宽度时作为提示，不参与选择
+		default:
+			return 0, 0
+		}
+	}
+	return kind, size
+}
+
+// 将相对地址解析为绝对地址
+func ResolveURL(baseURL, ref string) string {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return ref
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
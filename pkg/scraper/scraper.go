@@ -0,0 +1,252 @@
+// Package scraper 按 CSS 选择器从网页中提取条目，生成 pkg/feed 的订阅源条目。
+// 是 rss-zhuaqu 的抓取引擎，可以嵌入其他 Go 程序使用：
+//
+//	s := scraper.New(scraper.WithUserAgent("my-bot/1.0"), scraper.WithMaxItems(50))
+//	items, err := s.Scrape(ctx, "https://example.com/news", scraper.Selectors{
+//		Item: ".post", Title: "h2 a", Link: "h2 a", Date: "time", DateFormat: "2006-01-02",
+//	})
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"rss-zhuaqu/pkg/feed"
+	"rss-zhuaqu/pkg/hooks"
+)
+
+// 条目的字段名
+const (
+	FieldTitle       = "title"
+	FieldLink        = "link"
+	FieldDescription = "description"
+	FieldDate        = "date"
+	FieldImage       = "image"
+)
+
+// 提取条目使用的选择器。Item 匹配条目节点，其他选择器在条目节点内查找，为空时对应字段为空
+type Selectors struct {
+	Item        string
+	Title       string
+	Link        string
+	Description string
+	Date        string
+	Image       string
+	// 日期的格式（Go 时间布局），为空或不匹配时条目没有发布时间
+	DateFormat string
+}
+
+// 从一个条目节点中提取出的原始字段
+type Fields struct {
+	Title       string
+	Link        string
+	Description string
+	Date        string
+	Image       string
+}
+
+// 选择器在一个条目节点中的匹配结果，用于调试选择器
+type FieldTrace struct {
+	Field    string
+	Selector string
+	// 匹配到的节点数
+	Matched int
+	// 选择器取到的原始值（文本或 href），以及处理后的字段值
+	Raw   string
+	Value string
+}
+
+// 从一个条目节点中提取字段，相对链接按 baseURL 解析。
+// trace 不为 nil 时记录每个选择器的匹配结果
+func ExtractNode(s *goquery.Selection, sels Selectors, baseURL string, trace *[]FieldTrace) Fields {
+	titleSel, linkSel, descSel, dateSel := s.Find(sels.Title), s.Find(sels.Link), s.Find(sels.Description), s.Find(sels.Date)
+
+	rawLink, _ := linkSel.Attr("href")
+	link := strings.TrimSpace(rawLink)
+	if link != "" && !strings.HasPrefix(link, "http") {
+		link = ResolveURL(baseURL, link)
+	}
+
+	f := Fields{
+		Title:       NormalizeText(titleSel.Text()),
+		Link:        link,
+		Description: NormalizeText(descSel.Text()),
+		Date:        strings.TrimSpace(dateSel.Text()),
+	}
+	var imageSel *goquery.Selection
+	if sels.Image != "" {
+		imageSel = s.Find(sels.Image)
+		f.Image = ExtractImage(imageSel, baseURL)
+	}
+
+	if trace != nil {
+		add := func(field, selector string, matched *goquery.Selection, raw, value string) {
+			*trace = append(*trace, FieldTrace{Field: field, Selector: selector, Matched: matched.Length(), Raw: raw, Value: value})
+		}
+		add(FieldTitle, sels.Title, titleSel, titleSel.Text(), f.Title)
+		add(FieldLink, sels.Link, linkSel, rawLink, f.Link)
+		add(FieldDescription, sels.Description, descSel, descSel.Text(), f.Description)
+		add(FieldDate, sels.Date, dateSel, dateSel.Text(), f.Date)
+		if imageSel != nil {
+			add(FieldImage, sels.Image, imageSel, "", f.Image)
+		}
+	}
+	return f
+}
+
+// 抓取页面时上游返回的错误状态码
+type StatusError struct {
+	URL    string
+	Status int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned HTTP %d", e.URL, e.Status)
+}
+
+// 按选择器抓取网页的抓取器，可以在多个 goroutine 中同时使用
+type Scraper struct {
+	client    *http.Client
+	userAgent string
+	maxItems  int
+	fetchMW   []hooks.FetchMiddleware
+	itemHooks []hooks.ItemHook
+}
+
+// 抓取器的选项
+type Option func(*Scraper)
+
+// 使用指定的 HTTP 客户端，默认为 http.DefaultClient
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Scraper) { s.client = c }
+}
+
+// 请求时发送的 User-Agent
+func WithUserAgent(ua string) Option {
+	return func(s *Scraper) { s.userAgent = ua }
+}
+
+// 每个页面最多提取的条目数，0 表示不限制，默认 500
+func WithMaxItems(n int) Option {
+	return func(s *Scraper) { s.maxItems = n }
+}
+
+// 包装抓取页面的请求，第一个中间件在最外层；与 WithHTTPClient 一起使用时包装该客户端的 Transport
+func WithFetchMiddleware(mws ...hooks.FetchMiddleware) Option {
+	return func(s *Scraper) { s.fetchMW = append(s.fetchMW, mws...) }
+}
+
+// Scrape 返回条目前依次执行的钩子
+func WithItemHook(hs ...hooks.ItemHook) Option {
+	return func(s *Scraper) { s.itemHooks = append(s.itemHooks, hs...) }
+}
+
+func New(opts ...Option) *Scraper {
+	s := &Scraper{client: http.DefaultClient, maxItems: 500}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if len(s.fetchMW) > 0 {
+		c := *s.client
+		c.Transport = hooks.ChainFetch(c.Transport, s.fetchMW...)
+		s.client = &c
+	}
+	return s
+}
+
+// 请求页面，状态码为 4xx、5xx 时关闭响应并返回 *StatusError。调用方负责关闭返回的响应
+func (s *Scraper) Get(ctx context.Context, pageURL string) (*http.Response, error) {
+	return s.do(ctx, http.MethodGet, pageURL)
+}
+
+// 以 HEAD 请求检查地址是否存在，错误处理同 Get
+func (s *Scraper) Head(ctx context.Context, pageURL string) (*http.Response, error) {
+	return s.do(ctx, http.MethodHead, pageURL)
+}
+
+func (s *Scraper) do(ctx context.Context, method, pageURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.userAgent != "" {
+		req.Header.Set("User-Agent", s.userAgent)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		return nil, &StatusError{URL: pageURL, Status: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+// 解析 Get 返回的响应，文档的 Url 为跳转后的地址
+func Parse(resp *http.Response) (*goquery.Document, error) {
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	doc.Url = resp.Request.URL
+	return doc, nil
+}
+
+// 抓取并解析页面，状态码为 4xx、5xx 时返回 *StatusError
+func (s *Scraper) Fetch(ctx context.Context, pageURL string) (*goquery.Document, error) {
+	resp, err := s.Get(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return Parse(resp)
+}
+
+// 从页面中提取条目节点的字段，最多提取 WithMaxItems 条
+func (s *Scraper) Extract(doc *goquery.Document, baseURL string, sels Selectors) []Fields {
+	matched := doc.Find(sels.Item)
+	if s.maxItems > 0 && matched.Length() > s.maxItems {
+		matched = matched.Slice(0, s.maxItems)
+	}
+	out := make([]Fields, 0, matched.Length())
+	matched.Each(func(_ int, n *goquery.Selection) {
+		out = append(out, ExtractNode(n, sels, baseURL, nil))
+	})
+	return out
+}
+
+// 抓取页面并转换为订阅源条目。没有标题和链接的条目被跳过，
+// 日期按 DateFormat 解析，没有日期的条目发布时间为空。
+// 条目钩子出错时仍返回条目，同时返回该错误
+func (s *Scraper) Scrape(ctx context.Context, pageURL string, sels Selectors) ([]feed.Item, error) {
+	doc, err := s.Fetch(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []feed.Item
+	for _, f := range s.Extract(doc, pageURL, sels) {
+		if f.Title == "" || f.Link == "" {
+			continue
+		}
+		item := feed.Item{Title: f.Title, Link: f.Link, Description: f.Description, GUID: f.Link}
+		if f.Date != "" && sels.DateFormat != "" {
+			if t, err := time.Parse(sels.DateFormat, f.Date); err == nil {
+				item.PubDate = t.Format(feed.PubDateLayout)
+			}
+		}
+		if f.Image != "" {
+			item.Description = fmt.Sprintf(`<p><img src="%s" /></p>%s`, html.EscapeString(f.Image), html.EscapeString(f.Description))
+			item.Thumbnail = &feed.MediaThumbnail{URL: f.Image}
+		}
+		items = append(items, item)
+	}
+	return hooks.ApplyItemHooks(ctx, pageURL, items, s.itemHooks)
+}
@@ -0,0 +1,48 @@
+package scraper
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// 零宽字符，常被网站用于排版或防复制
+var zeroWidthReplacer = strings.NewReplacer(
+	"\u200b", "",
+	"\u200c", "",
+	"\u200d", "",
+	"\u2060", "",
+	"\ufeff", "",
+)
+
+// 规范化提取出的文本：去除零宽字符、合并空白并统一为 Unicode NFC。
+// s 应为已解码的文本（如 goquery 的 Text()），其中的实体不再解码，
+// 页面上显示的 &lt; 等保持原样；输入为 HTML 源码时先用 html.UnescapeString 解码
+func NormalizeText(s string) string {
+	s = zeroWidthReplacer.Replace(s)
+	s = strings.Join(strings.FieldsFunc(s, unicode.IsSpace), " ")
+	return norm.NFC.String(s)
+}
+
+// 按字符数截断文本，尽量在单词边界处断开并追加省略号
+func TruncateText(s string, max int) string {
+	runes := []rune(s)
+	if max <= 0 || len(runes) <= max {
+		return s
+	}
+
+	cut := runes[:max]
+	// 英文等以空格分词的文本回退到最近的空格，避免截断半个单词；
+	// 中文等没有空格的文本直接按字符截断
+	for i := len(cut) - 1; i > max/2; i-- {
+		if unicode.IsSpace(cut[i]) {
+			cut = cut[:i]
+			break
+		}
+	}
+
+	return strings.TrimRightFunc(string(cut), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) + "…"
+}
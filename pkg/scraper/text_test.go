@@ -0,0 +1,50 @@
+package scraper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name, html, want string
+	}{
+		{"whitespace", "<p>  Hello \n\t world  </p>", "Hello world"},
+		{"zero width", "<p>Go\u200b 1.22\ufeff</p>", "Go 1.22"},
+		{"nfc", "<p>Cafe\u0301</p>", "Caf\u00e9"},
+		{"entity", "<p>&lt;b&gt; &amp; co</p>", "<b> & co"},
+		// 页面上显示的是 &lt;b&gt;，不能再解码一次
+		{"literal entity", "<p>&amp;lt;b&amp;gt;</p>", "&lt;b&gt;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := NormalizeText(doc.Find("p").Text()); got != tt.want {
+				t.Errorf("NormalizeText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"hello brave new world", 12, "hello brave…"},
+		{"中文没有空格的文本", 4, "中文没有…"},
+		{"anything", 0, "anything"},
+	}
+	for _, tt := range tests {
+		if got := TruncateText(tt.s, tt.max); got != tt.want {
+			t.Errorf("TruncateText(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// 请求 ID 的请求头和响应头
+const requestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// 请求的 ID，没有时返回空字符串
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// 为每个请求分配 ID（沿用上游代理传入的 X-Request-ID）并写入响应头，
+// 请求结束后记录访问日志。探针和指标接口只在 debug 级别记录
+func (srv *Server) accessLogHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = randomID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if !srv.cfg.AccessLog {
+			return
+		}
+
+		level := slog.LevelInfo
+		if untracedRoutes[r.URL.Path] {
+			level = slog.LevelDebug
+		}
+		slog.Log(r.Context(), level, "Request",
+			"request_id", id,
+			"method", r.Method,
+			"path", redactTenantPath(r.URL.Path),
+			"site", r.URL.Query().Get("site"),
+			"status", rec.code,
+			"bytes", rec.bytes,
+			"duration", time.Since(start),
+			"remote", r.RemoteAddr,
+			"client", srv.clientAddr(r),
+		)
+	})
+}
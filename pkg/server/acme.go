@@ -0,0 +1,42 @@
+package server
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+func (srv *Server) acmeEnabled() bool {
+	return len(srv.acmeDomains) > 0
+}
+
+// 自动申请和续期证书。证书缓存在 acmeCacheDir 中，重启后不会重复申请
+func (srv *Server) newACMEManager() *autocert.Manager {
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(srv.acmeDomains...),
+		Cache:      autocert.DirCache(srv.cfg.ACMECacheDir),
+		Email:      srv.cfg.ACMEEmail,
+	}
+}
+
+// ACME 模式的 TLS 配置，同时支持 TLS-ALPN-01 验证
+func newACMETLSConfig(m *autocert.Manager) *tls.Config {
+	cfg := m.TLSConfig()
+	cfg.MinVersion = tls.VersionTLS12
+	return cfg
+}
+
+// 在 addr 上启动 HTTP 服务，响应 HTTP-01 验证，其他请求重定向到 HTTPS
+func (srv *Server) startACMEHTTP(m *autocert.Manager, addr string) *http.Server {
+	hs := srv.tuneServer(&http.Server{Addr: addr, Handler: m.HTTPHandler(nil)}, false)
+	go func() {
+		slog.Info("Serving ACME challenges and redirecting HTTP to HTTPS", "addr", addr)
+		if err := hs.ListenAndServe(); err != http.ErrServerClosed {
+			fatal("ACME HTTP server failed", "error", err)
+		}
+	}()
+	return hs
+}
@@ -0,0 +1,385 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"rss-zhuaqu/pkg/storage"
+)
+
+// 是否为管理页面或管理接口的路径
+func isAdminPath(path string) bool {
+	return path == "/admin" || strings.HasPrefix(path, "/admin/")
+}
+
+// 校验管理接口的访问令牌，支持 Authorization: Bearer <token>，
+// 以及浏览器中使用的 Basic 认证（密码为令牌，用户名任意）
+func (srv *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if srv.cfg.AdminToken == "" {
+			writeError(w, r, http.StatusForbidden, ErrCodeForbidden, "Admin API is disabled, start the server with -admin-token")
+			return
+		}
+
+		if !srv.adminAuthorized(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			writeError(w, r, http.StatusUnauthorized, ErrCodeAdminUnauthorized, "Unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// 请求是否带有正确的管理令牌
+func (srv *Server) adminAuthorized(r *http.Request) bool {
+	if srv.cfg.AdminToken == "" {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if _, password, ok := r.BasicAuth(); ok {
+		token = password
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(srv.cfg.AdminToken)) == 1
+}
+
+// 导出（GET）或导入（POST）缓存和历史条目快照
+func (srv *Server) snapshotHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="snapshot.json"`)
+		if err := srv.exportSnapshot(w); err != nil {
+			slog.Error("Failed to export snapshot", "error", err)
+		}
+	case http.MethodPost:
+		feeds, items, err := srv.importSnapshot(r.Body)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("Failed to import snapshot: %v", err))
+			return
+		}
+		slog.Info("Imported snapshot", "feeds", feeds, "items", items)
+		writeJSON(w, http.StatusOK, map[string]int{"feeds": feeds, "items": items})
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// 网站的缓存统计
+type siteCacheStats struct {
+	Site         string     `json:"site"`
+	Cached       bool       `json:"cached"`
+	FeedItems    int        `json:"feedItems"`
+	HistoryItems int        `json:"historyItems"`
+	CacheBytes   int64      `json:"cacheBytes"`
+	MaxItems     int        `json:"maxItems"`
+	Skipped      SkipStats  `json:"skipped,omitempty"`
+	UpdatedAt    *time.Time `json:"updatedAt,omitempty"`
+	ExpireAt     *time.Time `json:"expireAt,omitempty"`
+}
+
+// GET /admin/cache 返回缓存统计，DELETE 清除缓存
+func (srv *Server) cacheHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		srv.cacheStatsHandler(w, r)
+	case http.MethodDelete:
+		srv.purgeCacheHandler(w, r)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// 每个网站的条目数和缓存大小，memory/file 存储还返回缓存占用的总内存
+func (srv *Server) cacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	sites, err := srv.snapshotSites()
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeStorage, fmt.Sprintf("Failed to list sites: %v", err))
+		return
+	}
+
+	stats := make([]siteCacheStats, 0, len(sites))
+	var totalBytes int64
+	for _, site := range sites {
+		config, _ := srv.getSiteConfig(site)
+		st := siteCacheStats{Site: site, MaxItems: siteMaxItems(config)}
+
+		fc, ok, err := srv.store.Get(site)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeStorage, fmt.Sprintf("Failed to read cache for %s: %v", site, err))
+			return
+		}
+		if ok {
+			st.Cached = true
+			st.FeedItems = len(fc.Feed.Channel.Items)
+			st.CacheBytes = storage.FeedSize(fc)
+			st.Skipped = fc.Skipped
+			st.UpdatedAt, st.ExpireAt = &fc.UpdatedAt, &fc.ExpireAt
+			totalBytes += st.CacheBytes
+		}
+
+		recs, err := srv.store.QueryItems(site, ItemQuery{})
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeStorage, fmt.Sprintf("Failed to read items for %s: %v", site, err))
+			return
+		}
+		st.HistoryItems = len(recs)
+		stats = append(stats, st)
+	}
+
+	resp := map[string]interface{}{"sites": stats, "cacheBytes": totalBytes}
+	if m, ok := srv.store.(interface{ Bytes() int64 }); ok {
+		resp["memoryBytes"] = m.Bytes()
+		resp["memoryLimitBytes"] = srv.cacheMaxBytes
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// 清除缓存：DELETE /admin/cache?site=x，all=1 时清除全部网站，history=1 时同时删除历史条目
+func (srv *Server) purgeCacheHandler(w http.ResponseWriter, r *http.Request) {
+	site := r.URL.Query().Get("site")
+	all, _ := strconv.ParseBool(r.URL.Query().Get("all"))
+	if site == "" && !all {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Missing 'site' parameter, or all=1 to purge every site")
+		return
+	}
+	if site != "" && all {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Use either 'site' or all=1")
+		return
+	}
+	history, _ := strconv.ParseBool(r.URL.Query().Get("history"))
+
+	sites := []string{site}
+	if all {
+		var err error
+		if sites, err = srv.snapshotSites(); err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeStorage, fmt.Sprintf("Failed to list sites: %v", err))
+			return
+		}
+	}
+
+	for _, site := range sites {
+		if err := srv.store.Delete(site, history); err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeStorage, fmt.Sprintf("Failed to purge %s: %v", site, err))
+			return
+		}
+		srv.clearFailure(site)
+	}
+	slog.Info("Purged cache", "sites", strings.Join(sites, ","), "history", history)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"purged": sites, "history": history})
+}
+
+// 立即刷新：POST /admin/refresh?site=x，async=1 时在后台刷新并返回任务 ID，
+// 之后通过 GET /admin/jobs?id=<id> 查询结果
+func (srv *Server) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	site := r.URL.Query().Get("site")
+	if site == "" {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Missing 'site' parameter")
+		return
+	}
+	if _, ok := srv.getSiteConfig(site); !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeSiteNotFound, fmt.Sprintf("Unknown site: %s", site))
+		return
+	}
+
+	if async, _ := strconv.ParseBool(r.URL.Query().Get("async")); async {
+		id := srv.startRefreshJob(site)
+		w.Header().Set("Location", srv.requestBasePath(r)+"/admin/jobs?id="+id)
+		writeJSON(w, http.StatusAccepted, map[string]string{"id": id, "status": JobRunning})
+		return
+	}
+
+	ctx, cancel := srv.fetchContext(r.Context())
+	defer cancel()
+	fc, err := srv.refreshCacheContext(ctx, site)
+	if err != nil {
+		writeJSON(w, fetchErrorStatus(err, http.StatusBadGateway), map[string]string{"site": site, "error": err.Error(), "code": fetchErrorCode(err)})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"site":     site,
+		"items":    len(fc.Feed.Channel.Items),
+		"skipped":  fc.Skipped,
+		"expireAt": fc.ExpireAt,
+	})
+}
+
+// 查询后台刷新任务：GET /admin/jobs?id=<id>
+func (srv *Server) jobHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := srv.getJob(r.URL.Query().Get("id"))
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "Job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// 网站配置：GET /admin/sites（?site=x 时只返回一个网站），
+// 使用 -config-db 时支持 PUT /admin/sites?site=x 新增或修改、DELETE 删除
+func (srv *Server) siteConfigHandler(w http.ResponseWriter, r *http.Request) {
+	site := r.URL.Query().Get("site")
+
+	if r.Method == http.MethodGet {
+		if site == "" {
+			writeJSON(w, http.StatusOK, srv.getAllSiteConfig())
+			return
+		}
+		config, ok := srv.getSiteConfig(site)
+		if !ok {
+			writeError(w, r, http.StatusNotFound, ErrCodeSiteNotFound, fmt.Sprintf("Unknown site: %s", site))
+			return
+		}
+		writeJSON(w, http.StatusOK, config)
+		return
+	}
+
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if srv.configDB == nil {
+		writeError(w, r, http.StatusConflict, ErrCodeConflict, "Site configuration is read-only, start the server with -config-db")
+		return
+	}
+	if site == "" {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Missing 'site' parameter")
+		return
+	}
+
+	configs := make(map[string]SiteConfig)
+	for name, sc := range srv.getBaseSiteConfigs() {
+		configs[name] = sc
+	}
+
+	if r.Method == http.MethodDelete {
+		found, err := srv.configDB.Delete(site)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("Failed to delete site: %v", err))
+			return
+		}
+		if !found {
+			writeError(w, r, http.StatusNotFound, ErrCodeSiteNotFound, fmt.Sprintf("Unknown site: %s", site))
+			return
+		}
+		delete(configs, site)
+		srv.setSiteConfigs(configs)
+		slog.Info("Deleted site config", "site", site)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var sc SiteConfig
+	if err := json.NewDecoder(r.Body).Decode(&sc); err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("Invalid site config: %v", err))
+		return
+	}
+	if err := validateSiteConfig(site, sc); err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if err := srv.configDB.Put(site, sc); err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("Failed to save site: %v", err))
+		return
+	}
+
+	_, existed := configs[site]
+	configs[site] = sc
+	srv.setSiteConfigs(configs)
+	if existed {
+		go srv.loadSiteImage(site, sc)
+	}
+	srv.initCache()
+
+	slog.Info("Saved site config", "site", site)
+	status := http.StatusOK
+	if !existed {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, sc)
+}
+
+// /admin/stats 返回的网站统计
+type siteStatsResponse struct {
+	Site      string `json:"site"`
+	Requests  int64  `json:"requests"`
+	Hits      int64  `json:"hits"`
+	StaleHits int64  `json:"staleHits"`
+	Misses    int64  `json:"misses"`
+
+	Refreshes           int64      `json:"refreshes"`
+	Failures            int64      `json:"failures"`
+	ConsecutiveFailures int64      `json:"consecutiveFailures"`
+	Disabled            bool       `json:"disabled"`
+	LastRefresh         *time.Time `json:"lastRefresh,omitempty"`
+	LastDurationMs      int64      `json:"lastDurationMs"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastErrorAt         *time.Time `json:"lastErrorAt,omitempty"`
+
+	Cached    bool       `json:"cached"`
+	Items     int        `json:"items"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	ExpireAt  *time.Time `json:"expireAt,omitempty"`
+	// 缓存过期了多少秒，未过期时为 0
+	StaleSeconds int64 `json:"staleSeconds"`
+}
+
+// 每个配置的网站的命中/未命中次数、最近一次刷新的耗时和错误、条目数以及缓存过期程度
+func (srv *Server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	configs := srv.getAllSiteConfig()
+	sites := make([]string, 0, len(configs))
+	for site := range configs {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+
+	now := time.Now()
+	resp := make([]siteStatsResponse, 0, len(sites))
+	for _, site := range sites {
+		st := srv.getStats(site)
+		sr := siteStatsResponse{
+			Site:                site,
+			Requests:            srv.siteRequests(site),
+			Hits:                st.Hits,
+			StaleHits:           st.StaleHits,
+			Misses:              st.Misses,
+			Refreshes:           st.Refreshes,
+			Failures:            st.Failures,
+			ConsecutiveFailures: st.ConsecutiveFailures,
+			Disabled:            srv.siteDisabled(site),
+			LastDurationMs:      st.LastDuration.Milliseconds(),
+			LastError:           st.LastError,
+		}
+		if !st.LastRefresh.IsZero() {
+			sr.LastRefresh = &st.LastRefresh
+		}
+		if !st.LastErrorAt.IsZero() {
+			sr.LastErrorAt = &st.LastErrorAt
+		}
+
+		if fc, ok := srv.getCachedFeed(site); ok {
+			sr.Cached = true
+			sr.Items = len(fc.Feed.Channel.Items)
+			sr.UpdatedAt, sr.ExpireAt = &fc.UpdatedAt, &fc.ExpireAt
+			if now.After(fc.ExpireAt) {
+				sr.StaleSeconds = int64(now.Sub(fc.ExpireAt).Seconds())
+			}
+		}
+		resp = append(resp, sr)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
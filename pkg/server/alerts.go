@@ -0,0 +1,93 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// 告警 webhook 的请求体。text 为可读的摘要，可以直接用于 Slack 等接受 text 字段的 webhook
+type alertEvent struct {
+	Event               string     `json:"event"`
+	Site                string     `json:"site"`
+	Name                string     `json:"name,omitempty"`
+	URL                 string     `json:"url,omitempty"`
+	ConsecutiveFailures int64      `json:"consecutive_failures"`
+	Threshold           int64      `json:"threshold"`
+	Error               string     `json:"error,omitempty"`
+	ErrorCode           string     `json:"error_code,omitempty"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	Time                time.Time  `json:"time"`
+	Text                string     `json:"text"`
+}
+
+const (
+	alertEventFailing   = "site_failing"
+	alertEventRecovered = "site_recovered"
+)
+
+// 刷新后检查连续失败次数：prev、cur 为本次刷新前后的次数，
+// 达到阈值时发送告警，之前达到过阈值的网站成功后发送恢复通知
+func (srv *Server) checkFailureStreak(site string, prev, cur int64, err error) {
+	if srv.cfg.AlertWebhook == "" || srv.cfg.AlertThreshold <= 0 {
+		return
+	}
+	var ev alertEvent
+	switch {
+	case prev < srv.cfg.AlertThreshold && cur >= srv.cfg.AlertThreshold:
+		ev = alertEvent{Event: alertEventFailing, Error: err.Error(), ErrorCode: fetchErrorCode(err)}
+	case prev >= srv.cfg.AlertThreshold && cur == 0:
+		ev = alertEvent{Event: alertEventRecovered}
+	default:
+		return
+	}
+
+	config, _ := srv.getSiteConfig(site)
+	ev.Site, ev.Name, ev.URL = site, config.Name, config.URL
+	ev.ConsecutiveFailures, ev.Threshold = max(cur, prev), srv.cfg.AlertThreshold
+	if last := srv.getStats(site).LastSuccess; !last.IsZero() {
+		ev.LastSuccess = &last
+	}
+	ev.Time = time.Now()
+	if ev.Event == alertEventFailing {
+		ev.Text = fmt.Sprintf("RSS site %s has failed %d times in a row: %s", site, cur, ev.Error)
+	} else {
+		ev.Text = fmt.Sprintf("RSS site %s recovered after %d consecutive failures", site, prev)
+	}
+
+	go func() {
+		if err := srv.postAlert(ev); err != nil {
+			slog.Error("Failed to send alert", "site", site, "event", ev.Event, "error", err)
+			return
+		}
+		slog.Info("Sent alert", "site", site, "event", ev.Event, "consecutive_failures", ev.ConsecutiveFailures)
+	}()
+}
+
+func (srv *Server) postAlert(ev alertEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, srv.cfg.AlertWebhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 历史条目查询的默认条数和最大条数
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+)
+
+// API 返回的条目
+type apiItem struct {
+	GUID        string    `json:"guid"`
+	Title       string    `json:"title"`
+	Link        string    `json:"link"`
+	Description string    `json:"description"`
+	PubDate     string    `json:"pubDate,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+}
+
+func newAPIItem(rec storedItem) apiItem {
+	item := apiItem{
+		GUID:        rec.Item.GUID,
+		Title:       rec.Item.Title,
+		Link:        rec.Item.Link,
+		Description: rec.Item.Description,
+		PubDate:     rec.Item.PubDate,
+		FirstSeen:   time.Unix(rec.FirstSeen, 0),
+		LastSeen:    time.Unix(rec.LastSeen, 0),
+	}
+	if rec.Item.Thumbnail != nil {
+		item.Image = rec.Item.Thumbnail.URL
+	}
+	return item
+}
+
+// 以 JSON 格式返回
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// 解析 since 参数，支持 2006-01-02 和 RFC 3339 格式
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// 查询历史条目：/api/history?site=x&since=2024-05-01&q=关键词&limit=100
+func (srv *Server) historyHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	site := params.Get("site")
+	if site == "" {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Missing 'site' parameter")
+		return
+	}
+	if !srv.checkFeedAuth(w, r, site) {
+		return
+	}
+
+	q := ItemQuery{Query: params.Get("q"), Limit: defaultHistoryLimit}
+	if v := params.Get("since"); v != "" {
+		since, err := parseSince(v)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Invalid 'since' parameter, expected YYYY-MM-DD or RFC 3339")
+			return
+		}
+		q.Since = since
+	}
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Invalid 'limit' parameter")
+			return
+		}
+		if limit > maxHistoryLimit {
+			limit = maxHistoryLimit
+		}
+		q.Limit = limit
+	}
+
+	recs, err := srv.store.QueryItems(site, q)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeStorage, "Failed to query history: "+err.Error())
+		return
+	}
+
+	items := make([]apiItem, 0, len(recs))
+	for _, rec := range recs {
+		items = append(items, newAPIItem(rec))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"site":  site,
+		"items": items,
+	})
+}
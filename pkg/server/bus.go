@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"rss-zhuaqu/pkg/feed"
+)
+
+// 消息总线：每个新条目发布一条消息到 NATS 或 Kafka，供数据管道、搜索索引等消费。
+// 主题按网站或标签路由，消息内容与 webhook 相同
+
+// 默认的主题
+const defaultBusTopic = "rss.items"
+
+// 消息总线的发布者
+type busPublisher interface {
+	Publish(ctx context.Context, topic, key string, value []byte) error
+}
+
+// 根据参数创建发布者，启动时调用。连接在第一次发布时建立
+func (srv *Server) initBusPublishers() {
+	if srv.cfg.NATSURL != "" {
+		srv.natsPublisher = newNATSClient(srv.cfg.NATSURL)
+	}
+	if len(srv.cfg.KafkaBrokers) > 0 {
+		srv.kafkaPublisher = newKafkaClient(srv.cfg.KafkaBrokers)
+	}
+	if srv.cfg.MQTTURL != "" {
+		if srv.cfg.MQTTClientID == "" {
+			srv.cfg.MQTTClientID = "rss-zhuaqu-" + randomID()[:8]
+		}
+		c := newMQTTClient(srv.cfg.MQTTURL)
+		c.clientID, c.qos, c.retain = srv.cfg.MQTTClientID, srv.cfg.MQTTQoS, srv.cfg.MQTTRetain
+		srv.mqttPublisher = c
+	}
+}
+
+// 网站的主题：第一个筛选匹配的路由，没有指定路由时为 rss.items（租户的网站不发布）。
+// 主题中的 {site} 替换为网站名，网站名中主题不允许的字符替换为 _
+func busTopic(routes routeFlag, site string, config SiteConfig) (string, bool) {
+	if len(routes) == 0 {
+		return defaultBusTopic, !isTenantSite(site)
+	}
+	for _, route := range routes {
+		if route.Filter.match(site, config) {
+			return strings.ReplaceAll(route.Target, "{site}", busSafeName(site)), true
+		}
+	}
+	return "", false
+}
+
+func busSafeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+func (srv *Server) natsSink(site string, config SiteConfig, items []Item) []delivery {
+	return srv.busDeliveries("nats", srv.natsPublisher, srv.cfg.NATSSubjects, site, config, items)
+}
+
+func (srv *Server) kafkaSink(site string, config SiteConfig, items []Item) []delivery {
+	return srv.busDeliveries("kafka", srv.kafkaPublisher, srv.cfg.KafkaTopics, site, config, items)
+}
+
+// 每个条目一条消息，键为网站名，Kafka 中同一网站的消息在同一分区内保持顺序
+func (srv *Server) busDeliveries(sink string, pub busPublisher, routes routeFlag, site string, config SiteConfig, items []Item) []delivery {
+	if pub == nil {
+		return nil
+	}
+	topic, ok := busTopic(routes, site, config)
+	if !ok {
+		return nil
+	}
+	var feedURL string
+	if srv.cfg.PublicURL != "" {
+		feedURL = srv.websubTopic(site, FormatRSS)
+	}
+
+	var ds []delivery
+	for _, item := range items {
+		value, err := json.Marshal(webhookPayload{
+			Event:    "new_item",
+			ID:       randomID(),
+			Site:     site,
+			SiteName: config.Name,
+			SiteURL:  config.URL,
+			FeedURL:  feedURL,
+			Item:     feed.NewJSONFeedItem(item),
+			Time:     time.Now().UTC(),
+		})
+		if err != nil {
+			continue
+		}
+		ds = append(ds, delivery{
+			sink:   sink,
+			target: topic,
+			site:   site,
+			send: func(ctx context.Context) error {
+				return pub.Publish(ctx, topic, site, value)
+			},
+		})
+	}
+	return ds
+}
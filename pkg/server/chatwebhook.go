@@ -0,0 +1,209 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+
+	"rss-zhuaqu/pkg/feed"
+	"rss-zhuaqu/pkg/scraper"
+)
+
+// Slack 和 Discord 的 incoming webhook：每个 webhook 对应一个频道，
+// 全局的 webhook 接收所有网站的新条目，网站配置的 Slack、Discord 只接收该网站的
+
+const (
+	defaultSlackTemplate   = "*{{slack .SiteName}}*\n{{range .Items}}• <{{.URL}}|{{slack .Title}}>\n{{end}}"
+	defaultDiscordTemplate = "**{{md .SiteName}}**\n{{range .Items}}• [{{md .Title}}](<{{.URL}}>)\n{{end}}"
+)
+
+// Slack 建议单条消息不超过 4000 字符，Discord 限制 2000 字符
+const (
+	slackMaxMessage   = 4000
+	discordMaxMessage = 2000
+)
+
+// 消息模板的数据
+type chatMessageData struct {
+	Site     string
+	SiteName string
+	SiteURL  string
+	FeedURL  string
+	Items    []feed.JSONFeedItem
+}
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `_`, `\_`, "`", "\\`", `~`, `\~`, `|`, `\|`, `[`, `\[`, `]`, `\]`, `>`, `\>`)
+
+var chatTemplateFuncs = template.FuncMap{
+	// Slack mrkdwn 需要转义 &、<、>
+	"slack": strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace,
+	// Discord Markdown 转义
+	"md": markdownEscaper.Replace,
+}
+
+// 一个平台的推送方式
+type chatSink struct {
+	srv      *Server
+	name     string
+	tmpl     *template.Template
+	maxLen   int
+	webhooks func(site string, config SiteConfig) []string
+	payload  func(text string) interface{}
+}
+
+func (srv *Server) slackItems(site string, config SiteConfig, items []Item) []delivery {
+	return srv.slackSink.sink(site, config, items)
+}
+
+func (srv *Server) discordItems(site string, config SiteConfig, items []Item) []delivery {
+	return srv.discordSink.sink(site, config, items)
+}
+
+// 解析消息模板，启动时调用
+func (srv *Server) initChatSinks() error {
+	var err error
+	srv.slackSink, err = srv.newChatSink("slack", srv.cfg.SlackTemplate, defaultSlackTemplate, slackMaxMessage,
+		func(site string, config SiteConfig) []string {
+			return mergeTargets(globalTargets(site, srv.cfg.SlackWebhooks), config.Slack)
+		},
+		func(text string) interface{} { return map[string]string{"text": text} })
+	if err != nil {
+		return err
+	}
+	srv.discordSink, err = srv.newChatSink("discord", srv.cfg.DiscordTemplate, defaultDiscordTemplate, discordMaxMessage,
+		func(site string, config SiteConfig) []string {
+			return mergeTargets(globalTargets(site, srv.cfg.DiscordWebhooks), config.Discord)
+		},
+		func(text string) interface{} {
+			// 不解析消息中的 @everyone 等提及
+			return map[string]interface{}{"content": text, "allowed_mentions": map[string][]string{"parse": {}}}
+		})
+	return err
+}
+
+func (srv *Server) newChatSink(name, text, def string, maxLen int, webhooks func(string, SiteConfig) []string, payload func(string) interface{}) (*chatSink, error) {
+	if text == "" {
+		text = def
+	}
+	tmpl, err := template.New(name).Funcs(chatTemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%s template: %w", name, err)
+	}
+	return &chatSink{srv: srv, name: name, tmpl: tmpl, maxLen: maxLen, webhooks: webhooks, payload: payload}, nil
+}
+
+// 全局推送目标，租户的网站不发送
+func globalTargets(site string, targets []string) []string {
+	if isTenantSite(site) {
+		return nil
+	}
+	return targets
+}
+
+// 去掉重复的目标
+func mergeTargets(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	var targets []string
+	for _, list := range lists {
+		for _, t := range list {
+			if !seen[t] {
+				seen[t] = true
+				targets = append(targets, t)
+			}
+		}
+	}
+	return targets
+}
+
+func (s *chatSink) sink(site string, config SiteConfig, items []Item) []delivery {
+	if s == nil {
+		return nil
+	}
+	targets := s.webhooks(site, config)
+	if len(targets) == 0 {
+		return nil
+	}
+	texts, err := s.render(site, config, items)
+	if err != nil {
+		return nil
+	}
+
+	var ds []delivery
+	for _, target := range targets {
+		for _, text := range texts {
+			target := target
+			body, err := json.Marshal(s.payload(text))
+			if err != nil {
+				continue
+			}
+			ds = append(ds, delivery{
+				sink:   s.name,
+				target: redactWebhook(target),
+				site:   site,
+				send: func(ctx context.Context) error {
+					if err := s.srv.chatPacer.wait(ctx, target); err != nil {
+						return err
+					}
+					return postBody(ctx, target, "application/json", body, nil)
+				},
+			})
+		}
+	}
+	return ds
+}
+
+// 按模板生成消息，超过长度限制时把条目分成多条消息
+func (s *chatSink) render(site string, config SiteConfig, items []Item) ([]string, error) {
+	data := chatMessageData{Site: site, SiteName: config.Name, SiteURL: config.URL}
+	if data.SiteName == "" {
+		data.SiteName = site
+	}
+	if s.srv.cfg.PublicURL != "" {
+		data.FeedURL = s.srv.websubTopic(site, FormatRSS)
+	}
+
+	var texts []string
+	var batch []feed.JSONFeedItem
+	var last string
+	for _, item := range items {
+		data.Items = append(batch, feed.NewJSONFeedItem(item))
+		text, err := s.execute(data)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) > 0 && len([]rune(text)) > s.maxLen {
+			texts = append(texts, scraper.TruncateText(last, s.maxLen))
+			data.Items = []feed.JSONFeedItem{feed.NewJSONFeedItem(item)}
+			if text, err = s.execute(data); err != nil {
+				return nil, err
+			}
+		}
+		batch, last = data.Items, text
+	}
+	if len(batch) > 0 {
+		texts = append(texts, scraper.TruncateText(last, s.maxLen))
+	}
+	return texts, nil
+}
+
+func (s *chatSink) execute(data chatMessageData) (string, error) {
+	var buf bytes.Buffer
+	if err := s.tmpl.Execute(&buf, data); err != nil {
+		slog.Warn("Failed to render notification template", "sink", s.name, "site", data.Site, "error", err)
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// webhook 地址中包含令牌，日志中只保留主机名
+func redactWebhook(target string) string {
+	if i := strings.Index(target, "://"); i >= 0 {
+		host, _, _ := strings.Cut(target[i+3:], "/")
+		return target[:i+3] + host + "/..."
+	}
+	return "..."
+}
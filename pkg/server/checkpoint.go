@@ -0,0 +1,170 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"rss-zhuaqu/pkg/storage"
+)
+
+// 检查点文件的格式：运行统计（连续失败次数等）、抓取失败的退避状态、暂停的网站、维护模式、等待发送的邮件摘要、故障升级的状态、生成的条目摘要和译文。
+// 使用内存存储时还包括缓存（含 ETag 使用的内容哈希）和历史条目的快照
+type checkpointData struct {
+	Version      int
+	CreatedAt    time.Time
+	Stats        map[string]siteStats
+	Failures     map[string]fetchFailure
+	Disabled     []string
+	Maintenance  maintenanceStatus
+	Digests      map[string][]digestItem     `json:",omitempty"`
+	Incidents    map[string]incidentState    `json:",omitempty"`
+	Summaries    map[string]summaryEntry     `json:",omitempty"`
+	Translations map[string]translationEntry `json:",omitempty"`
+	Snapshot     *Snapshot                   `json:",omitempty"`
+}
+
+const checkpointVersion = 1
+
+// 内存存储重启后数据丢失，需要写入检查点
+func (srv *Server) storageVolatile() bool {
+	_, ok := srv.store.(*storage.Memory)
+	return ok
+}
+
+// 写入检查点文件。先写临时文件再重命名，避免写到一半时进程退出导致文件损坏
+func (srv *Server) saveCheckpoint(path string) error {
+	srv.checkpointMu.Lock()
+	defer srv.checkpointMu.Unlock()
+
+	cp := checkpointData{
+		Version:      checkpointVersion,
+		CreatedAt:    time.Now(),
+		Stats:        make(map[string]siteStats),
+		Failures:     make(map[string]fetchFailure),
+		Maintenance:  srv.getMaintenance(),
+		Digests:      srv.pendingDigests(),
+		Incidents:    srv.incidentStates(),
+		Summaries:    srv.summaryEntries(),
+		Translations: srv.translationEntries(),
+	}
+	srv.statsMu.Lock()
+	for site, st := range srv.stats {
+		cp.Stats[site] = *st
+	}
+	srv.statsMu.Unlock()
+	srv.failuresLock.RLock()
+	for site, f := range srv.failures {
+		if time.Now().Before(f.Until) {
+			cp.Failures[site] = f
+		}
+	}
+	srv.failuresLock.RUnlock()
+	srv.disabledSitesMu.RLock()
+	for site := range srv.disabledSites {
+		cp.Disabled = append(cp.Disabled, site)
+	}
+	srv.disabledSitesMu.RUnlock()
+	sort.Strings(cp.Disabled)
+
+	if srv.storageVolatile() {
+		snap, err := srv.buildSnapshot()
+		if err != nil {
+			return err
+		}
+		cp.Snapshot = &snap
+	}
+
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// 启动时从检查点文件恢复状态，文件不存在时忽略
+func (srv *Server) loadCheckpoint(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var cp checkpointData
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return err
+	}
+
+	srv.statsMu.Lock()
+	for site, st := range cp.Stats {
+		st := st
+		srv.stats[site] = &st
+	}
+	srv.statsMu.Unlock()
+	srv.failuresLock.Lock()
+	for site, f := range cp.Failures {
+		if time.Now().Before(f.Until) {
+			srv.failures[site] = f
+		}
+	}
+	srv.failuresLock.Unlock()
+	for _, site := range cp.Disabled {
+		srv.setSiteDisabled(site, true)
+	}
+	if cp.Maintenance.Enabled {
+		srv.maintenanceMu.Lock()
+		srv.maintenanceSince, srv.maintenanceReason = *cp.Maintenance.Since, cp.Maintenance.Reason
+		srv.maintenanceMu.Unlock()
+		slog.Warn("Restored maintenance mode from checkpoint, background refreshes paused", "reason", cp.Maintenance.Reason)
+	}
+
+	srv.restoreDigests(cp.Digests)
+	srv.restoreIncidents(cp.Incidents)
+	srv.restoreSummaries(cp.Summaries)
+	srv.restoreTranslations(cp.Translations)
+
+	var feeds, items int
+	if cp.Snapshot != nil && srv.storageVolatile() {
+		if feeds, items, err = srv.applySnapshot(*cp.Snapshot); err != nil {
+			return err
+		}
+	}
+	slog.Info("Restored checkpoint", "file", path, "created_at", cp.CreatedAt, "sites", len(cp.Stats), "feeds", feeds, "items", items)
+	return nil
+}
+
+// 写入文件存储和检查点文件
+func (srv *Server) checkpoint() {
+	if f, ok := srv.store.(Flusher); ok {
+		if err := f.Flush(); err != nil {
+			slog.Error("Failed to flush storage", "error", err)
+		}
+	}
+	if srv.cfg.CheckpointFile != "" {
+		if err := srv.saveCheckpoint(srv.cfg.CheckpointFile); err != nil {
+			slog.Error("Failed to write checkpoint", "file", srv.cfg.CheckpointFile, "error", err)
+		}
+	}
+}
+
+// 每隔 checkpointInterval 写一次检查点
+func (srv *Server) startCheckpointer() {
+	if srv.cfg.CheckpointInterval <= 0 {
+		return
+	}
+	if _, ok := srv.store.(Flusher); !ok && srv.cfg.CheckpointFile == "" {
+		return
+	}
+	go func() {
+		for range time.Tick(srv.cfg.CheckpointInterval) {
+			srv.checkpoint()
+		}
+	}()
+}
@@ -0,0 +1,330 @@
+package server
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"rss-zhuaqu/pkg/feed"
+	"rss-zhuaqu/pkg/scraper"
+)
+
+// 命令行命令。全局参数写在命令之前，命令自己的参数写在命令之后，如
+// rss-zhuaqu -config sites.json list -json
+
+const commandUsage = `Usage: %s [flags] [command] [args]
+
+Commands:
+  serve                 Start the HTTP server (default)
+  validate [file]       Check the config file (default -config) and report every invalid site
+  list [-json]          List configured sites and their cached feeds
+  refresh [site...]     Fetch sites (default all) into the storage and exit
+  fetch -site <site> [-format rss|atom|json] [-o file]
+                        Fetch one site and write its feed to stdout or a file
+  test -site <site> [-n N] [-json]
+                        Print what each selector matched in every item node, without caching
+  publish [dir]         Fetch all sites and write feeds to dir (or -s3-bucket)
+  export|import <file>  Export or import a snapshot of the storage, "-" for stdout/stdin
+  migrate               Apply pending database migrations
+
+Flags:
+`
+
+// 需要存储的命令：export/import 导出或导入快照，publish 发布静态文件，migrate 执行数据库迁移，
+// list 列出网站，refresh 抓取网站后退出，fetch 抓取一个网站并输出订阅源，test 调试选择器
+func (srv *Server) runCommand(args []string) error {
+	switch args[0] {
+	case "export", "import":
+		return srv.runSnapshotCommand(args)
+	case "publish":
+		return srv.runPublishCommand(args[1:])
+	case "migrate":
+		// 迁移已在打开数据库时执行
+		slog.Info("Database schema is up to date")
+		return nil
+	case "list":
+		return srv.runListCommand(args[1:])
+	case "refresh":
+		return srv.runRefreshCommand(args[1:])
+	case "fetch":
+		return srv.runFetchCommand(args[1:])
+	case "test":
+		return srv.runTestCommand(args[1:])
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// validate 命令：检查配置文件，不加载网站也不打开存储。所有错误输出到标准错误
+func (srv *Server) runValidateCommand(args []string, configPath string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: validate [file]")
+	}
+	path := configPath
+	if len(args) == 1 {
+		path = args[0]
+	}
+	if path == "" {
+		return fmt.Errorf("usage: validate <file>, or set -config")
+	}
+
+	config, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	errs := validateConfig(path, config)
+	if err := srv.setAutoTagRules(config.AutoTags); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", path, err))
+	}
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %d problems found", path, len(errs))
+	}
+	fmt.Printf("%s: %d sites OK\n", path, len(config.Sites))
+	return nil
+}
+
+// list 命令输出的网站信息
+type siteListEntry struct {
+	Site     string
+	Name     string
+	URL      string
+	Type     string   `json:",omitempty"`
+	Tags     []string `json:",omitempty"`
+	Disabled bool     `json:",omitempty"`
+	// 存储中缓存的条目数和过期时间，没有缓存时为空
+	Items    int        `json:",omitempty"`
+	ExpireAt *time.Time `json:",omitempty"`
+}
+
+// list 命令：按名称列出网站，-json 输出 JSON
+func (srv *Server) runListCommand(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print sites as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: list [-json]")
+	}
+
+	configs := srv.getAllSiteConfig()
+	entries := make([]siteListEntry, 0, len(configs))
+	for site, config := range configs {
+		e := siteListEntry{Site: site, Name: config.Name, URL: config.URL, Type: config.Type, Tags: config.Tags, Disabled: srv.siteDisabled(site)}
+		if fc, ok := srv.getCachedFeed(site); ok {
+			e.Items = len(fc.Feed.Channel.Items)
+			e.ExpireAt = &fc.ExpireAt
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Site < entries[j].Site })
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SITE\tNAME\tURL\tTAGS\tCACHED")
+	for _, e := range entries {
+		cached := "-"
+		if e.ExpireAt != nil {
+			cached = fmt.Sprintf("%d items, expires %s", e.Items, e.ExpireAt.Local().Format("2006-01-02 15:04"))
+		}
+		name := e.Name
+		if e.Disabled {
+			name += " (disabled)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Site, name, e.URL, strings.Join(e.Tags, ","), cached)
+	}
+	return tw.Flush()
+}
+
+// refresh 命令：抓取指定网站（默认全部）写入存储后退出，配合共享存储（文件、Redis 等）使用
+func (srv *Server) runRefreshCommand(args []string) error {
+	sites := args
+	if len(sites) == 0 {
+		for site := range srv.getAllSiteConfig() {
+			sites = append(sites, site)
+		}
+		sort.Strings(sites)
+	}
+	for _, site := range sites {
+		if _, ok := srv.getSiteConfig(site); !ok {
+			return fmt.Errorf("unknown site %q", site)
+		}
+	}
+
+	var failed int
+	for _, site := range sites {
+		start := time.Now()
+		fc, err := srv.refreshCache(site)
+		if err != nil {
+			fmt.Printf("%s\tFAILED\t%v\n", site, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s\t%d items\t%s\n", site, len(fc.Feed.Channel.Items), time.Since(start).Round(time.Millisecond))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d sites failed", failed, len(sites))
+	}
+	return nil
+}
+
+// fetch 命令：抓取一个网站，把订阅源写到标准输出或文件，不启动 HTTP 服务。
+// 适合由 cron 定时生成静态文件，或调试网站配置
+func (srv *Server) runFetchCommand(args []string) error {
+	fs := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	site := fs.String("site", "", "Site to fetch")
+	format := fs.String("format", FormatRSS, "Feed format: rss, atom or json")
+	output := fs.String("o", "-", "Output file, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *site == "" || fs.NArg() > 0 {
+		return fmt.Errorf("usage: fetch -site <site> [-format rss|atom|json] [-o file]")
+	}
+	if _, ok := feedFormatTypes[*format]; !ok {
+		return fmt.Errorf("unknown format %q, expected rss, atom or json", *format)
+	}
+	if _, ok := srv.getSiteConfig(*site); !ok {
+		return fmt.Errorf("unknown site %q", *site)
+	}
+
+	fc, err := srv.refreshCache(*site)
+	if err != nil {
+		return err
+	}
+	// 设置了 -public-url 时 self 地址与服务中的相同
+	var feedURL string
+	if srv.cfg.PublicURL != "" {
+		feedURL = srv.websubTopic(*site, *format)
+	}
+	data, err := feed.Encode(fc.Feed, *format, feedURL, "")
+	if err != nil {
+		return err
+	}
+	if *format == FormatRSS {
+		data = append([]byte(xml.Header), data...)
+	}
+
+	if *output == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	// 先写临时文件再改名，与 publish 相同
+	tmp := *output + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, *output); err != nil {
+		return err
+	}
+	slog.Info("Feed written", "site", *site, "format", *format, "path", *output, "items", len(fc.Feed.Channel.Items))
+	return nil
+}
+
+// test 命令：抓取网站页面，逐个条目节点输出每个选择器匹配到的节点数、原始值和字段值，
+// 标出为空的字段和被跳过的条目，调试选择器时不需要阅读生成的 XML
+func (srv *Server) runTestCommand(args []string) error {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	site := fs.String("site", "", "Site to test")
+	limit := fs.Int("n", 0, "Print at most this many item nodes (0 = all)")
+	asJSON := fs.Bool("json", false, "Print the result as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *site == "" || fs.NArg() > 0 {
+		return fmt.Errorf("usage: test -site <site> [-n N] [-json]")
+	}
+	config, ok := srv.getSiteConfig(*site)
+	if !ok {
+		return fmt.Errorf("unknown site %q", *site)
+	}
+
+	nodes, err := srv.traceSelectors(srv.appCtx, config)
+	if err != nil {
+		return err
+	}
+	// 统计全部节点中为空的字段和被跳过的条目
+	empty := make(map[string]int)
+	var skipped int
+	for _, n := range nodes {
+		for _, f := range n.Fields {
+			if f.Value == "" {
+				empty[f.Field]++
+			}
+		}
+		if n.Skipped != "" {
+			skipped++
+		}
+	}
+	total := len(nodes)
+	if *limit > 0 && len(nodes) > *limit {
+		nodes = nodes[:*limit]
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(nodes)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, n := range nodes {
+		fmt.Fprintf(tw, "#%d %s\n", n.Index, n.Page)
+		status := "kept"
+		if n.Skipped != "" {
+			status = "skipped: " + n.Skipped
+		}
+		for _, f := range n.Fields {
+			var result string
+			switch {
+			case f.Selector == "":
+				result = "(no selector)"
+			case f.Matched == 0:
+				result = "(no match)"
+			case f.Value == "":
+				result = fmt.Sprintf("(empty) raw=%q", scraper.TruncateText(f.Raw, 80))
+			case f.Raw != "" && scraper.NormalizeText(f.Raw) != f.Value:
+				result = fmt.Sprintf("%q <- raw %q", scraper.TruncateText(f.Value, 80), scraper.TruncateText(f.Raw, 80))
+			default:
+				result = fmt.Sprintf("%q", scraper.TruncateText(f.Value, 80))
+			}
+			if f.Field == FieldDate && f.Value != "" && n.PubDate == "" {
+				status += ", date does not match DateFormat " + strconv.Quote(config.DateFormat)
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%d\t%s\n", f.Field, f.Selector, f.Matched, result)
+		}
+		fmt.Fprintf(tw, "  quality %d, %s\n\n", n.Quality, status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d item nodes matched", total)
+	if len(nodes) < total {
+		fmt.Printf(", %d shown", len(nodes))
+	}
+	fmt.Printf(", %d would be skipped\n", skipped)
+	for _, field := range []string{FieldTitle, FieldLink, FieldDescription, FieldDate, FieldImage} {
+		if empty[field] > 0 {
+			fmt.Printf("  %s empty in %d of %d\n", field, empty[field], total)
+		}
+	}
+	if total == 0 {
+		return fmt.Errorf("no item nodes matched")
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 配置文件中的时间长度，支持 "30m"、"1h30m" 这样的字符串，或以秒为单位的数字
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(time.Duration(v * float64(time.Second)))
+	case string:
+		dur, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(dur)
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// 配置文件结构
+type FileConfig struct {
+	Sites map[string]SiteConfig
+	// 应用于所有网站条目的自动标签规则
+	AutoTags []AutoTagRule `json:",omitempty"`
+}
+
+func (srv *Server) setSiteConfigs(configs map[string]SiteConfig) {
+	srv.siteConfigsMu.Lock()
+	srv.baseSiteConfigs = configs
+	srv.mergeSiteConfigs()
+	srv.siteConfigsMu.Unlock()
+	srv.notifySubscribers()
+	srv.notifyUpstreamSubscriber()
+}
+
+// 替换所有租户的网站，名称为 <租户 ID>:<网站>
+func (srv *Server) setTenantSiteConfigs(configs map[string]SiteConfig) {
+	srv.siteConfigsMu.Lock()
+	srv.tenantSiteConfigs = configs
+	srv.mergeSiteConfigs()
+	srv.siteConfigsMu.Unlock()
+}
+
+// 调用方持有 siteConfigsMu
+func (srv *Server) mergeSiteConfigs() {
+	if len(srv.tenantSiteConfigs) == 0 {
+		srv.siteConfigs = srv.baseSiteConfigs
+		return
+	}
+	base := srv.baseSiteConfigs
+	if base == nil {
+		base = defaultSiteConfigs()
+	}
+	merged := make(map[string]SiteConfig, len(base)+len(srv.tenantSiteConfigs))
+	for site, sc := range base {
+		merged[site] = sc
+	}
+	for site, sc := range srv.tenantSiteConfigs {
+		merged[site] = sc
+	}
+	srv.siteConfigs = merged
+}
+
+// 配置文件或数据库中的网站，不包括租户的网站
+func (srv *Server) getBaseSiteConfigs() map[string]SiteConfig {
+	srv.siteConfigsMu.RLock()
+	defer srv.siteConfigsMu.RUnlock()
+	if srv.baseSiteConfigs != nil {
+		return srv.baseSiteConfigs
+	}
+	return defaultSiteConfigs()
+}
+
+// 检查单个网站的配置
+func validateSiteConfig(site string, sc SiteConfig) error {
+	// 网站名用于文件名（publish）、租户网站的前缀（冒号）和 ?sites= 列表（逗号），只允许字母、数字、- 和 _
+	if !tenantNameRe.MatchString(site) {
+		return fmt.Errorf("site %q: name must be 1-64 letters, digits, - or _", site)
+	}
+	if sc.URL == "" {
+		return fmt.Errorf("site %s: URL is required", site)
+	}
+	switch sc.StaleAction {
+	case "", StaleActionBlock, StaleActionError:
+	default:
+		return fmt.Errorf("site %s: unknown StaleAction %q", site, sc.StaleAction)
+	}
+	switch sc.Type {
+	case "":
+	case SiteTypeMonitor:
+		if sc.WatchSelector == "" {
+			return fmt.Errorf("site %s: WatchSelector is required for monitor sites", site)
+		}
+	default:
+		return fmt.Errorf("site %s: unknown Type %q", site, sc.Type)
+	}
+	if sc.BasicAuth != "" && !strings.Contains(sc.BasicAuth, ":") {
+		return fmt.Errorf("site %s: BasicAuth must be user:password", site)
+	}
+	if err := validatePipeline(sc.Pipeline); err != nil {
+		return fmt.Errorf("site %s: %w", site, err)
+	}
+	for _, hook := range sc.Webhooks {
+		if !isHTTPURL(hook) {
+			return fmt.Errorf("site %s: webhook %q must be an http or https URL", site, hook)
+		}
+	}
+	for _, hook := range append(append([]string(nil), sc.Slack...), sc.Discord...) {
+		if !isHTTPURL(hook) {
+			return fmt.Errorf("site %s: chat webhook %q must be an http or https URL", site, hook)
+		}
+	}
+	if sc.Priority < 0 || sc.Priority > 5 {
+		return fmt.Errorf("site %s: Priority must be between 1 and 5", site)
+	}
+	if sc.MinQuality < 0 || sc.MinQuality > 100 {
+		return fmt.Errorf("site %s: MinQuality must be between 0 and 100", site)
+	}
+	if sc.UpstreamFeed != "" && !isHTTPURL(sc.UpstreamFeed) {
+		return fmt.Errorf("site %s: UpstreamFeed must be an http or https URL", site)
+	}
+	for _, lang := range sc.Translate {
+		if !langPattern.MatchString(lang) {
+			return fmt.Errorf("site %s: invalid Translate language %q", site, lang)
+		}
+	}
+	for _, rule := range sc.ReadLater {
+		if err := validateReadLaterRule(rule); err != nil {
+			return fmt.Errorf("site %s: %w", site, err)
+		}
+	}
+	return nil
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// 加载 JSON 配置文件
+func (srv *Server) loadConfig(path string) error {
+	config, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	if errs := validateConfig(path, config); len(errs) > 0 {
+		return errs[0]
+	}
+	if err := srv.setAutoTagRules(config.AutoTags); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	srv.setSiteConfigs(config.Sites)
+	return nil
+}
+
+func readConfig(path string) (FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return FileConfig{}, err
+	}
+	var config FileConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return FileConfig{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// 检查配置文件中的全部网站和自动标签规则，按网站名称的顺序返回所有错误
+func validateConfig(path string, config FileConfig) []error {
+	if len(config.Sites) == 0 {
+		return []error{fmt.Errorf("%s: no sites configured", path)}
+	}
+	sites := make([]string, 0, len(config.Sites))
+	for site := range config.Sites {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+
+	var errs []error
+	for _, site := range sites {
+		if err := validateSiteConfig(site, config.Sites[site]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
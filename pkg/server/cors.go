@@ -0,0 +1,109 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type corsRule struct {
+	prefix  string
+	origins []string
+}
+
+// -cors 参数，格式为 路径前缀=来源1,来源2，可以重复指定
+type corsRuleFlag []corsRule
+
+func (f *corsRuleFlag) String() string {
+	var parts []string
+	for _, rule := range *f {
+		parts = append(parts, rule.prefix+"="+strings.Join(rule.origins, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (f *corsRuleFlag) Set(value string) error {
+	prefix, origins, ok := strings.Cut(value, "=")
+	if !ok || !strings.HasPrefix(prefix, "/") {
+		return fmt.Errorf("expected /path=origin[,origin...], got %q", value)
+	}
+	*f = append(*f, corsRule{prefix: prefix, origins: splitList(origins)})
+	return nil
+}
+
+// 逗号分隔的列表，去掉空白和空项
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// 路径适用的来源：最长匹配的 -cors 规则，没有时为 corsOrigins。
+// 管理接口只能通过 -cors 单独开放
+func (srv *Server) corsOriginsFor(path string) []string {
+	var best *corsRule
+	for i, rule := range srv.cfg.CORSRules {
+		if strings.HasPrefix(path, rule.prefix) && (best == nil || len(rule.prefix) > len(best.prefix)) {
+			best = &srv.cfg.CORSRules[i]
+		}
+	}
+	if best != nil {
+		return best.origins
+	}
+	if isAdminPath(path) {
+		return nil
+	}
+	return srv.corsOrigins
+}
+
+// 路径允许来源时返回匹配的规则（"*" 或来源本身），否则返回空
+func (srv *Server) allowedOrigin(path, origin string) string {
+	for _, o := range srv.corsOriginsFor(path) {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return o
+		}
+	}
+	return ""
+}
+
+// 处理 CORS：来源被允许时加上 Access-Control-* 响应头，预检请求直接返回 204
+func (srv *Server) corsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed := srv.allowedOrigin(r.URL.Path, origin)
+		h := w.Header()
+		if allowed != "*" {
+			h.Add("Vary", "Origin")
+		}
+		if allowed == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if allowed != "*" {
+			allowed = origin
+		}
+		h.Set("Access-Control-Allow-Origin", allowed)
+
+		// 预检请求
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, DELETE, OPTIONS")
+			if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+				h.Set("Access-Control-Allow-Headers", headers)
+			}
+			h.Set("Access-Control-Max-Age", "86400")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", "ETag, Retry-After, Warning, X-Request-ID")
+		next.ServeHTTP(w, r)
+	})
+}
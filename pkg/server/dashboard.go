@@ -0,0 +1,123 @@
+package server
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+func (srv *Server) siteDisabled(site string) bool {
+	srv.disabledSitesMu.RLock()
+	defer srv.disabledSitesMu.RUnlock()
+	return srv.disabledSites[site]
+}
+
+func (srv *Server) setSiteDisabled(site string, disabled bool) {
+	srv.disabledSitesMu.Lock()
+	defer srv.disabledSitesMu.Unlock()
+	if disabled {
+		srv.disabledSites[site] = true
+	} else {
+		delete(srv.disabledSites, site)
+	}
+}
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"since": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return time.Since(t).Truncate(time.Second).String() + " ago"
+	},
+	"ms": func(d time.Duration) int64 { return d.Milliseconds() },
+}).Parse(dashboardHTML))
+
+// 管理页面中一个网站的状态
+type dashboardSite struct {
+	Site     string
+	Name     string
+	Stats    siteStats
+	Cached   bool
+	Items    int
+	Stale    bool
+	Disabled bool
+}
+
+// GET /admin 管理页面，列出每个网站最近一次刷新的时间、耗时、条目数和连续失败次数
+func (srv *Server) dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	configs := srv.getAllSiteConfig()
+	sites := make([]dashboardSite, 0, len(configs))
+	for site, config := range configs {
+		ds := dashboardSite{Site: site, Name: config.Name, Stats: srv.getStats(site), Disabled: srv.siteDisabled(site)}
+		if fc, ok := srv.getCachedFeed(site); ok {
+			ds.Cached = true
+			ds.Items = len(fc.Feed.Channel.Items)
+			ds.Stale = time.Now().After(fc.ExpireAt)
+		}
+		sites = append(sites, ds)
+	}
+	sort.Slice(sites, func(i, j int) bool { return sites[i].Site < sites[j].Site })
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := dashboardTemplate.Execute(w, map[string]interface{}{"Sites": sites, "Preview": nil, "Base": srv.requestBasePath(r), "Maintenance": srv.getMaintenance()}); err != nil {
+		slog.Error("Failed to render dashboard", "error", err)
+	}
+}
+
+// GET /admin/preview?site=x 在管理页面中预览网站的缓存条目，不受订阅源认证限制
+func (srv *Server) previewHandler(w http.ResponseWriter, r *http.Request) {
+	site := r.URL.Query().Get("site")
+	config, ok := srv.getSiteConfig(site)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown site: %s", site), http.StatusNotFound)
+		return
+	}
+	fc, ok := srv.getCachedFeed(site)
+	if !ok {
+		http.Error(w, "Feed is not cached yet", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	preview := map[string]interface{}{"Site": site, "Name": config.Name, "Feed": fc}
+	if err := dashboardTemplate.Execute(w, map[string]interface{}{"Preview": preview, "Base": srv.requestBasePath(r)}); err != nil {
+		slog.Error("Failed to render preview", "site", site, "error", err)
+	}
+}
+
+// 暂停或恢复网站：POST /admin/disable?site=x&disabled=true|false
+func (srv *Server) disableHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	site := r.URL.Query().Get("site")
+	if _, ok := srv.getSiteConfig(site); !ok {
+		http.Error(w, fmt.Sprintf("Unknown site: %s", site), http.StatusNotFound)
+		return
+	}
+	disabled := true
+	if v := r.URL.Query().Get("disabled"); v != "" {
+		var err error
+		if disabled, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "Invalid 'disabled' parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	srv.setSiteDisabled(site, disabled)
+	slog.Info("Changed site state", "site", site, "disabled", disabled)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"site": site, "disabled": disabled})
+}
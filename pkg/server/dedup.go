@@ -0,0 +1,156 @@
+package server
+
+import (
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 折叠大小写并去掉标点和空白，用于比较标题
+func titleKey(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// 基于字符二元组的 Dice 系数，返回 0~1 的相似度
+func titleSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) < 2 || len(rb) < 2 {
+		return 0
+	}
+
+	bigrams := make(map[[2]rune]int)
+	for i := 0; i < len(ra)-1; i++ {
+		bigrams[[2]rune{ra[i], ra[i+1]}]++
+	}
+
+	var common int
+	for i := 0; i < len(rb)-1; i++ {
+		k := [2]rune{rb[i], rb[i+1]}
+		if bigrams[k] > 0 {
+			bigrams[k]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(ra)+len(rb)-2)
+}
+
+// 去除标题相同或相近的条目，只保留第一次出现的条目。
+// threshold 为相似度阈值（0~1），0 表示只去除规范化后完全相同的标题
+func dedupItems(items []Item, threshold float64) ([]Item, int) {
+	var kept []Item
+	var keys []string
+	seen := make(map[string]bool)
+
+	for _, item := range items {
+		key := titleKey(item.Title)
+		if key == "" {
+			kept = append(kept, item)
+			continue
+		}
+
+		dup := seen[key]
+		if !dup && threshold > 0 {
+			for _, k := range keys {
+				if titleSimilarity(key, k) >= threshold {
+					dup = true
+					break
+				}
+			}
+		}
+		if dup {
+			continue
+		}
+
+		seen[key] = true
+		keys = append(keys, key)
+		kept = append(kept, item)
+	}
+
+	return kept, len(items) - len(kept)
+}
+
+// 链接跟踪参数，比较链接时忽略
+var trackingParams = []string{"utm_", "fbclid", "gclid", "mc_cid", "mc_eid", "spm", "ref"}
+
+// 规范化链接，用于判断不同网站的条目是否指向同一篇文章：
+// 忽略协议、www. 前缀、片段、跟踪参数、参数顺序和末尾的 /
+func canonicalURL(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Host == "" {
+		return link
+	}
+	q := u.Query()
+	for k := range q {
+		for _, p := range trackingParams {
+			if k == p || strings.HasSuffix(p, "_") && strings.HasPrefix(k, p) {
+				q.Del(k)
+			}
+		}
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	key := host + strings.TrimSuffix(u.EscapedPath(), "/")
+	if len(q) > 0 {
+		key += "?" + q.Encode()
+	}
+	return key
+}
+
+// 合并订阅源中的重复条目：链接规范化后相同，或者发布时间相差不超过 mergeDedupWindow 且标题相同或相似度达到 threshold。
+// 保留最早发布的条目。items 按发布时间倒序排列，返回的条目保持原顺序
+func (srv *Server) dedupMerged(items []Item, threshold float64) ([]Item, int) {
+	type seenItem struct {
+		key string
+		at  time.Time
+	}
+	drop := make([]bool, len(items))
+	links := make(map[string]bool)
+	var seen []seenItem
+
+	// 从最早的条目开始，先出现的保留
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		link := ""
+		if item.Link != "" {
+			link = canonicalURL(item.Link)
+		}
+		key := titleKey(item.Title)
+		at, _ := time.ParseInLocation(pubDateLayout, item.PubDate, time.Local)
+
+		dup := link != "" && links[link]
+		if !dup && key != "" {
+			// seen 按发布时间升序，只需要比较窗口内的条目
+			for j := len(seen) - 1; j >= 0 && at.Sub(seen[j].at) <= srv.cfg.MergeDedupWindow; j-- {
+				if key == seen[j].key || threshold < 1 && titleSimilarity(key, seen[j].key) >= threshold {
+					dup = true
+					break
+				}
+			}
+		}
+		drop[i] = dup
+		// 被去掉的条目的链接和标题同样记录，转载的转载也能识别
+		if link != "" {
+			links[link] = true
+		}
+		if key != "" {
+			seen = append(seen, seenItem{key, at})
+		}
+	}
+
+	out := items[:0:0]
+	for i, item := range items {
+		if !drop[i] {
+			out = append(out, item)
+		}
+	}
+	return out, len(items) - len(out)
+}
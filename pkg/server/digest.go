@@ -0,0 +1,338 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html"
+	"html/template"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"rss-zhuaqu/pkg/scraper"
+)
+
+// 邮件摘要：收集新条目，按计划（每天、每周）把 HTML 摘要通过 SMTP 发送到邮箱
+
+// 每个收件人最多保留的条目数，超过时丢弃最早的
+const digestMaxItems = 500
+
+// 摘要中的一个条目
+type digestItem struct {
+	Site     string
+	SiteName string
+	Title    string
+	Link     string
+	Summary  string `json:",omitempty"`
+	Added    time.Time
+}
+
+func (srv *Server) digestEnabled() bool {
+	return len(srv.cfg.DigestRecipients) > 0
+}
+
+// 新条目加入匹配的收件人的摘要，不直接投递
+func (srv *Server) digestSink(site string, config SiteConfig, items []Item) []delivery {
+	if !srv.digestEnabled() {
+		return nil
+	}
+	name := config.Name
+	if name == "" {
+		name = site
+	}
+	now := time.Now()
+	var entries []digestItem
+	for _, item := range items {
+		entries = append(entries, digestItem{
+			Site:     site,
+			SiteName: name,
+			Title:    item.Title,
+			Link:     item.Link,
+			Summary:  scraper.TruncateText(plainText(item.Description), 200),
+			Added:    now,
+		})
+	}
+
+	srv.digestMu.Lock()
+	defer srv.digestMu.Unlock()
+	for _, route := range srv.cfg.DigestRecipients {
+		if !route.Filter.match(site, config) {
+			continue
+		}
+		pending := append(srv.digestPending[route.Target], entries...)
+		if len(pending) > digestMaxItems {
+			pending = pending[len(pending)-digestMaxItems:]
+		}
+		srv.digestPending[route.Target] = pending
+	}
+	return nil
+}
+
+// HTML 转为纯文本
+func plainText(s string) string {
+	if !strings.Contains(s, "<") {
+		return scraper.NormalizeText(html.UnescapeString(s))
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(s))
+	if err != nil {
+		return scraper.NormalizeText(html.UnescapeString(s))
+	}
+	return scraper.NormalizeText(doc.Text())
+}
+
+// 取出所有等待发送的条目
+func (srv *Server) takeDigests() map[string][]digestItem {
+	srv.digestMu.Lock()
+	defer srv.digestMu.Unlock()
+	pending := srv.digestPending
+	srv.digestPending = make(map[string][]digestItem)
+	return pending
+}
+
+// 检查点中保存的等待发送的条目
+func (srv *Server) pendingDigests() map[string][]digestItem {
+	srv.digestMu.Lock()
+	defer srv.digestMu.Unlock()
+	if len(srv.digestPending) == 0 {
+		return nil
+	}
+	pending := make(map[string][]digestItem, len(srv.digestPending))
+	for to, items := range srv.digestPending {
+		pending[to] = append([]digestItem(nil), items...)
+	}
+	return pending
+}
+
+func (srv *Server) restoreDigests(pending map[string][]digestItem) {
+	srv.digestMu.Lock()
+	defer srv.digestMu.Unlock()
+	for to, items := range pending {
+		srv.digestPending[to] = append(items, srv.digestPending[to]...)
+	}
+}
+
+// 检查 -digest-schedule 和 -digest-at
+func (srv *Server) validateDigestSchedule() error {
+	switch srv.cfg.DigestSchedule {
+	case "daily", "weekly":
+		if _, err := time.Parse("15:04", srv.cfg.DigestAt); err != nil {
+			return fmt.Errorf("invalid -digest-at %q, expected HH:MM", srv.cfg.DigestAt)
+		}
+		return nil
+	}
+	if d, err := time.ParseDuration(srv.cfg.DigestSchedule); err != nil || d < time.Minute {
+		return fmt.Errorf("invalid -digest-schedule %q, expected daily, weekly or a duration of at least 1m", srv.cfg.DigestSchedule)
+	}
+	return nil
+}
+
+// 下一次发送摘要的时间
+func (srv *Server) nextDigest(now time.Time) time.Time {
+	if d, err := time.ParseDuration(srv.cfg.DigestSchedule); err == nil {
+		return now.Add(d)
+	}
+	at, _ := time.Parse("15:04", srv.cfg.DigestAt)
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	for !next.After(now) || (srv.cfg.DigestSchedule == "weekly" && next.Weekday() != time.Monday) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// 按计划发送摘要
+func (srv *Server) startDigester() {
+	if !srv.digestEnabled() {
+		return
+	}
+	go func() {
+		for {
+			next := srv.nextDigest(time.Now())
+			srv.digestMu.Lock()
+			srv.digestNextAt = next
+			srv.digestMu.Unlock()
+			slog.Debug("Next email digest scheduled", "at", next)
+			select {
+			case <-time.After(time.Until(next)):
+				srv.sendDigests()
+			case <-srv.appCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// 为每个有新条目的收件人生成摘要邮件，交给推送队列发送
+func (srv *Server) sendDigests() int {
+	pending := srv.takeDigests()
+	for to, items := range pending {
+		msg, err := srv.digestMessage(to, items)
+		if err != nil {
+			slog.Error("Failed to build email digest", "to", to, "error", err)
+			continue
+		}
+		to := to
+		srv.enqueueDelivery(delivery{
+			sink:   "email",
+			target: to,
+			send: func(ctx context.Context) error {
+				return srv.sendMail(ctx, to, msg)
+			},
+		})
+	}
+	return len(pending)
+}
+
+// 摘要按网站分组
+type digestGroup struct {
+	SiteName string
+	Items    []digestItem
+}
+
+var digestTemplate = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Subject}}</title></head>
+<body style="font-family:sans-serif;max-width:640px;margin:auto">
+<h1 style="font-size:20px">{{.Subject}}</h1>
+{{range .Groups}}<h2 style="font-size:16px;border-bottom:1px solid #ddd">{{.SiteName}}</h2>
+<ul style="padding-left:20px">
+{{range .Items}}<li style="margin-bottom:8px"><a href="{{.Link}}">{{if .Title}}{{.Title}}{{else}}{{.Link}}{{end}}</a>{{if .Summary}}<br><span style="color:#555;font-size:13px">{{.Summary}}</span>{{end}}</li>
+{{end}}</ul>
+{{end}}</body></html>
+`))
+
+// 生成摘要邮件（HTML，quoted-printable 编码）
+func (srv *Server) digestMessage(to string, items []digestItem) ([]byte, error) {
+	bySite := make(map[string]*digestGroup)
+	var groups []*digestGroup
+	for _, item := range items {
+		g := bySite[item.Site]
+		if g == nil {
+			g = &digestGroup{SiteName: item.SiteName}
+			bySite[item.Site] = g
+			groups = append(groups, g)
+		}
+		g.Items = append(g.Items, item)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].SiteName < groups[j].SiteName })
+
+	subject := fmt.Sprintf("RSS digest: %d new items from %d sites", len(items), len(groups))
+	var body bytes.Buffer
+	if err := digestTemplate.Execute(&body, map[string]interface{}{"Subject": subject, "Groups": groups}); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", srv.cfg.SMTPFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@%s>\r\n", randomID(), mailDomain(srv.cfg.SMTPFrom))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&msg)
+	qp.Write(body.Bytes())
+	qp.Close()
+	return msg.Bytes(), nil
+}
+
+func mailDomain(addr string) string {
+	_, domain, ok := strings.Cut(strings.Trim(addr, "<> "), "@")
+	if !ok {
+		return "localhost"
+	}
+	return strings.TrimRight(domain, ">")
+}
+
+// 通过 -smtp-addr 发送邮件：465 端口直接使用 TLS，其他端口在服务器支持时使用 STARTTLS
+func (srv *Server) sendMail(ctx context.Context, to string, msg []byte) error {
+	host, port, err := net.SplitHostPort(srv.cfg.SMTPAddr)
+	if err != nil {
+		return err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", srv.cfg.SMTPAddr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: host}
+	if port == "465" {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok && port != "465" {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if srv.cfg.SMTPUser != "" {
+		if err := c.Auth(smtp.PlainAuth("", srv.cfg.SMTPUser, srv.cfg.SMTPPassword, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(addressOnly(srv.cfg.SMTPFrom)); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// "Name <a@b.c>" 中的地址
+func addressOnly(addr string) string {
+	if i := strings.LastIndex(addr, "<"); i >= 0 {
+		return strings.TrimSuffix(addr[i+1:], ">")
+	}
+	return strings.TrimSpace(addr)
+}
+
+// /admin/digest：POST 立即发送等待中的摘要，GET 返回每个收件人等待发送的条目数
+func (srv *Server) digestHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		counts := make(map[string]int)
+		for to, items := range srv.pendingDigests() {
+			counts[to] = len(items)
+		}
+		srv.digestMu.Lock()
+		next := srv.digestNextAt
+		srv.digestMu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"pending": counts, "next": next})
+	case http.MethodPost:
+		sent := srv.sendDigests()
+		slog.Info("Email digests sent on request", "recipients", sent)
+		writeJSON(w, http.StatusOK, map[string]int{"recipients": sent})
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"rss-zhuaqu/pkg/scraper"
+)
+
+// 错误响应中的错误码，客户端和监控可以按错误码区分问题，不必解析错误信息
+const (
+	ErrCodeBadRequest         = "BAD_REQUEST"
+	ErrCodeUnknownFormat      = "UNKNOWN_FORMAT"
+	ErrCodeSiteNotFound       = "SITE_NOT_FOUND"
+	ErrCodeSiteDisabled       = "SITE_DISABLED"
+	ErrCodeUnauthorized       = "UNAUTHORIZED"
+	ErrCodeTokenRequired      = "TOKEN_REQUIRED"
+	ErrCodeNotCached          = "NOT_CACHED"
+	ErrCodeFeedTooStale       = "FEED_TOO_STALE"
+	ErrCodeMaintenance        = "MAINTENANCE"
+	ErrCodeUpstreamTimeout    = "UPSTREAM_TIMEOUT"
+	ErrCodeUpstreamDNS        = "UPSTREAM_DNS"
+	ErrCodeUpstreamConnection = "UPSTREAM_CONNECTION"
+	ErrCodeUpstreamStatus     = "UPSTREAM_STATUS"
+	ErrCodeParseEmpty         = "PARSE_EMPTY"
+	ErrCodeFetchFailed        = "FETCH_FAILED"
+	ErrCodeStorage            = "STORAGE_ERROR"
+	ErrCodeEncodeFailed       = "ENCODE_FAILED"
+	ErrCodeRequestTimeout     = "REQUEST_TIMEOUT"
+	ErrCodeMethodNotAllowed   = "METHOD_NOT_ALLOWED"
+	ErrCodeNotFound           = "NOT_FOUND"
+	ErrCodeConflict           = "CONFLICT"
+	ErrCodeForbidden          = "FORBIDDEN"
+	ErrCodeAdminUnauthorized  = "ADMIN_UNAUTHORIZED"
+	ErrCodeReaderUnauthorized = "READER_UNAUTHORIZED"
+	ErrCodeInternal           = "INTERNAL_ERROR"
+)
+
+// 各错误码的排查提示
+var errorHints = map[string]string{
+	ErrCodeBadRequest:         "Check the query parameters against the README.",
+	ErrCodeUnknownFormat:      "Use .xml, .rss, .atom or .json, or format=rss|atom|json.",
+	ErrCodeSiteNotFound:       "The site name is case-sensitive; GET /sites lists the configured sites.",
+	ErrCodeSiteDisabled:       "The site was paused on the admin dashboard and has no cached feed.",
+	ErrCodeUnauthorized:       "Send the Basic auth credentials configured for this feed.",
+	ErrCodeTokenRequired:      "Add a feed token (token=...) or a signed URL created with POST /admin/tokens?id=.",
+	ErrCodeNotCached:          "Another instance refreshes this feed; retry after the Retry-After delay.",
+	ErrCodeFeedTooStale:       "A refresh has been started; retry after the Retry-After delay.",
+	ErrCodeMaintenance:        "The service is in maintenance mode and only serves cached feeds; retry later.",
+	ErrCodeUpstreamTimeout:    "The site did not respond in time; it may be down or slow. Try raising -request-timeout.",
+	ErrCodeUpstreamDNS:        "The site's host name could not be resolved; check the site URL.",
+	ErrCodeUpstreamConnection: "The connection to the site failed; check the site URL and the network.",
+	ErrCodeUpstreamStatus:     "The site returned an HTTP error; the page may have moved or the scraper may be blocked.",
+	ErrCodeParseEmpty:         "The selectors matched no items; the page layout has probably changed. Check them with /admin/preview.",
+	ErrCodeFetchFailed:        "See the server log for this request ID.",
+	ErrCodeStorage:            "The item storage failed; see the server log for this request ID.",
+	ErrCodeEncodeFailed:       "See the server log for this request ID.",
+	ErrCodeRequestTimeout:     "The request took longer than -request-timeout, usually while fetching a site without a cached feed; retry later.",
+	ErrCodeAdminUnauthorized:  "Send the admin token in an Authorization: Bearer header.",
+	ErrCodeReaderUnauthorized: "Log in with the -reader-user and -reader-password credentials.",
+	ErrCodeInternal:           "See the server log for this request ID.",
+}
+
+// 抓取和生成订阅源时可以识别的错误
+var (
+	errSiteNotFound = errors.New("site configuration not found")
+	errParseEmpty   = errors.New("selectors matched no items")
+	errStorage      = errors.New("storage error")
+)
+
+// 抓取错误对应的错误码
+func fetchErrorCode(err error) string {
+	var statusErr *scraper.StatusError
+	switch {
+	case errors.Is(err, errSiteNotFound):
+		return ErrCodeSiteNotFound
+	case errors.Is(err, errParseEmpty):
+		return ErrCodeParseEmpty
+	case errors.Is(err, errStorage):
+		return ErrCodeStorage
+	case errors.As(err, &statusErr):
+		return ErrCodeUpstreamStatus
+	}
+	switch errorClass(err) {
+	case "timeout":
+		return ErrCodeUpstreamTimeout
+	case "dns":
+		return ErrCodeUpstreamDNS
+	case "connection":
+		return ErrCodeUpstreamConnection
+	}
+	return ErrCodeFetchFailed
+}
+
+// 抓取失败时的状态码：网站不存在为 404，超时为 504，网站本身的问题为 502，其余为 fallback
+func fetchErrorStatus(err error, fallback int) int {
+	return codeStatus(fetchErrorCode(err), fallback)
+}
+
+func codeStatus(code string, fallback int) int {
+	switch code {
+	case ErrCodeSiteNotFound:
+		return http.StatusNotFound
+	case ErrCodeUpstreamTimeout:
+		return http.StatusGatewayTimeout
+	case ErrCodeUpstreamDNS, ErrCodeUpstreamConnection, ErrCodeUpstreamStatus, ErrCodeParseEmpty:
+		return http.StatusBadGateway
+	}
+	return fallback
+}
+
+// 错误响应
+type errorBody struct {
+	XMLName   xml.Name `xml:"error" json:"-"`
+	Code      string   `xml:"code,attr" json:"code"`
+	Message   string   `xml:"message" json:"error"`
+	Hint      string   `xml:"hint,omitempty" json:"hint,omitempty"`
+	RequestID string   `xml:"request_id,omitempty" json:"request_id,omitempty"`
+}
+
+// 返回带错误码的错误响应。订阅源（RSS、Atom）请求返回 XML，开头的注释说明错误，
+// 在阅读器或浏览器中直接可读；其余请求返回 JSON
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	body := errorBody{Code: code, Message: message, Hint: errorHints[code], RequestID: requestID(r.Context())}
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("X-Error-Code", code)
+
+	if !wantsXMLError(r) {
+		h.Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+		return
+	}
+
+	data, _ := xml.MarshalIndent(body, "", "  ")
+	comment := fmt.Sprintf("Error %d %s: %s", status, code, message)
+	if body.Hint != "" {
+		comment += "\n  Hint: " + body.Hint
+	}
+	// XML 注释中不能出现 --
+	comment = strings.ReplaceAll(comment, "--", "- -")
+
+	h.Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s<!--\n  %s\n-->\n%s\n", xml.Header, comment, data)
+}
+
+// RSS、Atom 格式的订阅源请求（/rss、/rss/{file}、/feed/{file}，包括租户路径）
+func wantsXMLError(r *http.Request) bool {
+	p := r.URL.Path
+	feed := strings.HasSuffix(p, "/rss") || strings.Contains(p, "/rss/") || strings.Contains(p, "/feed/")
+	format, err := requestedFormat(r)
+	return feed && (err != nil || format != FormatJSON)
+}
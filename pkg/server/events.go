@@ -0,0 +1,232 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"rss-zhuaqu/pkg/feed"
+)
+
+// 保留最近的事件数，客户端断线重连时按 Last-Event-ID 补发
+const recentEventsSize = 1000
+
+// 事件流的心跳间隔，避免代理关闭空闲连接
+var eventKeepAlive = 30 * time.Second
+
+// 刷新时发现的新条目
+type itemEvent struct {
+	ID   int64
+	Site string
+	Item Item
+}
+
+// 事件流中发送的条目，字段同 JSON Feed
+type itemEventData struct {
+	Site string `json:"site"`
+	feed.JSONFeedItem
+}
+
+func (srv *Server) closeStreams() {
+	srv.closeStreamsOnce.Do(func() { close(srv.streamsClosing) })
+}
+
+// 返回 items 中不在 prev 里的条目（按 GUID）
+func newItems(prev, items []Item) []Item {
+	seen := make(map[string]bool, len(prev))
+	for _, item := range prev {
+		seen[item.GUID] = true
+	}
+	var added []Item
+	for _, item := range items {
+		if !seen[item.GUID] {
+			added = append(added, item)
+		}
+	}
+	return added
+}
+
+// 刷新后发现新条目，推送给事件流的订阅者和各推送方式
+func (srv *Server) notifyNewItems(site string, items []Item) {
+	slog.Debug("New items", "site", site, "items", len(items))
+	srv.broadcastItems(site, items)
+	srv.deliverNewItems(site, items)
+}
+
+func (srv *Server) broadcastItems(site string, items []Item) {
+	srv.eventsMu.Lock()
+	defer srv.eventsMu.Unlock()
+	for _, item := range items {
+		srv.eventSeq++
+		ev := itemEvent{ID: srv.eventSeq, Site: site, Item: item}
+		srv.recentEvents = append(srv.recentEvents, ev)
+		for ch, sites := range srv.eventSubs {
+			if !sites[site] {
+				continue
+			}
+			select {
+			case ch <- ev:
+			default:
+				// 订阅者处理不过来时断开，客户端重连后按 Last-Event-ID 补发
+				delete(srv.eventSubs, ch)
+				close(ch)
+			}
+		}
+	}
+	if n := len(srv.recentEvents) - recentEventsSize; n > 0 {
+		srv.recentEvents = slices.Delete(srv.recentEvents, 0, n)
+	}
+}
+
+// 订阅网站的新条目，lastID 不小于 0 时同时返回它之后的最近事件
+func (srv *Server) subscribeItems(sites map[string]bool, lastID int64) (chan itemEvent, []itemEvent) {
+	srv.eventsMu.Lock()
+	defer srv.eventsMu.Unlock()
+	ch := make(chan itemEvent, 64)
+	srv.eventSubs[ch] = sites
+	return ch, srv.missedEvents(sites, lastID)
+}
+
+// 修改订阅的网站，返回值同 subscribeItems。订阅已经因处理不过来被断开时不做修改
+func (srv *Server) resubscribeItems(ch chan itemEvent, sites map[string]bool, lastID int64) []itemEvent {
+	srv.eventsMu.Lock()
+	defer srv.eventsMu.Unlock()
+	if _, ok := srv.eventSubs[ch]; !ok {
+		return nil
+	}
+	srv.eventSubs[ch] = sites
+	return srv.missedEvents(sites, lastID)
+}
+
+// 最近事件中 lastID 之后属于 sites 的事件，调用时需持有 eventsMu
+func (srv *Server) missedEvents(sites map[string]bool, lastID int64) []itemEvent {
+	if lastID < 0 {
+		return nil
+	}
+	var missed []itemEvent
+	for _, ev := range srv.recentEvents {
+		if ev.ID > lastID && sites[ev.Site] {
+			missed = append(missed, ev)
+		}
+	}
+	return missed
+}
+
+func (srv *Server) unsubscribeItems(ch chan itemEvent) {
+	srv.eventsMu.Lock()
+	defer srv.eventsMu.Unlock()
+	if _, ok := srv.eventSubs[ch]; ok {
+		delete(srv.eventSubs, ch)
+		close(ch)
+	}
+}
+
+// 解析 site、sites 或 tag 参数指定的网站，不存在或没有权限时写入错误并返回 nil。
+// tag 只展开为有权限的网站
+func (srv *Server) eventSites(w http.ResponseWriter, r *http.Request) map[string]bool {
+	params := r.URL.Query()
+	var names []string
+	switch {
+	case params.Get("site") != "":
+		names = []string{params.Get("site")}
+	case params.Get("sites") != "":
+		names = splitList(params.Get("sites"))
+	case params.Get("tag") != "":
+		tag := params.Get("tag")
+		sites := make(map[string]bool)
+		for site, config := range srv.getAllSiteConfig() {
+			if slices.Contains(config.Tags, tag) && srv.feedAuthorized(r, site) {
+				sites[site] = true
+			}
+		}
+		if len(sites) == 0 {
+			http.Error(w, fmt.Sprintf("No sites with tag: %s", tag), http.StatusNotFound)
+			return nil
+		}
+		return sites
+	default:
+		http.Error(w, "Missing 'site', 'sites' or 'tag' parameter", http.StatusBadRequest)
+		return nil
+	}
+
+	sites := make(map[string]bool, len(names))
+	for _, site := range names {
+		if _, ok := srv.getSiteConfig(site); !ok {
+			http.Error(w, fmt.Sprintf("Unknown site: %s", site), http.StatusNotFound)
+			return nil
+		}
+		if !srv.checkFeedAuth(w, r, site) {
+			return nil
+		}
+		sites[site] = true
+	}
+	return sites
+}
+
+// GET /events?site=x（或 sites=a,b、tag=t）以 Server-Sent Events 推送刷新时发现的新条目
+func (srv *Server) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	sites := srv.eventSites(w, r)
+	if sites == nil {
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// 事件流是长连接，不受服务器读写超时限制
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	// 没有 Last-Event-ID 时不补发
+	lastID, err := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+	if err != nil {
+		lastID = -1
+	}
+	ch, missed := srv.subscribeItems(sites, lastID)
+	defer srv.unsubscribeItems(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", 5000)
+	for _, ev := range missed {
+		writeItemEvent(w, ev)
+	}
+	if err := rc.Flush(); err != nil {
+		slog.Warn("Event stream does not support flushing", "error", err)
+		return
+	}
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeItemEvent(w, ev)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		case <-srv.streamsClosing:
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeItemEvent(w http.ResponseWriter, ev itemEvent) {
+	data, err := json.Marshal(itemEventData{Site: ev.Site, JSONFeedItem: feed.NewJSONFeedItem(ev.Item)})
+	if err != nil {
+		slog.Error("Failed to encode item event", "site", ev.Site, "error", err)
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: item\ndata: %s\n\n", ev.ID, data)
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"time"
+)
+
+// 最近一次抓取失败
+type fetchFailure struct {
+	Err   string
+	Until time.Time
+	// 错误码，见 fetchErrorCode
+	Code string
+}
+
+// 记录抓取失败
+func (srv *Server) recordFailure(site string, err error) {
+	if srv.cfg.FailureTTL <= 0 {
+		return
+	}
+	srv.failuresLock.Lock()
+	srv.failures[site] = fetchFailure{
+		Err:   err.Error(),
+		Until: time.Now().Add(srv.cfg.FailureTTL),
+		Code:  fetchErrorCode(err),
+	}
+	srv.failuresLock.Unlock()
+}
+
+// 抓取成功后清除失败记录
+func (srv *Server) clearFailure(site string) {
+	srv.failuresLock.Lock()
+	delete(srv.failures, site)
+	srv.failuresLock.Unlock()
+}
+
+// 返回仍在缓存期内的抓取失败
+func (srv *Server) recentFailure(site string) (fetchFailure, bool) {
+	srv.failuresLock.RLock()
+	f, ok := srv.failures[site]
+	srv.failuresLock.RUnlock()
+
+	if !ok || time.Now().After(f.Until) {
+		return fetchFailure{}, false
+	}
+	return f, true
+}
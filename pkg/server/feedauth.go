@@ -0,0 +1,59 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// 网站订阅源要求的 Basic 认证，网站配置的 BasicAuth 优先于 -feed-auth
+func (srv *Server) siteFeedAuth(site string) string {
+	if config, ok := srv.getSiteConfig(site); ok && config.BasicAuth != "" {
+		return config.BasicAuth
+	}
+	return srv.cfg.FeedAuth
+}
+
+// 订阅源是否需要认证（Basic 认证或令牌），租户的网站总是需要该租户的密钥
+func (srv *Server) feedProtected(site string) bool {
+	if _, _, ok := splitTenantSite(site); ok {
+		return true
+	}
+	return srv.siteFeedAuth(site) != "" || srv.siteRequiresToken(site)
+}
+
+// 校验订阅源的访问权限：有效的令牌或签名地址，或者正确的 Basic 认证。
+// 不通过时返回 401（需要 Basic 认证时）或 403，并返回 false
+func (srv *Server) checkFeedAuth(w http.ResponseWriter, r *http.Request, site string) bool {
+	if srv.feedAuthorized(r, site) {
+		return true
+	}
+	if srv.siteFeedAuth(site) == "" {
+		writeError(w, r, http.StatusForbidden, ErrCodeTokenRequired, "A valid feed token is required")
+		return false
+	}
+	w.Header().Set("WWW-Authenticate", `Basic realm="`+site+`", charset="UTF-8"`)
+	writeError(w, r, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
+	return false
+}
+
+// 同 checkFeedAuth，只返回是否有权限
+func (srv *Server) feedAuthorized(r *http.Request, site string) bool {
+	if id, _, ok := splitTenantSite(site); ok {
+		return srv.requestTenant(r) == id
+	}
+	if !srv.feedProtected(site) || srv.validFeedToken(r, site) {
+		return true
+	}
+
+	want := srv.siteFeedAuth(site)
+	if want == "" {
+		return false
+	}
+	wantUser, wantPassword, _ := strings.Cut(want, ":")
+
+	user, password, ok := r.BasicAuth()
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(wantUser)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(wantPassword)) == 1
+	return ok && userOK && passwordOK
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// 订阅源地址扩展名对应的输出格式
+var feedExtensions = map[string]string{
+	".xml":  FormatRSS,
+	".rss":  FormatRSS,
+	".atom": FormatAtom,
+	".json": FormatJSON,
+}
+
+// 处理 /rss/{site}.xml、/feed/{site}.json 等带扩展名的固定地址，
+// 转换为 site 和 format 参数后交给 /rss 处理，其余查询参数保持不变
+func (srv *Server) feedPathHandler(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	ext := path.Ext(file)
+	format, ok := feedExtensions[strings.ToLower(ext)]
+	site := strings.TrimSuffix(file, ext)
+	if !ok || site == "" {
+		writeError(w, r, http.StatusNotFound, ErrCodeUnknownFormat, "Unknown feed extension, expected .xml, .rss, .atom or .json")
+		return
+	}
+
+	q := r.URL.Query()
+	q.Del("sites")
+	q.Set("site", site)
+	q.Set("format", format)
+	r2 := r.Clone(r.Context())
+	r2.URL.RawQuery = q.Encode()
+	srv.generateRSSHandler(w, r2)
+}
+
+// 请求的订阅源网站：?site= 参数，或 /rss/{site}.xml 等固定地址中的网站名。
+// 用于在路由之前（如限流时）校验签名地址
+func requestFeedSite(r *http.Request) string {
+	if site := r.URL.Query().Get("site"); site != "" {
+		return site
+	}
+	for _, prefix := range []string{"/rss/", "/feed/"} {
+		if file, ok := strings.CutPrefix(r.URL.Path, prefix); ok && !strings.Contains(file, "/") {
+			return strings.TrimSuffix(file, path.Ext(file))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"rss-zhuaqu/pkg/feed"
+)
+
+// 订阅源的输出格式
+const (
+	FormatRSS  = feed.FormatRSS
+	FormatAtom = feed.FormatAtom
+	FormatJSON = feed.FormatJSON
+)
+
+var feedFormatTypes = feed.ContentTypes
+
+// 按查询参数调整订阅源：since 只保留此后发布的条目，limit 限制条目数，
+// fulltext=1 用文章正文替换摘要，lang 翻译标题和摘要。内容有变化时重新计算哈希
+func (srv *Server) shapeFeed(r *http.Request, sites []string, fc FeedCache) (FeedCache, error) {
+	params := r.URL.Query()
+	items := fc.Feed.Channel.Items
+	changed := false
+
+	if v := params.Get("since"); v != "" {
+		since, err := parseSince(v)
+		if err != nil {
+			return fc, fmt.Errorf("invalid 'since' parameter, expected YYYY-MM-DD or RFC 3339")
+		}
+		// 发布时间格式固定，按字符串比较即为时间顺序
+		cutoff := since.In(time.Local).Format(pubDateLayout)
+		var kept []Item
+		for _, item := range items {
+			if item.PubDate >= cutoff {
+				kept = append(kept, item)
+			}
+		}
+		items, changed = kept, true
+	}
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return fc, fmt.Errorf("invalid 'limit' parameter")
+		}
+		if n < len(items) {
+			items, changed = items[:n], true
+		}
+	}
+	if params.Get("fulltext") == "1" {
+		var selector string
+		if len(sites) == 1 {
+			config, _ := srv.getSiteConfig(sites[0])
+			selector = config.ContentSelector
+		}
+		items, changed = srv.withFullText(r.Context(), items, selector), true
+	}
+	if lang := params.Get("lang"); lang != "" {
+		if err := srv.checkTranslation(sites, lang); err != nil {
+			return fc, err
+		}
+		items, changed = srv.translateItems(r.Context(), items, lang), true
+	}
+
+	if changed {
+		fc.Feed.Channel.Items = items
+		fc.Hash = feedHash(fc.Feed)
+	}
+	return fc, nil
+}
+
+// Accept 请求头中可以协商的媒体类型
+var acceptFormats = map[string]string{
+	"application/rss+xml":   FormatRSS,
+	"application/atom+xml":  FormatAtom,
+	"application/feed+json": FormatJSON,
+	"application/json":      FormatJSON,
+}
+
+// 请求的输出格式：format 参数优先，没有时按 Accept 请求头协商，默认为 RSS
+func requestedFormat(r *http.Request) (string, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		return negotiateFormat(r.Header.Get("Accept")), nil
+	}
+	if _, ok := feedFormatTypes[format]; !ok {
+		return "", fmt.Errorf("invalid 'format' parameter, expected rss, atom or json")
+	}
+	return format, nil
+}
+
+// 选择 Accept 中 q 值最高的订阅源格式，q 值相同时取靠前的，都不支持时为 RSS
+func negotiateFormat(accept string) string {
+	format, best := FormatRSS, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		f, ok := acceptFormats[strings.ToLower(strings.TrimSpace(mediaType))]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if q, _ = strconv.ParseFloat(v, 64); q < 0 || q > 1 {
+					q = 0
+				}
+			}
+		}
+		if q > best {
+			format, best = f, q
+		}
+	}
+	return format
+}
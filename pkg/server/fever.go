@@ -0,0 +1,280 @@
+package server
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fever API（https://feedafever.com/api），Reeder、ReadKit 等阅读器可以直接用本服务同步。
+// 每个网站是一个订阅源，每个标签是一个分组，条目来自历史条目存储
+
+// 每次返回的最大条目数，与 Fever 相同
+const feverItemLimit = 50
+
+// /fever/?api：认证参数 api_key = md5("<用户名>:<密码>")
+func (srv *Server) feverHandler(w http.ResponseWriter, r *http.Request) {
+	if !srv.readerEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	resp := map[string]interface{}{"api_version": 3, "auth": 0}
+	if !srv.feverAuthorized(r.Form.Get("api_key")) {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	resp["auth"] = 1
+	resp["last_refreshed_on_time"] = srv.feverLastRefreshed()
+
+	if err := srv.feverMark(r); err != nil {
+		slog.Error("Failed to update reader state", "file", srv.cfg.ReaderStateFile, "error", err)
+		http.Error(w, "Failed to update state", http.StatusInternalServerError)
+		return
+	}
+
+	_, groups := r.Form["groups"]
+	_, feeds := r.Form["feeds"]
+	if groups || feeds {
+		resp["feeds_groups"] = srv.feverFeedsGroups()
+	}
+	if groups {
+		resp["groups"] = srv.feverGroups()
+	}
+	if feeds {
+		resp["feeds"] = srv.feverFeeds(r)
+	}
+	if _, ok := r.Form["favicons"]; ok {
+		resp["favicons"] = []interface{}{}
+	}
+	if _, ok := r.Form["links"]; ok {
+		resp["links"] = []interface{}{}
+	}
+
+	_, items := r.Form["items"]
+	_, unread := r.Form["unread_item_ids"]
+	_, saved := r.Form["saved_item_ids"]
+	if items || unread || saved {
+		all, err := srv.allReaderItems()
+		if err != nil {
+			slog.Error("Failed to query items", "error", err)
+			http.Error(w, "Failed to query items", http.StatusInternalServerError)
+			return
+		}
+		if items {
+			resp["total_items"] = len(all)
+			resp["items"] = srv.feverItems(r, all)
+		}
+		if unread {
+			resp["unread_item_ids"] = feverIDList(all, func(id int64) bool { return !srv.itemRead(id) })
+		}
+		if saved {
+			resp["saved_item_ids"] = feverIDList(all, srv.itemSaved)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (srv *Server) feverAuthorized(key string) bool {
+	sum := md5.Sum([]byte(srv.cfg.ReaderUser + ":" + srv.cfg.ReaderPassword))
+	want := hex.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(strings.ToLower(key)), []byte(want)) == 1
+}
+
+// 所有网站中最近一次刷新的时间
+func (srv *Server) feverLastRefreshed() int64 {
+	var last time.Time
+	for site := range srv.readerSiteConfigs() {
+		if fc, ok := srv.getCachedFeed(site); ok && fc.UpdatedAt.After(last) {
+			last = fc.UpdatedAt
+		}
+	}
+	if last.IsZero() {
+		return 0
+	}
+	return last.Unix()
+}
+
+func (srv *Server) feverGroups() []map[string]interface{} {
+	groups := []map[string]interface{}{}
+	for _, tag := range srv.readerTags() {
+		groups = append(groups, map[string]interface{}{"id": readerNameID(tag), "title": tag})
+	}
+	return groups
+}
+
+func (srv *Server) feverFeedsGroups() []map[string]interface{} {
+	configs := srv.readerSiteConfigs()
+	groups := []map[string]interface{}{}
+	for _, tag := range srv.readerTags() {
+		var ids []string
+		for site, config := range configs {
+			if slices.Contains(config.Tags, tag) {
+				ids = append(ids, strconv.FormatInt(readerNameID(site), 10))
+			}
+		}
+		sort.Strings(ids)
+		groups = append(groups, map[string]interface{}{"group_id": readerNameID(tag), "feed_ids": strings.Join(ids, ",")})
+	}
+	return groups
+}
+
+func (srv *Server) feverFeeds(r *http.Request) []map[string]interface{} {
+	configs := srv.readerSiteConfigs()
+	sites := make([]string, 0, len(configs))
+	for site := range configs {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+
+	base := srv.requestBaseURL(r)
+	feeds := []map[string]interface{}{}
+	for _, site := range sites {
+		var updated int64
+		if fc, ok := srv.getCachedFeed(site); ok {
+			updated = fc.UpdatedAt.Unix()
+		}
+		feeds = append(feeds, map[string]interface{}{
+			"id":                   readerNameID(site),
+			"favicon_id":           0,
+			"title":                configs[site].Name,
+			"url":                  siteFeedURLs(base, site)[FormatRSS],
+			"site_url":             configs[site].URL,
+			"is_spark":             0,
+			"last_updated_on_time": updated,
+		})
+	}
+	return feeds
+}
+
+// 按 since_id（从旧到新）、max_id（从新到旧）或 with_ids 返回最多 50 个条目，
+// 没有参数时返回最新的条目
+func (srv *Server) feverItems(r *http.Request, all []readerItem) []map[string]interface{} {
+	var selected []readerItem
+	switch {
+	case r.Form.Get("with_ids") != "":
+		want := make(map[int64]bool)
+		for _, s := range strings.Split(r.Form.Get("with_ids"), ",") {
+			if id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil {
+				want[id] = true
+			}
+		}
+		for _, item := range all {
+			if want[item.ID] {
+				selected = append(selected, item)
+			}
+		}
+	case r.Form.Get("since_id") != "":
+		since, _ := strconv.ParseInt(r.Form.Get("since_id"), 10, 64)
+		for _, item := range all {
+			if item.ID > since {
+				selected = append(selected, item)
+			}
+		}
+	default:
+		maxID, err := strconv.ParseInt(r.Form.Get("max_id"), 10, 64)
+		for i := len(all) - 1; i >= 0; i-- {
+			if err != nil || maxID <= 0 || all[i].ID < maxID {
+				selected = append(selected, all[i])
+			}
+		}
+	}
+	if len(selected) > feverItemLimit {
+		selected = selected[:feverItemLimit]
+	}
+
+	items := make([]map[string]interface{}, 0, len(selected))
+	for _, item := range selected {
+		items = append(items, map[string]interface{}{
+			"id":              item.ID,
+			"feed_id":         readerNameID(item.Site),
+			"title":           item.Item.Title,
+			"author":          "",
+			"html":            item.Item.Description,
+			"url":             item.Item.Link,
+			"is_saved":        feverBool(srv.itemSaved(item.ID)),
+			"is_read":         feverBool(srv.itemRead(item.ID)),
+			"created_on_time": item.FirstSeen,
+		})
+	}
+	return items
+}
+
+func feverBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func feverIDList(all []readerItem, match func(id int64) bool) string {
+	var ids []string
+	for _, item := range all {
+		if match(item.ID) {
+			ids = append(ids, strconv.FormatInt(item.ID, 10))
+		}
+	}
+	return strings.Join(ids, ",")
+}
+
+// 处理 mark=item|feed|group 请求：
+// item 可以标记为 read、unread、saved、unsaved；feed 和 group 只能标记为已读，
+// 只标记首次出现时间早于 before 的条目，group 为 0 时表示所有网站
+func (srv *Server) feverMark(r *http.Request) error {
+	mark, as := r.Form.Get("mark"), r.Form.Get("as")
+	if mark == "" {
+		return nil
+	}
+	id, err := strconv.ParseInt(r.Form.Get("id"), 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	if mark == "item" {
+		set := map[string]func(readerState, int64){
+			"read": markRead, "unread": markUnread, "saved": markSaved, "unsaved": markUnsaved,
+		}[as]
+		if set == nil {
+			return nil
+		}
+		return srv.setItemState(set, id)
+	}
+
+	if as != "read" || (mark != "feed" && mark != "group") {
+		return nil
+	}
+	before, err := strconv.ParseInt(r.Form.Get("before"), 10, 64)
+	if err != nil {
+		before = time.Now().Unix()
+	}
+	all, err := srv.allReaderItems()
+	if err != nil {
+		return err
+	}
+	configs := srv.readerSiteConfigs()
+	var ids []int64
+	for _, item := range all {
+		if item.FirstSeen >= before {
+			continue
+		}
+		match := readerNameID(item.Site) == id
+		if mark == "group" {
+			match = id == 0 || slices.ContainsFunc(configs[item.Site].Tags, func(tag string) bool { return readerNameID(tag) == id })
+		}
+		if match {
+			ids = append(ids, item.ID)
+		}
+	}
+	return srv.setItemState(markRead, ids...)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"strings"
+
+	"rss-zhuaqu/pkg/scraper"
+)
+
+// 可配置为必填的字段
+const (
+	FieldTitle       = scraper.FieldTitle
+	FieldLink        = scraper.FieldLink
+	FieldDescription = scraper.FieldDescription
+	FieldDate        = scraper.FieldDate
+	FieldImage       = scraper.FieldImage
+)
+
+// 未配置 RequiredFields 时的默认必填字段
+var defaultRequiredFields = []string{FieldTitle, FieldLink}
+
+// 网站配置的必填字段集合
+func requiredFields(config SiteConfig) map[string]bool {
+	fields := config.RequiredFields
+	if len(fields) == 0 {
+		fields = defaultRequiredFields
+	}
+
+	required := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		required[strings.ToLower(f)] = true
+	}
+	return required
+}
+
+// 检查条目的必填字段，返回跳过原因，为空表示条目有效
+func checkRequiredFields(required map[string]bool, values map[string]string) string {
+	for _, f := range []string{FieldTitle, FieldLink, FieldDescription, FieldDate, FieldImage} {
+		if required[f] && values[f] == "" {
+			return "missing " + f
+		}
+	}
+	// 即使都是可选字段，标题和链接至少要有一个
+	if values[FieldTitle] == "" && values[FieldLink] == "" {
+		return "empty item"
+	}
+	return ""
+}
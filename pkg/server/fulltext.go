@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// 全文模式：抓取条目链接指向的文章页，用正文替换摘要。
+// 没有配置 ContentSelector 时依次尝试这些选择器
+var defaultContentSelectors = []string{"article", "main", ".post-content", ".entry-content", "#content", "body"}
+
+const (
+	// 全文缓存的有效期和最多缓存的文章数
+	fullTextTTL        = 24 * time.Hour
+	fullTextCacheLimit = 1000
+	// 同时抓取的文章页数
+	fullTextConcurrency = 4
+)
+
+type fullTextEntry struct {
+	html      string
+	fetchedAt time.Time
+}
+
+func (srv *Server) cachedFullText(link string) (string, bool) {
+	srv.fullTextCacheMu.Lock()
+	defer srv.fullTextCacheMu.Unlock()
+	e, ok := srv.fullTextCache[link]
+	if !ok || time.Since(e.fetchedAt) > fullTextTTL {
+		return "", false
+	}
+	return e.html, true
+}
+
+// 缓存文章正文，超过上限时淘汰最早抓取的文章
+func (srv *Server) storeFullText(link, html string) {
+	srv.fullTextCacheMu.Lock()
+	defer srv.fullTextCacheMu.Unlock()
+	srv.fullTextCache[link] = fullTextEntry{html: html, fetchedAt: time.Now()}
+	for len(srv.fullTextCache) > fullTextCacheLimit {
+		var oldest string
+		var oldestAt time.Time
+		for l, e := range srv.fullTextCache {
+			if oldest == "" || e.fetchedAt.Before(oldestAt) {
+				oldest, oldestAt = l, e.fetchedAt
+			}
+		}
+		delete(srv.fullTextCache, oldest)
+	}
+}
+
+// 抓取文章页并提取正文 HTML
+func (srv *Server) fetchFullText(ctx context.Context, link, selector string) (string, error) {
+	if html, ok := srv.cachedFullText(link); ok {
+		return html, nil
+	}
+	doc, err := srv.fetchDocument(ctx, link)
+	if err != nil {
+		return "", err
+	}
+
+	selectors := defaultContentSelectors
+	if selector != "" {
+		selectors = []string{selector}
+	}
+	var html string
+	for _, sel := range selectors {
+		if s := doc.Find(sel).First(); s.Length() > 0 {
+			s.Find("script, style, noscript").Remove()
+			absolutizeURLs(s, doc)
+			html, _ = s.Html()
+			break
+		}
+	}
+	srv.storeFullText(link, html)
+	return html, nil
+}
+
+// 将正文中图片和链接的相对地址转为绝对地址
+func absolutizeURLs(s *goquery.Selection, doc *goquery.Document) {
+	for attr, sel := range map[string]string{"src": "img[src]", "href": "a[href]"} {
+		s.Find(sel).Each(func(_ int, el *goquery.Selection) {
+			v, _ := el.Attr(attr)
+			if u, err := doc.Url.Parse(v); err == nil {
+				el.SetAttr(attr, u.String())
+			}
+		})
+	}
+}
+
+// 用文章正文替换条目的摘要，抓取失败的条目保留原摘要
+func (srv *Server) withFullText(ctx context.Context, items []Item, selector string) []Item {
+	out := make([]Item, len(items))
+	copy(out, items)
+
+	sem := make(chan struct{}, fullTextConcurrency)
+	var wg sync.WaitGroup
+	for i := range out {
+		if out[i].Link == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(item *Item) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			html, err := srv.fetchFullText(ctx, item.Link, selector)
+			if err != nil {
+				slog.Warn("Failed to fetch full text", "url", item.Link, "error", err, "error_class", errorClass(err))
+				return
+			}
+			if html != "" {
+				item.Description = html
+			}
+		}(&out[i])
+	}
+	wg.Wait()
+	return out
+}
@@ -0,0 +1,660 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// GraphQL 的最小实现：只支持查询，支持别名、参数、变量和嵌套字段，
+// 不支持片段、指令和内省（__typename 除外）。接口定义见 graphql_api.go
+
+// 查询中的字段
+type gqlField struct {
+	Alias      string
+	Name       string
+	Args       map[string]interface{}
+	Selections []*gqlField
+}
+
+func (f *gqlField) key() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// 变量定义
+type gqlVarDef struct {
+	Name    string
+	Type    string
+	Default interface{}
+	HasDef  bool
+}
+
+type gqlOperation struct {
+	Name       string
+	Vars       []gqlVarDef
+	Selections []*gqlField
+}
+
+// 查询中引用的变量，执行时替换为变量值
+type gqlVariable string
+
+// 枚举值，按字符串处理
+type gqlEnum string
+
+type gqlParser struct {
+	src string
+	pos int
+}
+
+func (p *gqlParser) errorf(format string, args ...interface{}) error {
+	line := 1 + strings.Count(p.src[:p.pos], "\n")
+	return fmt.Errorf("syntax error at line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// 跳过空白、逗号和注释
+func (p *gqlParser) skip() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case strings.HasPrefix(p.src[p.pos:], "\ufeff"):
+			p.pos += len("\ufeff")
+		default:
+			return
+		}
+	}
+}
+
+func (p *gqlParser) peek() byte {
+	p.skip()
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *gqlParser) expect(c byte) error {
+	if p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func (p *gqlParser) name() (string, error) {
+	if !isNameStart(p.peek()) {
+		return "", p.errorf("expected a name")
+	}
+	start := p.pos
+	for p.pos < len(p.src) && (isNameStart(p.src[p.pos]) || p.src[p.pos] >= '0' && p.src[p.pos] <= '9') {
+		p.pos++
+	}
+	return p.src[start:p.pos], nil
+}
+
+// 解析查询文档，返回 operationName 指定的操作（文档只有一个操作时可以省略）
+func parseGraphQL(src, operationName string) (*gqlOperation, error) {
+	p := &gqlParser{src: src}
+	var ops []*gqlOperation
+	for p.peek() != 0 {
+		op, err := p.operation()
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("no operation in query")
+	}
+	if operationName == "" {
+		if len(ops) > 1 {
+			return nil, fmt.Errorf("operationName is required when the query contains several operations")
+		}
+		return ops[0], nil
+	}
+	for _, op := range ops {
+		if op.Name == operationName {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", operationName)
+}
+
+func (p *gqlParser) operation() (*gqlOperation, error) {
+	op := &gqlOperation{}
+	if p.peek() != '{' {
+		keyword, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		switch keyword {
+		case "query":
+		case "fragment":
+			return nil, fmt.Errorf("fragments are not supported")
+		default:
+			return nil, fmt.Errorf("only queries are supported, got %q", keyword)
+		}
+		if isNameStart(p.peek()) {
+			if op.Name, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if p.peek() == '(' {
+			if op.Vars, err = p.varDefs(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if p.peek() == '@' {
+		return nil, fmt.Errorf("directives are not supported")
+	}
+	sels, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.Selections = sels
+	return op, nil
+}
+
+func (p *gqlParser) varDefs() ([]gqlVarDef, error) {
+	p.pos++ // (
+	var defs []gqlVarDef
+	for p.peek() != ')' {
+		if err := p.expect('$'); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		typ, err := p.typeRef()
+		if err != nil {
+			return nil, err
+		}
+		def := gqlVarDef{Name: name, Type: typ}
+		if p.peek() == '=' {
+			p.pos++
+			if def.Default, err = p.value(true); err != nil {
+				return nil, err
+			}
+			def.HasDef = true
+		}
+		defs = append(defs, def)
+	}
+	p.pos++ // )
+	return defs, nil
+}
+
+// 类型引用，如 String、[String!]!
+func (p *gqlParser) typeRef() (string, error) {
+	var typ string
+	if p.peek() == '[' {
+		p.pos++
+		inner, err := p.typeRef()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect(']'); err != nil {
+			return "", err
+		}
+		typ = "[" + inner + "]"
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		typ = name
+	}
+	if p.peek() == '!' {
+		p.pos++
+		typ += "!"
+	}
+	return typ, nil
+}
+
+func (p *gqlParser) selectionSet() ([]*gqlField, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+	var fields []*gqlField
+	for p.peek() != '}' {
+		if p.peek() == 0 {
+			return nil, p.errorf("unexpected end of query")
+		}
+		if strings.HasPrefix(p.src[p.pos:], "...") {
+			return nil, fmt.Errorf("fragments are not supported")
+		}
+		f, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	p.pos++ // }
+	if len(fields) == 0 {
+		return nil, p.errorf("empty selection set")
+	}
+	return fields, nil
+}
+
+func (p *gqlParser) field() (*gqlField, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	f := &gqlField{Name: name}
+	if p.peek() == ':' {
+		p.pos++
+		f.Alias = name
+		if f.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek() == '(' {
+		p.pos++
+		f.Args = make(map[string]interface{})
+		for p.peek() != ')' {
+			arg, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(':'); err != nil {
+				return nil, err
+			}
+			if f.Args[arg], err = p.value(false); err != nil {
+				return nil, err
+			}
+		}
+		p.pos++ // )
+	}
+	if p.peek() == '@' {
+		return nil, fmt.Errorf("directives are not supported")
+	}
+	if p.peek() == '{' {
+		if f.Selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// 解析参数值，constant 为 true 时不允许变量（用于变量默认值）
+func (p *gqlParser) value(constant bool) (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '$':
+		if constant {
+			return nil, p.errorf("variables are not allowed here")
+		}
+		p.pos++
+		name, err := p.name()
+		return gqlVariable(name), err
+	case c == '"':
+		return p.stringValue()
+	case c == '-' || c >= '0' && c <= '9':
+		return p.number()
+	case c == '[':
+		p.pos++
+		list := []interface{}{}
+		for p.peek() != ']' {
+			if p.peek() == 0 {
+				return nil, p.errorf("unexpected end of query")
+			}
+			v, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		p.pos++
+		return list, nil
+	case c == '{':
+		return nil, p.errorf("input objects are not supported")
+	case isNameStart(c):
+		name, _ := p.name()
+		switch name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return gqlEnum(name), nil
+	}
+	return nil, p.errorf("expected a value")
+}
+
+func (p *gqlParser) number() (interface{}, error) {
+	start := p.pos
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	isFloat := false
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '.' || c == 'e' || c == 'E' || (isFloat && (c == '+' || c == '-')) {
+			isFloat = true
+		} else if c < '0' || c > '9' {
+			break
+		}
+		p.pos++
+	}
+	text := p.src[start:p.pos]
+	if isFloat {
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", text)
+		}
+		return f, nil
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return nil, p.errorf("invalid number %q", text)
+	}
+	return n, nil
+}
+
+func (p *gqlParser) stringValue() (string, error) {
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		end := strings.Index(p.src[p.pos+3:], `"""`)
+		if end < 0 {
+			return "", p.errorf("unterminated block string")
+		}
+		s := p.src[p.pos+3 : p.pos+3+end]
+		p.pos += end + 6
+		return strings.TrimSpace(s), nil
+	}
+
+	p.pos++ // "
+	var sb strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return sb.String(), nil
+		case c == '\n':
+			return "", p.errorf("unterminated string")
+		case c == '\\' && p.pos+1 < len(p.src):
+			p.pos++
+			switch e := p.src[p.pos]; e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'u':
+				if p.pos+4 >= len(p.src) {
+					return "", p.errorf("invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(p.src[p.pos+1:p.pos+5], 16, 32)
+				if err != nil {
+					return "", p.errorf("invalid unicode escape")
+				}
+				sb.WriteRune(rune(r))
+				p.pos += 4
+			default:
+				sb.WriteByte(e)
+			}
+			p.pos++
+		default:
+			r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			sb.WriteRune(r)
+			p.pos += size
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// 执行结果中的对象，按查询中字段的顺序输出
+type gqlObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *gqlObject) set(key string, v interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = v
+}
+
+func (o *gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		buf.Write(key)
+		buf.WriteByte(':')
+		v, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// 字段解析函数，parent 为上一级对象的值
+type gqlResolver func(ctx *gqlContext, parent interface{}, args map[string]interface{}) (interface{}, error)
+
+// 字段定义：Type 为对象类型名时结果（或结果列表的每一项）继续按子字段解析，为空时是标量
+type gqlFieldDef struct {
+	Type    string
+	Args    map[string]string
+	Resolve gqlResolver
+}
+
+type gqlSchema map[string]map[string]gqlFieldDef
+
+// 执行中出现的错误，path 为出错字段的路径
+type gqlError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+type gqlContext struct {
+	schema gqlSchema
+	vars   map[string]interface{}
+	errors []gqlError
+	// 请求相关的数据，由接口自行使用
+	data interface{}
+}
+
+// 执行查询。字段出错时该字段为 null 并记录错误，其余字段照常返回
+func executeGraphQL(schema gqlSchema, op *gqlOperation, vars map[string]interface{}, data interface{}) (*gqlObject, []gqlError, error) {
+	resolved := make(map[string]interface{})
+	for _, def := range op.Vars {
+		v, ok := vars[def.Name]
+		if !ok && def.HasDef {
+			v, ok = def.Default, true
+		}
+		if !ok || v == nil {
+			if strings.HasSuffix(def.Type, "!") {
+				return nil, nil, fmt.Errorf("variable $%s of type %s is required", def.Name, def.Type)
+			}
+			continue
+		}
+		resolved[def.Name] = v
+	}
+
+	ctx := &gqlContext{schema: schema, vars: resolved, data: data}
+	if err := validateGraphQL(ctx, "Query", op.Selections, op.Vars); err != nil {
+		return nil, nil, err
+	}
+	result := ctx.selectFields("Query", nil, op.Selections, nil)
+	return result, ctx.errors, nil
+}
+
+// 执行前检查字段和参数是否存在，对象类型必须选择子字段
+func validateGraphQL(ctx *gqlContext, typ string, fields []*gqlField, vars []gqlVarDef) error {
+	for _, f := range fields {
+		if f.Name == "__typename" {
+			continue
+		}
+		def, ok := ctx.schema[typ][f.Name]
+		if !ok {
+			return fmt.Errorf("cannot query field %q on type %q", f.Name, typ)
+		}
+		for arg, v := range f.Args {
+			if _, ok := def.Args[arg]; !ok {
+				return fmt.Errorf("unknown argument %q on field %s.%s", arg, typ, f.Name)
+			}
+			if name, ok := v.(gqlVariable); ok && !hasVarDef(vars, string(name)) {
+				return fmt.Errorf("variable $%s is not defined", name)
+			}
+		}
+		for arg, argType := range def.Args {
+			if _, ok := f.Args[arg]; !ok && strings.HasSuffix(argType, "!") {
+				return fmt.Errorf("argument %q of type %s is required on field %s.%s", arg, argType, typ, f.Name)
+			}
+		}
+		switch {
+		case def.Type != "" && len(f.Selections) == 0:
+			return fmt.Errorf("field %s.%s of type %s must have a selection of subfields", typ, f.Name, def.Type)
+		case def.Type == "" && len(f.Selections) > 0:
+			return fmt.Errorf("field %s.%s is a scalar and cannot have subfields", typ, f.Name)
+		}
+		if def.Type != "" {
+			if err := validateGraphQL(ctx, def.Type, f.Selections, vars); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func hasVarDef(vars []gqlVarDef, name string) bool {
+	for _, v := range vars {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (ctx *gqlContext) selectFields(typ string, parent interface{}, fields []*gqlField, path []interface{}) *gqlObject {
+	obj := &gqlObject{values: make(map[string]interface{})}
+	for _, f := range fields {
+		if f.Name == "__typename" {
+			obj.set(f.key(), typ)
+			continue
+		}
+		fieldPath := append(path[:len(path):len(path)], f.key())
+		v, err := ctx.resolveField(typ, parent, f, fieldPath)
+		if err != nil {
+			ctx.errors = append(ctx.errors, gqlError{Message: err.Error(), Path: fieldPath})
+			v = nil
+		}
+		obj.set(f.key(), v)
+	}
+	return obj
+}
+
+func (ctx *gqlContext) resolveField(typ string, parent interface{}, f *gqlField, path []interface{}) (interface{}, error) {
+	def := ctx.schema[typ][f.Name]
+	args := make(map[string]interface{}, len(def.Args))
+	for name, argType := range def.Args {
+		raw, ok := f.Args[name]
+		if v, isVar := raw.(gqlVariable); isVar {
+			raw, ok = ctx.vars[string(v)]
+		}
+		if !ok || raw == nil {
+			continue
+		}
+		v, err := coerceGraphQLArg(raw, strings.TrimSuffix(argType, "!"))
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %v", name, err)
+		}
+		args[name] = v
+	}
+
+	v, err := def.Resolve(ctx, parent, args)
+	if err != nil || def.Type == "" || v == nil {
+		return v, err
+	}
+	// 对象列表逐项解析子字段
+	if list, ok := v.([]interface{}); ok {
+		out := make([]interface{}, len(list))
+		for i, item := range list {
+			out[i] = ctx.selectFields(def.Type, item, f.Selections, append(path[:len(path):len(path)], i))
+		}
+		return out, nil
+	}
+	return ctx.selectFields(def.Type, v, f.Selections, path), nil
+}
+
+// 按参数类型（String、Int、Boolean、[String]）转换参数值，
+// 变量中的数字来自 JSON，为 float64
+func coerceGraphQLArg(v interface{}, typ string) (interface{}, error) {
+	if strings.HasPrefix(typ, "[") {
+		inner := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(typ, "["), "]"), "!")
+		list, ok := v.([]interface{})
+		if !ok {
+			// 单个值视为只有一项的列表
+			list = []interface{}{v}
+		}
+		out := make([]interface{}, 0, len(list))
+		for _, item := range list {
+			c, err := coerceGraphQLArg(item, inner)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, c)
+		}
+		return out, nil
+	}
+
+	switch typ {
+	case "String":
+		switch s := v.(type) {
+		case string:
+			return s, nil
+		case gqlEnum:
+			return string(s), nil
+		}
+	case "Int":
+		switch n := v.(type) {
+		case int64:
+			if n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int(n), nil
+			}
+		case float64:
+			if n == math.Trunc(n) && n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int(n), nil
+			}
+		}
+	case "Boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("expected %s", typ)
+}
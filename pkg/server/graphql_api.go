@@ -0,0 +1,252 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 查询条目时的默认条数
+const defaultGraphQLItems = 50
+
+// 请求体的最大长度
+const maxGraphQLBody = 1 << 20
+
+// 没有参数、直接从上级对象取值的标量字段
+func gqlScalar(get func(parent interface{}) interface{}) gqlFieldDef {
+	return gqlFieldDef{Resolve: func(ctx *gqlContext, p interface{}, args map[string]interface{}) (interface{}, error) {
+		return get(p), nil
+	}}
+}
+
+// 按 JSON 字段名取值的标量字段，上级对象为可以序列化为 JSON 对象的值
+func gqlJSONFields(names ...string) map[string]gqlFieldDef {
+	fields := make(map[string]gqlFieldDef, len(names))
+	for _, name := range names {
+		name := name
+		fields[name] = gqlScalar(func(p interface{}) interface{} {
+			data, _ := json.Marshal(p)
+			var m map[string]interface{}
+			json.Unmarshal(data, &m)
+			return m[name]
+		})
+	}
+	return fields
+}
+
+// 条目及其所属网站
+type gqlItem struct {
+	Site string `json:"site"`
+	apiItem
+	firstSeen int64
+}
+
+func (srv *Server) gqlResolveSites(ctx *gqlContext, _ interface{}, args map[string]interface{}) (interface{}, error) {
+	tag, _ := args["tag"].(string)
+	var sites []string
+	for site, config := range srv.getAllSiteConfig() {
+		if (tag == "" || slices.Contains(config.Tags, tag)) && srv.siteVisible(ctx.data.(*http.Request), site) {
+			sites = append(sites, site)
+		}
+	}
+	sort.Strings(sites)
+	list := make([]interface{}, len(sites))
+	for i, site := range sites {
+		list[i] = site
+	}
+	return list, nil
+}
+
+func (srv *Server) gqlResolveSite(ctx *gqlContext, _ interface{}, args map[string]interface{}) (interface{}, error) {
+	site, _ := args["site"].(string)
+	if _, ok := srv.getSiteConfig(site); !ok || !srv.siteVisible(ctx.data.(*http.Request), site) {
+		return nil, nil
+	}
+	return site, nil
+}
+
+// 查询多个网站的历史条目，按 site、sites、tag 参数选择网站，都没有时查询所有网站
+func (srv *Server) gqlResolveItems(ctx *gqlContext, _ interface{}, args map[string]interface{}) (interface{}, error) {
+	var sites []string
+	if site, ok := args["site"].(string); ok {
+		sites = append(sites, site)
+	}
+	if list, ok := args["sites"].([]interface{}); ok {
+		for _, v := range list {
+			sites = append(sites, v.(string))
+		}
+	}
+	tag, hasTag := args["tag"].(string)
+	if len(sites) == 0 || hasTag {
+		// 按标签或所有网站展开时跳过没有权限的网站
+		for site, config := range srv.getAllSiteConfig() {
+			if (!hasTag || slices.Contains(config.Tags, tag)) && srv.gqlSiteAuthorized(ctx, site) {
+				sites = append(sites, site)
+			}
+		}
+		sort.Strings(sites)
+	}
+	return srv.gqlQueryItems(ctx, sites, args)
+}
+
+func (srv *Server) gqlSiteAuthorized(ctx *gqlContext, site string) bool {
+	r := ctx.data.(*http.Request)
+	return srv.adminAuthorized(r) || srv.feedAuthorized(r, site)
+}
+
+// 查询网站的历史条目，合并后按首次出现时间从新到旧排序
+func (srv *Server) gqlQueryItems(ctx *gqlContext, sites []string, args map[string]interface{}) (interface{}, error) {
+	q := ItemQuery{Limit: defaultGraphQLItems}
+	if v, ok := args["q"].(string); ok {
+		q.Query = v
+	}
+	if v, ok := args["limit"].(int); ok {
+		if v <= 0 {
+			return nil, fmt.Errorf("limit must be positive")
+		}
+		q.Limit = min(v, maxHistoryLimit)
+	}
+	for name, t := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if v, ok := args[name].(string); ok {
+			parsed, err := parseSince(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s, expected YYYY-MM-DD or RFC 3339", name)
+			}
+			*t = parsed
+		}
+	}
+
+	var items []gqlItem
+	for _, site := range sites {
+		if _, ok := srv.getSiteConfig(site); !ok || !srv.siteVisible(ctx.data.(*http.Request), site) {
+			return nil, fmt.Errorf("unknown site: %s", site)
+		}
+		if !srv.gqlSiteAuthorized(ctx, site) {
+			return nil, fmt.Errorf("unauthorized: %s", site)
+		}
+		recs, err := srv.store.QueryItems(site, q)
+		if err != nil {
+			return nil, fmt.Errorf("query items for %s: %v", site, err)
+		}
+		for _, rec := range recs {
+			items = append(items, gqlItem{Site: site, apiItem: newAPIItem(rec), firstSeen: rec.FirstSeen})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].firstSeen > items[j].firstSeen })
+	if len(items) > q.Limit {
+		items = items[:q.Limit]
+	}
+
+	list := make([]interface{}, len(items))
+	for i, item := range items {
+		list[i] = item
+	}
+	return list, nil
+}
+
+// 抓取状态只对 -admin-allow / -admin-deny 允许的客户端开放，同 /status
+func (srv *Server) gqlCheckStatusAccess(ctx *gqlContext) error {
+	ip, ok := srv.clientIP(ctx.data.(*http.Request))
+	if !srv.adminIPRules.allows(ip, ok) {
+		return fmt.Errorf("status is not available from this address")
+	}
+	return nil
+}
+
+func (srv *Server) gqlResolveStatus(ctx *gqlContext, _ interface{}, args map[string]interface{}) (interface{}, error) {
+	if err := srv.gqlCheckStatusAccess(ctx); err != nil {
+		return nil, err
+	}
+	r := ctx.data.(*http.Request)
+	var sites []string
+	if site, ok := args["site"].(string); ok {
+		if _, ok := srv.getSiteConfig(site); !ok || !srv.siteVisible(r, site) {
+			return nil, fmt.Errorf("unknown site: %s", site)
+		}
+		sites = []string{site}
+	} else {
+		for site := range srv.getAllSiteConfig() {
+			if srv.siteVisible(r, site) {
+				sites = append(sites, site)
+			}
+		}
+		sort.Strings(sites)
+	}
+	list := make([]interface{}, len(sites))
+	for i, site := range sites {
+		list[i] = srv.getSiteStatus(site)
+	}
+	return list, nil
+}
+
+// GraphQL 请求
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// GraphQL 响应，查询无法执行时没有 data
+type graphQLResponse struct {
+	Data   *gqlObject `json:"data,omitempty"`
+	Errors []gqlError `json:"errors,omitempty"`
+}
+
+// /graphql：GET ?query=...&variables=...，或 POST JSON（application/graphql 时请求体为查询本身）
+func (srv *Server) graphQLHandler(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	switch r.Method {
+	case http.MethodGet:
+		params := r.URL.Query()
+		req.Query = params.Get("query")
+		req.OperationName = params.Get("operationName")
+		if v := params.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeGraphQLError(w, fmt.Errorf("invalid variables: %v", err))
+				return
+			}
+		}
+	case http.MethodPost:
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxGraphQLBody))
+		if err != nil {
+			writeGraphQLError(w, fmt.Errorf("read request: %v", err))
+			return
+		}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql") {
+			req.Query = string(body)
+		} else if err := json.Unmarshal(body, &req); err != nil {
+			writeGraphQLError(w, fmt.Errorf("invalid request body: %v", err))
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if req.Query == "" {
+		writeGraphQLError(w, fmt.Errorf("missing query"))
+		return
+	}
+
+	op, err := parseGraphQL(req.Query, req.OperationName)
+	if err != nil {
+		writeGraphQLError(w, err)
+		return
+	}
+	data, errs, err := executeGraphQL(srv.graphQLSchema, op, req.Variables, r)
+	if err != nil {
+		writeGraphQLError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, graphQLResponse{Data: data, Errors: errs})
+}
+
+// 查询无法解析或校验失败
+func writeGraphQLError(w http.ResponseWriter, err error) {
+	writeJSON(w, http.StatusBadRequest, graphQLResponse{Errors: []gqlError{{Message: err.Error()}}})
+}
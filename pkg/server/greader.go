@@ -0,0 +1,444 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Google Reader API 的常用部分，供 FreshRSS、Inoreader 兼容的阅读器同步使用。
+// 订阅源的流 ID 为 feed/<网站>，标签为 user/-/label/<标签>
+
+const (
+	greaderPrefix     = "/reader/api/0/"
+	greaderItemPrefix = "tag:google.com,2005:reader/item/"
+
+	greaderReadingList = "user/-/state/com.google/reading-list"
+	greaderRead        = "user/-/state/com.google/read"
+	greaderStarred     = "user/-/state/com.google/starred"
+	greaderLabel       = "user/-/label/"
+
+	// 每次返回的默认和最大条目数
+	greaderDefaultItems = 20
+	greaderMaxItems     = 1000
+)
+
+// 登录令牌由账号和密码计算，重启后保持有效，修改密码后失效
+func (srv *Server) greaderAuthToken() string {
+	mac := hmac.New(sha256.New, []byte(srv.cfg.ReaderPassword))
+	mac.Write([]byte("greader\n" + srv.cfg.ReaderUser))
+	return srv.cfg.ReaderUser + "/" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// POST /accounts/ClientLogin：Email、Passwd 为 -reader-user、-reader-password
+func (srv *Server) greaderLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if !srv.readerEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Invalid form")
+		return
+	}
+	user, pass := r.Form.Get("Email"), r.Form.Get("Passwd")
+	if subtle.ConstantTimeCompare([]byte(user), []byte(srv.cfg.ReaderUser)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(pass), []byte(srv.cfg.ReaderPassword)) != 1 {
+		writeError(w, r, http.StatusUnauthorized, ErrCodeReaderUnauthorized, "Error=BadAuthentication")
+		return
+	}
+	token := srv.greaderAuthToken()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "SID=%s\nLSID=%s\nAuth=%s\n", token, token, token)
+}
+
+// 请求头 Authorization: GoogleLogin auth=<令牌>
+func (srv *Server) greaderAuthorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "GoogleLogin auth=")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(srv.greaderAuthToken())) == 1
+}
+
+// /reader/api/0/...
+func (srv *Server) greaderHandler(w http.ResponseWriter, r *http.Request) {
+	if !srv.readerEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	if !srv.greaderAuthorized(r) {
+		w.Header().Set("WWW-Authenticate", "GoogleLogin")
+		writeError(w, r, http.StatusUnauthorized, ErrCodeReaderUnauthorized, "Unauthorized")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Invalid form")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, greaderPrefix)
+	switch {
+	case path == "token":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, srv.greaderAuthToken())
+	case path == "user-info":
+		writeJSON(w, http.StatusOK, map[string]string{
+			"userId": "1", "userName": srv.cfg.ReaderUser, "userProfileId": "1", "userEmail": srv.cfg.ReaderUser,
+		})
+	case path == "subscription/list":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"subscriptions": srv.greaderSubscriptions(r)})
+	case path == "tag/list":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"tags": srv.greaderTags()})
+	case path == "unread-count":
+		srv.greaderUnreadCount(w, r)
+	case path == "stream/items/ids":
+		srv.greaderStream(w, r, r.Form.Get("s"), true)
+	case strings.HasPrefix(path, "stream/contents"):
+		stream := strings.TrimPrefix(strings.TrimPrefix(path, "stream/contents"), "/")
+		if stream == "" {
+			stream = r.Form.Get("s")
+		}
+		srv.greaderStream(w, r, stream, false)
+	case path == "stream/items/contents":
+		srv.greaderItemContents(w, r)
+	case path == "edit-tag":
+		srv.greaderEditTag(w, r)
+	case path == "mark-all-as-read":
+		srv.greaderMarkAllRead(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (srv *Server) greaderSubscriptions(r *http.Request) []map[string]interface{} {
+	configs := srv.readerSiteConfigs()
+	sites := make([]string, 0, len(configs))
+	for site := range configs {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+
+	base := srv.requestBaseURL(r)
+	subs := []map[string]interface{}{}
+	for _, site := range sites {
+		config := configs[site]
+		categories := []map[string]string{}
+		for _, tag := range config.Tags {
+			categories = append(categories, map[string]string{"id": greaderLabel + tag, "label": tag})
+		}
+		subs = append(subs, map[string]interface{}{
+			"id":         "feed/" + site,
+			"title":      config.Name,
+			"categories": categories,
+			"url":        siteFeedURLs(base, site)[FormatRSS],
+			"htmlUrl":    config.URL,
+			"iconUrl":    "",
+		})
+	}
+	return subs
+}
+
+func (srv *Server) greaderTags() []map[string]string {
+	tags := []map[string]string{{"id": greaderStarred}}
+	for _, tag := range srv.readerTags() {
+		tags = append(tags, map[string]string{"id": greaderLabel + tag, "type": "folder"})
+	}
+	return tags
+}
+
+// 客户端可能使用 user/<数字 ID>/...，统一为 user/-/...
+func normalizeStreamID(id string) string {
+	if rest, ok := strings.CutPrefix(id, "user/"); ok {
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			return "user/-" + rest[i:]
+		}
+	}
+	return id
+}
+
+// 条目是否属于流：订阅源、标签、全部条目、已读或收藏
+func (srv *Server) greaderInStream(item readerItem, stream string, configs map[string]SiteConfig) bool {
+	switch stream = normalizeStreamID(stream); {
+	case stream == greaderReadingList || stream == "":
+		return true
+	case stream == greaderRead:
+		return srv.itemRead(item.ID)
+	case stream == greaderStarred:
+		return srv.itemSaved(item.ID)
+	case strings.HasPrefix(stream, "feed/"):
+		return item.Site == strings.TrimPrefix(stream, "feed/")
+	case strings.HasPrefix(stream, greaderLabel):
+		return slices.Contains(configs[item.Site].Tags, strings.TrimPrefix(stream, greaderLabel))
+	}
+	return false
+}
+
+// stream/contents 和 stream/items/ids：支持 n、r=o（从旧到新）、c（续取位置）、
+// ot/nt（首次出现时间范围，秒）、xt/it（排除或只包含某个流，通常是已读）
+func (srv *Server) greaderStream(w http.ResponseWriter, r *http.Request, stream string, idsOnly bool) {
+	all, err := srv.allReaderItems()
+	if err != nil {
+		slog.Error("Failed to query items", "error", err)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeStorage, "Failed to query items")
+		return
+	}
+	configs := srv.readerSiteConfigs()
+	params := r.Form
+	ot, _ := strconv.ParseInt(params.Get("ot"), 10, 64)
+	nt, _ := strconv.ParseInt(params.Get("nt"), 10, 64)
+
+	var matched []readerItem
+	for _, item := range all {
+		if !srv.greaderInStream(item, stream, configs) ||
+			(ot > 0 && item.FirstSeen < ot) || (nt > 0 && item.FirstSeen > nt) {
+			continue
+		}
+		excluded := false
+		for _, xt := range params["xt"] {
+			excluded = excluded || srv.greaderInStream(item, xt, configs)
+		}
+		for _, it := range params["it"] {
+			excluded = excluded || !srv.greaderInStream(item, it, configs)
+		}
+		if !excluded {
+			matched = append(matched, item)
+		}
+	}
+	if params.Get("r") != "o" {
+		slices.Reverse(matched)
+	}
+
+	n, err := strconv.Atoi(params.Get("n"))
+	if err != nil || n <= 0 {
+		n = greaderDefaultItems
+	}
+	n = min(n, greaderMaxItems)
+	offset, _ := strconv.Atoi(params.Get("c"))
+	offset = min(max(offset, 0), len(matched))
+	page := matched[offset:min(offset+n, len(matched))]
+
+	resp := map[string]interface{}{}
+	if offset+n < len(matched) {
+		resp["continuation"] = strconv.Itoa(offset + n)
+	}
+	if idsOnly {
+		refs := make([]map[string]interface{}, 0, len(page))
+		for _, item := range page {
+			refs = append(refs, map[string]interface{}{
+				"id":              strconv.FormatInt(item.ID, 10),
+				"directStreamIds": []string{},
+				"timestampUsec":   strconv.FormatInt(item.FirstSeen*1e6, 10),
+			})
+		}
+		resp["itemRefs"] = refs
+	} else {
+		resp["id"] = stream
+		resp["updated"] = time.Now().Unix()
+		resp["items"] = srv.greaderItems(page, configs)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// stream/items/contents：按 i 参数（可以有多个）返回条目内容
+func (srv *Server) greaderItemContents(w http.ResponseWriter, r *http.Request) {
+	want := make(map[int64]bool)
+	for _, s := range r.Form["i"] {
+		if id, ok := parseGReaderID(s); ok {
+			want[id] = true
+		}
+	}
+	all, err := srv.allReaderItems()
+	if err != nil {
+		slog.Error("Failed to query items", "error", err)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeStorage, "Failed to query items")
+		return
+	}
+	var page []readerItem
+	for _, item := range all {
+		if want[item.ID] {
+			page = append(page, item)
+		}
+	}
+	slices.Reverse(page)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      greaderReadingList,
+		"updated": time.Now().Unix(),
+		"items":   srv.greaderItems(page, srv.readerSiteConfigs()),
+	})
+}
+
+// 条目 ID 可以是十进制，也可以是 tag:google.com,2005:reader/item/<16 位十六进制>
+func parseGReaderID(s string) (int64, bool) {
+	if hexID, ok := strings.CutPrefix(s, greaderItemPrefix); ok {
+		id, err := strconv.ParseUint(hexID, 16, 64)
+		return int64(id), err == nil
+	}
+	id, err := strconv.ParseInt(s, 10, 64)
+	return id, err == nil
+}
+
+func (srv *Server) greaderItems(page []readerItem, configs map[string]SiteConfig) []map[string]interface{} {
+	items := make([]map[string]interface{}, 0, len(page))
+	for _, item := range page {
+		config := configs[item.Site]
+		categories := []string{greaderReadingList}
+		for _, tag := range config.Tags {
+			categories = append(categories, greaderLabel+tag)
+		}
+		if srv.itemRead(item.ID) {
+			categories = append(categories, greaderRead)
+		}
+		if srv.itemSaved(item.ID) {
+			categories = append(categories, greaderStarred)
+		}
+		published := item.FirstSeen
+		if t, err := time.ParseInLocation(pubDateLayout, item.Item.PubDate, time.Local); err == nil {
+			published = t.Unix()
+		}
+		items = append(items, map[string]interface{}{
+			"id":            fmt.Sprintf("%s%016x", greaderItemPrefix, item.ID),
+			"crawlTimeMsec": strconv.FormatInt(item.FirstSeen*1e3, 10),
+			"timestampUsec": strconv.FormatInt(item.FirstSeen*1e6, 10),
+			"published":     published,
+			"updated":       item.LastSeen,
+			"title":         item.Item.Title,
+			"canonical":     []map[string]string{{"href": item.Item.Link}},
+			"alternate":     []map[string]string{{"href": item.Item.Link, "type": "text/html"}},
+			"summary":       map[string]string{"direction": "ltr", "content": item.Item.Description},
+			"categories":    categories,
+			"origin":        map[string]string{"streamId": "feed/" + item.Site, "title": config.Name, "htmlUrl": config.URL},
+		})
+	}
+	return items
+}
+
+// edit-tag：a 添加、r 移除已读（read）或收藏（starred）状态，i 为条目 ID
+func (srv *Server) greaderEditTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	var ids []int64
+	for _, s := range r.Form["i"] {
+		if id, ok := parseGReaderID(s); ok {
+			ids = append(ids, id)
+		}
+	}
+	changes := []struct {
+		param         string
+		read, starred func(readerState, int64)
+	}{
+		{"a", markRead, markSaved},
+		{"r", markUnread, markUnsaved},
+	}
+	for _, c := range changes {
+		for _, tag := range r.Form[c.param] {
+			var set func(readerState, int64)
+			switch normalizeStreamID(tag) {
+			case greaderRead:
+				set = c.read
+			case greaderStarred:
+				set = c.starred
+			default:
+				continue
+			}
+			if err := srv.setItemState(set, ids...); err != nil {
+				slog.Error("Failed to update reader state", "file", srv.cfg.ReaderStateFile, "error", err)
+				writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Failed to update state")
+				return
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, "OK")
+}
+
+// mark-all-as-read：把流 s 中首次出现时间不晚于 ts（微秒）的条目标记为已读
+func (srv *Server) greaderMarkAllRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	ts, err := strconv.ParseInt(r.Form.Get("ts"), 10, 64)
+	if err != nil || ts <= 0 {
+		ts = time.Now().UnixMicro()
+	}
+	all, err := srv.allReaderItems()
+	if err != nil {
+		slog.Error("Failed to query items", "error", err)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeStorage, "Failed to query items")
+		return
+	}
+	configs := srv.readerSiteConfigs()
+	var ids []int64
+	for _, item := range all {
+		if item.FirstSeen*1e6 <= ts && srv.greaderInStream(item, r.Form.Get("s"), configs) {
+			ids = append(ids, item.ID)
+		}
+	}
+	if err := srv.setItemState(markRead, ids...); err != nil {
+		slog.Error("Failed to update reader state", "file", srv.cfg.ReaderStateFile, "error", err)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Failed to update state")
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, "OK")
+}
+
+// unread-count：每个订阅源、标签和全部条目的未读数
+func (srv *Server) greaderUnreadCount(w http.ResponseWriter, r *http.Request) {
+	all, err := srv.allReaderItems()
+	if err != nil {
+		slog.Error("Failed to query items", "error", err)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeStorage, "Failed to query items")
+		return
+	}
+	configs := srv.readerSiteConfigs()
+	type count struct {
+		n      int
+		newest int64
+	}
+	counts := make(map[string]*count)
+	add := func(stream string, item readerItem) {
+		c := counts[stream]
+		if c == nil {
+			c = &count{}
+			counts[stream] = c
+		}
+		c.n++
+		c.newest = max(c.newest, item.FirstSeen)
+	}
+	total := 0
+	for _, item := range all {
+		if srv.itemRead(item.ID) {
+			continue
+		}
+		total++
+		add("feed/"+item.Site, item)
+		add(greaderReadingList, item)
+		for _, tag := range configs[item.Site].Tags {
+			add(greaderLabel+tag, item)
+		}
+	}
+
+	streams := make([]string, 0, len(counts))
+	for stream := range counts {
+		streams = append(streams, stream)
+	}
+	sort.Strings(streams)
+	list := []map[string]interface{}{}
+	for _, stream := range streams {
+		list = append(list, map[string]interface{}{
+			"id":                      stream,
+			"count":                   counts[stream].n,
+			"newestItemTimestampUsec": strconv.FormatInt(counts[stream].newest*1e6, 10),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"max": total, "unreadcounts": list})
+}
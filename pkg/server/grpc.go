@@ -0,0 +1,373 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+const grpcService = "/rss.v1.FeedService/"
+
+// gRPC 状态码
+const (
+	grpcOK                = 0
+	grpcInvalidArgument   = 3
+	grpcNotFound          = 5
+	grpcPermissionDenied  = 7
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+	grpcUnavailable       = 14
+	grpcUnauthenticated   = 16
+)
+
+// 请求消息的最大长度
+const grpcMaxMessage = 4 << 20
+
+type grpcError struct {
+	code int
+	msg  string
+}
+
+func (e *grpcError) Error() string { return e.msg }
+
+func grpcErrorf(code int, format string, args ...interface{}) error {
+	return &grpcError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+// 在 addr 上以 h2c（不加密的 HTTP/2）提供 gRPC 服务，
+// 与 HTTP 服务共用 IP 访问控制
+func (srv *Server) startGRPCServer(addr string) *http.Server {
+	hs := srv.tuneServer(&http.Server{
+		Addr:    addr,
+		Handler: h2c.NewHandler(srv.ipFilterHandler(http.HandlerFunc(srv.grpcHandler)), &http2.Server{}),
+	}, true)
+	go func() {
+		slog.Info("gRPC server started", "addr", addr)
+		if err := hs.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatal("gRPC server failed", "error", err)
+		}
+	}()
+	return hs
+}
+
+// 处理一次 gRPC 调用：读取请求消息，调用方法，写入响应消息和 grpc-status trailer
+func (srv *Server) grpcHandler(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 || r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	start := time.Now()
+	method := strings.TrimPrefix(r.URL.Path, grpcService)
+	ctx := r.Context()
+	if d, ok := parseGRPCTimeout(r.Header.Get("Grpc-Timeout")); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	r = r.WithContext(ctx)
+
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+
+	err := srv.callGRPC(w, r, method)
+	code, msg := grpcOK, ""
+	if err != nil {
+		code, msg = grpcInternal, err.Error()
+		var ge *grpcError
+		switch {
+		case errors.As(err, &ge):
+			code = ge.code
+		case errors.Is(err, context.DeadlineExceeded):
+			code = 4
+		case errors.Is(err, context.Canceled):
+			code = 1
+		}
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		w.Header().Set("Grpc-Message", url.PathEscape(msg))
+	}
+	slog.Debug("gRPC call", "method", method, "code", code, "duration", time.Since(start), "error", msg)
+}
+
+func (srv *Server) callGRPC(w http.ResponseWriter, r *http.Request, method string) error {
+	req, err := readGRPCMessage(r.Body)
+	if err != nil {
+		return err
+	}
+	fields, err := parseProtobuf(req)
+	if err != nil {
+		return grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+
+	switch method {
+	case "ListSites":
+		return srv.grpcListSites(w, r, fields)
+	case "GetFeed":
+		return srv.grpcGetFeed(w, r, fields)
+	case "StreamNewItems":
+		return srv.grpcStreamNewItems(w, r, fields)
+	case "RefreshSite":
+		return srv.grpcRefreshSite(w, r, fields)
+	}
+	return grpcErrorf(grpcUnimplemented, "unknown method %s", r.URL.Path)
+}
+
+// 读取一条带 5 字节前缀（压缩标志 + 长度）的消息，不支持压缩
+func readGRPCMessage(body io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(body, prefix[:]); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "read request: %v", err)
+	}
+	if prefix[0] != 0 {
+		return nil, grpcErrorf(grpcUnimplemented, "compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if n > grpcMaxMessage {
+		return nil, grpcErrorf(grpcResourceExhausted, "request message too large")
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(body, msg); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "read request: %v", err)
+	}
+	return msg, nil
+}
+
+func writeGRPCMessage(w http.ResponseWriter, msg []byte) error {
+	prefix := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+	if _, err := w.Write(append(prefix, msg...)); err != nil {
+		return err
+	}
+	return http.NewResponseController(w).Flush()
+}
+
+// 解析 grpc-timeout，如 100m、30S
+func parseGRPCTimeout(s string) (time.Duration, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	units := map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second,
+		'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond}
+	unit, ok := units[s[len(s)-1]]
+	return time.Duration(n) * unit, ok
+}
+
+// 读取网站需要订阅源权限：管理令牌，或者该网站的 Basic 认证（通过 authorization 元数据）
+func (srv *Server) grpcCheckSite(r *http.Request, site string) error {
+	if site == "" {
+		return grpcErrorf(grpcInvalidArgument, "missing site")
+	}
+	if _, ok := srv.getSiteConfig(site); !ok {
+		return grpcErrorf(grpcNotFound, "unknown site: %s", site)
+	}
+	if !srv.adminAuthorized(r) && !srv.feedAuthorized(r, site) {
+		return grpcErrorf(grpcUnauthenticated, "unauthorized: %s", site)
+	}
+	return nil
+}
+
+func (srv *Server) grpcListSites(w http.ResponseWriter, r *http.Request, fields []pbField) error {
+	var tag string
+	for _, f := range fields {
+		if f.field == 1 {
+			tag = string(f.data)
+		}
+	}
+
+	configs := srv.getAllSiteConfig()
+	sites := make([]string, 0, len(configs))
+	for site, config := range configs {
+		if (tag == "" || slices.Contains(config.Tags, tag)) && srv.siteVisible(r, site) {
+			sites = append(sites, site)
+		}
+	}
+	sort.Strings(sites)
+
+	var resp pbWriter
+	for _, site := range sites {
+		config := configs[site]
+		var m pbWriter
+		m.String(1, site)
+		m.String(2, config.Name)
+		m.String(3, config.URL)
+		m.Strings(4, config.Tags)
+		m.Bool(5, srv.feedProtected(site))
+		resp.Message(1, m.buf)
+	}
+	return writeGRPCMessage(w, resp.buf)
+}
+
+func (srv *Server) grpcGetFeed(w http.ResponseWriter, r *http.Request, fields []pbField) error {
+	var site string
+	var limit int
+	for _, f := range fields {
+		switch f.field {
+		case 1:
+			site = string(f.data)
+		case 2:
+			limit = int(int32(f.num))
+		}
+	}
+	if err := srv.grpcCheckSite(r, site); err != nil {
+		return err
+	}
+	srv.countRequest(site)
+	srv.touchCachedFeed(site)
+
+	fc, err := srv.siteFeed(r.Context(), site)
+	if err != nil {
+		return grpcErrorf(grpcUnavailable, "%v", err)
+	}
+	items := fc.Feed.Channel.Items
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+
+	ch := fc.Feed.Channel
+	var m pbWriter
+	m.String(1, site)
+	m.String(2, ch.Title)
+	m.String(3, ch.Link)
+	m.String(4, ch.Description)
+	m.Int64(5, fc.UpdatedAt.Unix())
+	m.Int64(6, fc.ExpireAt.Unix())
+	for _, item := range items {
+		m.Message(7, encodeGRPCItem(item))
+	}
+	return writeGRPCMessage(w, m.buf)
+}
+
+func encodeGRPCItem(item Item) []byte {
+	var m pbWriter
+	m.String(1, item.GUID)
+	m.String(2, item.Title)
+	m.String(3, item.Link)
+	m.String(4, item.Description)
+	if t, err := time.ParseInLocation(pubDateLayout, item.PubDate, time.Local); err == nil {
+		m.Int64(5, t.Unix())
+	}
+	if item.Thumbnail != nil {
+		m.String(6, item.Thumbnail.URL)
+	}
+	return m.buf
+}
+
+func (srv *Server) grpcStreamNewItems(w http.ResponseWriter, r *http.Request, fields []pbField) error {
+	var names, tags []string
+	lastID := int64(-1)
+	for _, f := range fields {
+		switch f.field {
+		case 1:
+			names = append(names, string(f.data))
+		case 2:
+			tags = append(tags, string(f.data))
+		case 3:
+			lastID = int64(f.num)
+		}
+	}
+	for _, tag := range tags {
+		found := false
+		for site, config := range srv.getAllSiteConfig() {
+			if slices.Contains(config.Tags, tag) {
+				names = append(names, site)
+				found = true
+			}
+		}
+		if !found {
+			return grpcErrorf(grpcNotFound, "no sites with tag: %s", tag)
+		}
+	}
+	if len(names) == 0 {
+		return grpcErrorf(grpcInvalidArgument, "missing sites or tags")
+	}
+	sites := make(map[string]bool, len(names))
+	for _, site := range names {
+		if err := srv.grpcCheckSite(r, site); err != nil {
+			return err
+		}
+		sites[site] = true
+	}
+
+	ch, missed := srv.subscribeItems(sites, lastID)
+	defer srv.unsubscribeItems(ch)
+	send := func(ev itemEvent) error {
+		var m pbWriter
+		m.Int64(1, ev.ID)
+		m.String(2, ev.Site)
+		m.Message(3, encodeGRPCItem(ev.Item))
+		return writeGRPCMessage(w, m.buf)
+	}
+	for _, ev := range missed {
+		if err := send(ev); err != nil {
+			return err
+		}
+	}
+	// 先发送响应头，客户端才知道流已经建立
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return grpcErrorf(grpcResourceExhausted, "client too slow")
+			}
+			if err := send(ev); err != nil {
+				return err
+			}
+		case <-r.Context().Done():
+			return r.Context().Err()
+		case <-srv.streamsClosing:
+			return grpcErrorf(grpcUnavailable, "server shutting down")
+		}
+	}
+}
+
+func (srv *Server) grpcRefreshSite(w http.ResponseWriter, r *http.Request, fields []pbField) error {
+	var site string
+	for _, f := range fields {
+		if f.field == 1 {
+			site = string(f.data)
+		}
+	}
+	if !srv.adminAuthorized(r) {
+		return grpcErrorf(grpcPermissionDenied, "RefreshSite requires the admin token")
+	}
+	if err := srv.grpcCheckSite(r, site); err != nil {
+		return err
+	}
+
+	ctx, cancel := srv.fetchContext(r.Context())
+	defer cancel()
+	fc, err := srv.refreshCacheContext(ctx, site)
+	if err != nil {
+		return grpcErrorf(grpcUnavailable, "%v", err)
+	}
+	var m pbWriter
+	m.String(1, site)
+	m.Int64(2, int64(len(fc.Feed.Channel.Items)))
+	m.Int64(3, fc.ExpireAt.Unix())
+	return writeGRPCMessage(w, m.buf)
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// 存活检查：进程能处理请求即可
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// 就绪检查：存储可访问，且关键网站（Critical）都已有缓存；
+// 没有关键网站时等待启动预热完成。不就绪时返回 503 和原因
+func (srv *Server) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if problems := srv.readinessProblems(); len(problems) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, strings.Join(problems, "\n"))
+		return
+	}
+	fmt.Fprintln(w, "ready")
+}
+
+func (srv *Server) readinessProblems() []string {
+	if srv.shuttingDown.Load() {
+		return []string{"shutting down"}
+	}
+
+	// 支持 Ping 的存储（Redis、SQLite）检查连接
+	if p, ok := srv.store.(interface{ Ping() error }); ok {
+		if err := p.Ping(); err != nil {
+			return []string{"storage unreachable: " + err.Error()}
+		}
+	}
+
+	var problems []string
+	critical := srv.criticalSites()
+	for _, site := range critical {
+		if _, ok, err := srv.store.Get(site); err != nil {
+			problems = append(problems, fmt.Sprintf("site %s: %v", site, err))
+		} else if !ok {
+			problems = append(problems, fmt.Sprintf("site %s: not cached yet", site))
+		}
+	}
+	if len(critical) == 0 && !srv.warmedUp.Load() {
+		problems = append(problems, "warmup in progress")
+	}
+	return problems
+}
+
+func (srv *Server) criticalSites() []string {
+	var sites []string
+	for site, config := range srv.getAllSiteConfig() {
+		if config.Critical {
+			sites = append(sites, site)
+		}
+	}
+	sort.Strings(sites)
+	return sites
+}
@@ -0,0 +1,56 @@
+package server
+
+// 网站订阅源的条目数
+func (srv *Server) siteFeedItems(config SiteConfig) int {
+	if config.FeedItems > 0 {
+		return config.FeedItems
+	}
+	return srv.cfg.FeedItems
+}
+
+// 去除 GUID 重复的条目，保留第一次出现的条目
+func dedupGUIDs(items []Item) ([]Item, int) {
+	seen := make(map[string]bool, len(items))
+	kept := items[:0:0]
+	for _, item := range items {
+		if seen[item.GUID] {
+			continue
+		}
+		seen[item.GUID] = true
+		kept = append(kept, item)
+	}
+	return kept, len(items) - len(kept)
+}
+
+// 合并当前页面的条目和历史条目：先按页面顺序放入当前条目（使用 stored 中存储的版本，
+// 以沿用第一次记录的发布时间，不论条目多旧），再用最近的历史条目补足到 limit 条
+func mergeHistory(current, stored, history []Item, limit int) []Item {
+	byGUID := make(map[string]Item, len(stored))
+	for _, item := range stored {
+		byGUID[item.GUID] = item
+	}
+
+	merged := make([]Item, 0, limit)
+	inFeed := make(map[string]bool, limit)
+	for _, item := range current {
+		if len(merged) >= limit {
+			break
+		}
+		if s, ok := byGUID[item.GUID]; ok {
+			item = s
+		}
+		merged = append(merged, item)
+		inFeed[item.GUID] = true
+	}
+
+	for _, item := range history {
+		if len(merged) >= limit {
+			break
+		}
+		if !inFeed[item.GUID] {
+			merged = append(merged, item)
+			inFeed[item.GUID] = true
+		}
+	}
+	return merged
+}
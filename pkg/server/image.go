@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"rss-zhuaqu/pkg/feed"
+	"rss-zhuaqu/pkg/scraper"
+)
+
+// 网站图标的候选来源，按优先级排列
+var siteImageSelectors = []struct {
+	selector string
+	attr     string
+}{
+	{`link[rel="apple-touch-icon"]`, "href"},
+	{`link[rel="apple-touch-icon-precomposed"]`, "href"},
+	{`link[rel="icon"]`, "href"},
+	{`link[rel="shortcut icon"]`, "href"},
+	{`meta[property="og:image"]`, "content"},
+}
+
+// 查找到的网站图标的有效期，过期后重新查找
+const siteImageTTL = 7 * 24 * time.Hour
+
+// 加载网站图标（apple-touch-icon、favicon 或 og:image）。
+// 优先使用内存中或存储的缓存中未过期的查找结果，不必每次启动都请求网站
+func (srv *Server) loadSiteImage(site string, config SiteConfig) {
+	if img := srv.siteImage(site); img != nil && siteImageFresh(*img, config) {
+		return
+	}
+	if fc, ok := srv.getCachedFeed(site); ok && fc.SiteImage != nil && siteImageFresh(*fc.SiteImage, config) {
+		srv.setSiteImage(site, *fc.SiteImage)
+		return
+	}
+
+	image, err := srv.findSiteImage(srv.appCtx, config.URL)
+	if err != nil {
+		slog.Warn("Failed to load site image", "site", site, "url", config.URL, "error", err, "error_class", errorClass(err))
+		return
+	}
+	srv.setSiteImage(site, SiteImage{URL: image, Page: config.URL, CheckedAt: time.Now()})
+}
+
+func siteImageFresh(img SiteImage, config SiteConfig) bool {
+	return img.Page == config.URL && time.Since(img.CheckedAt) < siteImageTTL
+}
+
+// 网站图标的查找结果，没有查找过时返回 nil
+func (srv *Server) siteImage(site string) *SiteImage {
+	srv.siteImagesLock.RLock()
+	defer srv.siteImagesLock.RUnlock()
+	img, ok := srv.siteImages[site]
+	if !ok {
+		return nil
+	}
+	return &img
+}
+
+func (srv *Server) setSiteImage(site string, img SiteImage) {
+	srv.siteImagesLock.Lock()
+	srv.siteImages[site] = img
+	srv.siteImagesLock.Unlock()
+}
+
+func (srv *Server) findSiteImage(ctx context.Context, pageURL string) (string, error) {
+	doc, err := srv.fetchDocument(ctx, pageURL)
+	if err != nil {
+		return "", err
+	}
+
+	for _, c := range siteImageSelectors {
+		if v, ok := doc.Find(c.selector).First().Attr(c.attr); ok && v != "" {
+			return scraper.ResolveURL(pageURL, v), nil
+		}
+	}
+
+	// 页面未声明图标时，退回到站点根目录的 favicon.ico
+	favicon := scraper.ResolveURL(pageURL, "/favicon.ico")
+	resp, err := srv.pageScraper().Head(ctx, favicon)
+	var statusErr *scraper.StatusError
+	if errors.As(err, &statusErr) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil
+	}
+	return favicon, nil
+}
+
+// 获取网站的频道图片
+func (srv *Server) getChannelImage(site string, config SiteConfig) *feed.ChannelImage {
+	img := srv.siteImage(site)
+	if img == nil || img.URL == "" {
+		return nil
+	}
+	return &feed.ChannelImage{
+		URL:   img.URL,
+		Title: config.Name,
+		Link:  config.URL,
+	}
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"rss-zhuaqu/pkg/scraper"
+)
+
+// 故障升级：网站连续失败达到 -alert-threshold 次并持续 -escalate-after 后，
+// 在 PagerDuty 或 Opsgenie 中创建事件，网站恢复后自动解决
+
+// 网站的故障状态
+type incidentState struct {
+	// 连续失败达到阈值的时间
+	FailingSince time.Time
+	// 已经创建了事件
+	Opened    bool
+	Error     string `json:",omitempty"`
+	ErrorCode string `json:",omitempty"`
+}
+
+func (srv *Server) escalationEnabled() bool {
+	return srv.cfg.PagerDutyRoutingKey != "" || srv.cfg.OpsgenieAPIKey != ""
+}
+
+// 刷新后更新网站的故障状态：prev、cur 为本次刷新前后的连续失败次数
+func (srv *Server) trackIncident(site string, prev, cur int64, err error) {
+	if !srv.escalationEnabled() || srv.cfg.AlertThreshold <= 0 {
+		return
+	}
+	srv.incidentsMu.Lock()
+	defer srv.incidentsMu.Unlock()
+
+	st := srv.incidents[site]
+	switch {
+	case cur >= srv.cfg.AlertThreshold:
+		if st == nil {
+			st = &incidentState{FailingSince: time.Now()}
+			srv.incidents[site] = st
+		}
+		if err != nil {
+			st.Error, st.ErrorCode = err.Error(), fetchErrorCode(err)
+		}
+		srv.escalateLocked(site, st)
+	case cur == 0 && st != nil:
+		delete(srv.incidents, site)
+		if st.Opened {
+			slog.Info("Site recovered, resolving incident", "site", site, "failing_for", time.Since(st.FailingSince).Round(time.Second))
+			srv.enqueueIncidentEvents(site, st, false)
+		}
+	}
+}
+
+// 持续失败超过 escalateAfter 时创建事件，调用方持有 incidentsMu
+func (srv *Server) escalateLocked(site string, st *incidentState) {
+	if st.Opened || time.Since(st.FailingSince) < srv.cfg.EscalateAfter {
+		return
+	}
+	st.Opened = true
+	slog.Warn("Site has been failing too long, opening incident", "site", site, "failing_since", st.FailingSince, "error", st.Error)
+	srv.enqueueIncidentEvents(site, st, true)
+}
+
+// 网站的刷新间隔可能比 escalateAfter 长，定期检查一次
+func (srv *Server) startEscalator() {
+	if !srv.escalationEnabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				srv.incidentsMu.Lock()
+				for site, st := range srv.incidents {
+					srv.escalateLocked(site, st)
+				}
+				srv.incidentsMu.Unlock()
+			case <-srv.appCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// 检查点中保存的故障状态
+func (srv *Server) incidentStates() map[string]incidentState {
+	srv.incidentsMu.Lock()
+	defer srv.incidentsMu.Unlock()
+	if len(srv.incidents) == 0 {
+		return nil
+	}
+	states := make(map[string]incidentState, len(srv.incidents))
+	for site, st := range srv.incidents {
+		states[site] = *st
+	}
+	return states
+}
+
+func (srv *Server) restoreIncidents(states map[string]incidentState) {
+	srv.incidentsMu.Lock()
+	defer srv.incidentsMu.Unlock()
+	for site, st := range states {
+		st := st
+		srv.incidents[site] = &st
+	}
+}
+
+// 同一网站的事件使用固定的去重键，创建和解决对应同一个事件
+func incidentKey(site string) string {
+	return "rss-zhuaqu:" + site
+}
+
+func (srv *Server) enqueueIncidentEvents(site string, st *incidentState, open bool) {
+	config, _ := srv.getSiteConfig(site)
+	summary := fmt.Sprintf("RSS site %s has been failing since %s: %s", site, st.FailingSince.Format(time.RFC3339), st.Error)
+	if !open {
+		summary = fmt.Sprintf("RSS site %s recovered", site)
+	}
+	details := map[string]string{
+		"site":          site,
+		"name":          config.Name,
+		"url":           config.URL,
+		"failing_since": st.FailingSince.Format(time.RFC3339),
+		"error":         st.Error,
+		"error_code":    st.ErrorCode,
+	}
+	if srv.cfg.PagerDutyRoutingKey != "" {
+		srv.enqueueDelivery(delivery{
+			sink:   "pagerduty",
+			target: incidentKey(site),
+			site:   site,
+			send: func(ctx context.Context) error {
+				return srv.sendPagerDutyEvent(ctx, site, open, summary, details)
+			},
+		})
+	}
+	if srv.cfg.OpsgenieAPIKey != "" {
+		srv.enqueueDelivery(delivery{
+			sink:   "opsgenie",
+			target: incidentKey(site),
+			site:   site,
+			send: func(ctx context.Context) error {
+				return srv.sendOpsgenieEvent(ctx, site, open, summary, details)
+			},
+		})
+	}
+}
+
+// PagerDuty Events API v2
+func (srv *Server) sendPagerDutyEvent(ctx context.Context, site string, open bool, summary string, details map[string]string) error {
+	event := map[string]interface{}{
+		"routing_key":  srv.cfg.PagerDutyRoutingKey,
+		"event_action": "resolve",
+		"dedup_key":    incidentKey(site),
+	}
+	if open {
+		host, _ := os.Hostname()
+		event["event_action"] = "trigger"
+		event["payload"] = map[string]interface{}{
+			"summary":        scraper.TruncateText(summary, 1024),
+			"source":         host,
+			"severity":       "error",
+			"component":      site,
+			"group":          "rss-zhuaqu",
+			"custom_details": details,
+		}
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return postBody(ctx, strings.TrimSuffix(srv.cfg.PagerDutyAPI, "/")+"/v2/enqueue", "application/json", body, nil)
+}
+
+// Opsgenie Alert API：创建告警，恢复时按别名关闭
+func (srv *Server) sendOpsgenieEvent(ctx context.Context, site string, open bool, summary string, details map[string]string) error {
+	base := strings.TrimSuffix(srv.cfg.OpsgenieAPI, "/") + "/v2/alerts"
+	header := http.Header{"Authorization": {"GenieKey " + srv.cfg.OpsgenieAPIKey}}
+	host, _ := os.Hostname()
+	if !open {
+		body, _ := json.Marshal(map[string]string{"source": host, "note": summary})
+		return postBody(ctx, base+"/"+url.PathEscape(incidentKey(site))+"/close?identifierType=alias", "application/json", body, header)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"message":     scraper.TruncateText(summary, 130),
+		"alias":       incidentKey(site),
+		"description": summary,
+		"details":     details,
+		"source":      host,
+		"tags":        []string{"rss-zhuaqu", site},
+		"priority":    "P3",
+	})
+	if err != nil {
+		return err
+	}
+	return postBody(ctx, base, "application/json", body, header)
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// 按客户端 IP 限制访问：允许列表不为空时只允许列表中的地址，拒绝列表优先。
+// admin 规则用于 /admin、/admin/、/metrics 和 /status，feed 规则用于其余接口（健康检查除外）
+type ipRules struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// 解析逗号分隔的 CIDR 或 IP 地址
+func parsePrefixes(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, v := range splitList(s) {
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP or CIDR %q", v)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP or CIDR %q", v)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+func newIPRules(allow, deny string) (ipRules, error) {
+	var rules ipRules
+	var err error
+	if rules.allow, err = parsePrefixes(allow); err != nil {
+		return rules, err
+	}
+	rules.deny, err = parsePrefixes(deny)
+	return rules, err
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rules ipRules) allows(addr netip.Addr, ok bool) bool {
+	if !ok {
+		// 无法识别地址（如 Unix socket）时只有没有允许列表才放行
+		return len(rules.allow) == 0
+	}
+	if containsAddr(rules.deny, addr) {
+		return false
+	}
+	return len(rules.allow) == 0 || containsAddr(rules.allow, addr)
+}
+
+// 客户端 IP
+func (srv *Server) clientIP(r *http.Request) (netip.Addr, bool) {
+	addr, ok := peerIP(r)
+	if !ok || !containsAddr(srv.trustedProxies, addr) {
+		return addr, ok
+	}
+	// 来自信任的代理时，从 X-Forwarded-For 右侧开始跳过信任的代理，第一个其他地址为客户端
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !containsAddr(srv.trustedProxies, addr) {
+			break
+		}
+	}
+	return addr, true
+}
+
+func (srv *Server) ipRulesFor(path string) (ipRules, bool) {
+	switch {
+	case path == "/healthz" || path == "/readyz":
+		return ipRules{}, false
+	case isAdminPath(path) || path == "/metrics" || path == "/status" || isRestHooksPath(path):
+		return srv.adminIPRules, true
+	}
+	return srv.feedIPRules, true
+}
+
+// 客户端 IP 不被允许时返回 403
+func (srv *Server) ipFilterHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rules, ok := srv.ipRulesFor(r.URL.Path); ok && !rules.allows(srv.clientIP(r)) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
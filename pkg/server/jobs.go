@@ -0,0 +1,79 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// 后台刷新任务的状态
+const (
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// 完成的任务保留多久以供查询
+const jobRetention = time.Hour
+
+// 由 /admin/refresh 发起的后台刷新任务
+type refreshJob struct {
+	ID         string     `json:"id"`
+	Site       string     `json:"site"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Items      int        `json:"items"`
+	Skipped    SkipStats  `json:"skipped,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// 随机的十六进制 ID
+func randomID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// 在后台刷新网站，返回任务 ID
+func (srv *Server) startRefreshJob(site string) string {
+	job := &refreshJob{ID: randomID(), Site: site, Status: JobRunning, StartedAt: time.Now()}
+
+	srv.jobsMu.Lock()
+	for id, j := range srv.jobs {
+		if j.FinishedAt != nil && time.Since(*j.FinishedAt) > jobRetention {
+			delete(srv.jobs, id)
+		}
+	}
+	srv.jobs[job.ID] = job
+	srv.jobsMu.Unlock()
+
+	go func() {
+		fc, err := srv.refreshCache(site)
+
+		srv.jobsMu.Lock()
+		defer srv.jobsMu.Unlock()
+		now := time.Now()
+		job.FinishedAt = &now
+		if err != nil {
+			job.Status = JobFailed
+			job.Error = err.Error()
+			return
+		}
+		job.Status = JobDone
+		job.Items = len(fc.Feed.Channel.Items)
+		job.Skipped = fc.Skipped
+	}()
+	return job.ID
+}
+
+// 查询任务，返回副本
+func (srv *Server) getJob(id string) (refreshJob, bool) {
+	srv.jobsMu.Lock()
+	defer srv.jobsMu.Unlock()
+	job, ok := srv.jobs[id]
+	if !ok {
+		return refreshJob{}, false
+	}
+	return *job, true
+}
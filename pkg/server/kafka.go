@@ -0,0 +1,465 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kafka 客户端，只实现发布：Metadata v4 查询分区的 leader，Produce v3 发送 RecordBatch（magic 2）。
+// 分区按消息键（网站名）的 murmur2 哈希选择，与 Java 客户端的默认分区器一致
+
+// Kafka 协议的 API
+const (
+	kafkaAPIProduce  = 0
+	kafkaAPIMetadata = 3
+)
+
+// 需要刷新元数据的错误码
+const (
+	kafkaErrUnknownTopicOrPartition = 3
+	kafkaErrLeaderNotAvailable      = 5
+	kafkaErrNotLeader               = 6
+)
+
+// 分区元数据的缓存时间
+const kafkaMetadataTTL = 5 * time.Minute
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+type kafkaClient struct {
+	bootstrap []string
+
+	mu      sync.Mutex
+	brokers map[int32]string
+	conns   map[int32]*kafkaConn
+	topics  map[string]*kafkaTopicMeta
+}
+
+// 主题的分区 leader，下标为分区号
+type kafkaTopicMeta struct {
+	leaders []int32
+	fetched time.Time
+}
+
+func newKafkaClient(brokers []string) *kafkaClient {
+	return &kafkaClient{
+		bootstrap: brokers,
+		brokers:   make(map[int32]string),
+		conns:     make(map[int32]*kafkaConn),
+		topics:    make(map[string]*kafkaTopicMeta),
+	}
+}
+
+// Kafka 返回的错误码
+type kafkaError struct {
+	Op    string
+	Topic string
+	Code  int16
+}
+
+func (e *kafkaError) Error() string {
+	return fmt.Sprintf("kafka %s %s: error code %d", e.Op, e.Topic, e.Code)
+}
+
+// 发布一条消息，等待所有同步副本确认（acks=-1）
+func (c *kafkaClient) Publish(ctx context.Context, topic, key string, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	meta, err := c.topicMeta(ctx, topic)
+	if err != nil {
+		return err
+	}
+	partition := kafkaPartition([]byte(key), len(meta.leaders))
+	leader := meta.leaders[partition]
+	conn, err := c.conn(ctx, leader)
+	if err != nil {
+		delete(c.topics, topic)
+		return err
+	}
+
+	resp, err := conn.roundTrip(ctx, kafkaAPIProduce, 3, produceRequest(topic, partition, []byte(key), value))
+	if err != nil {
+		c.closeConn(leader)
+		delete(c.topics, topic)
+		return err
+	}
+	if code, err := parseProduceResponse(resp); err != nil {
+		return err
+	} else if code != 0 {
+		if code == kafkaErrNotLeader || code == kafkaErrUnknownTopicOrPartition || code == kafkaErrLeaderNotAvailable {
+			delete(c.topics, topic)
+		}
+		return &kafkaError{Op: "produce", Topic: topic, Code: code}
+	}
+	return nil
+}
+
+// 主题的分区元数据，过期或出错后重新向 broker 查询
+func (c *kafkaClient) topicMeta(ctx context.Context, topic string) (*kafkaTopicMeta, error) {
+	if meta := c.topics[topic]; meta != nil && time.Since(meta.fetched) < kafkaMetadataTTL {
+		return meta, nil
+	}
+
+	var lastErr error
+	for _, addr := range c.bootstrap {
+		conn, err := dialKafka(ctx, addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp, err := conn.roundTrip(ctx, kafkaAPIMetadata, 4, metadataRequest(topic))
+		conn.close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		brokers, leaders, code, err := parseMetadataResponse(resp, topic)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for id, addr := range brokers {
+			if c.brokers[id] != addr {
+				c.closeConn(id)
+				c.brokers[id] = addr
+			}
+		}
+		if code != 0 {
+			return nil, &kafkaError{Op: "metadata", Topic: topic, Code: code}
+		}
+		if len(leaders) == 0 {
+			return nil, fmt.Errorf("kafka metadata %s: topic has no partitions", topic)
+		}
+		meta := &kafkaTopicMeta{leaders: leaders, fetched: time.Now()}
+		c.topics[topic] = meta
+		return meta, nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no kafka brokers configured")
+	}
+	return nil, lastErr
+}
+
+// 到 broker 的连接，复用已有的连接
+func (c *kafkaClient) conn(ctx context.Context, id int32) (*kafkaConn, error) {
+	if conn := c.conns[id]; conn != nil {
+		return conn, nil
+	}
+	addr, ok := c.brokers[id]
+	if !ok {
+		return nil, fmt.Errorf("kafka: unknown broker %d", id)
+	}
+	conn, err := dialKafka(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	c.conns[id] = conn
+	return conn, nil
+}
+
+func (c *kafkaClient) closeConn(id int32) {
+	if conn := c.conns[id]; conn != nil {
+		conn.close()
+		delete(c.conns, id)
+	}
+}
+
+// 与 Java 客户端相同的 murmur2 哈希
+func kafkaPartition(key []byte, partitions int) int {
+	const m = 0x5bd1e995
+	n := len(key)
+	h := uint32(0x9747b28c) ^ uint32(n)
+	for i := 0; i+4 <= n; i += 4 {
+		k := binary.LittleEndian.Uint32(key[i:])
+		k *= m
+		k ^= k >> 24
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := key[n&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int(h&0x7fffffff) % partitions
+}
+
+// 到一个 broker 的连接，请求按顺序发送和读取
+type kafkaConn struct {
+	conn          net.Conn
+	correlationID int32
+}
+
+func dialKafka(ctx context.Context, addr string) (*kafkaConn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaConn{conn: conn}, nil
+}
+
+func (kc *kafkaConn) close() {
+	kc.conn.Close()
+}
+
+// 发送请求（请求头 v1）并读取响应（响应头 v0）
+func (kc *kafkaConn) roundTrip(ctx context.Context, apiKey, version int16, body []byte) ([]byte, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(30 * time.Second)
+	}
+	kc.conn.SetDeadline(deadline)
+
+	kc.correlationID++
+	var req kafkaWriter
+	req.int32(0) // 长度，最后填写
+	req.int16(apiKey)
+	req.int16(version)
+	req.int32(kc.correlationID)
+	req.string("rss-zhuaqu")
+	req.b = append(req.b, body...)
+	binary.BigEndian.PutUint32(req.b, uint32(len(req.b)-4))
+	if _, err := kc.conn.Write(req.b); err != nil {
+		return nil, err
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(kc.conn, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n < 4 || n > 64<<20 {
+		return nil, fmt.Errorf("kafka: invalid response size %d", n)
+	}
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(kc.conn, resp); err != nil {
+		return nil, err
+	}
+	if id := int32(binary.BigEndian.Uint32(resp)); id != kc.correlationID {
+		return nil, fmt.Errorf("kafka: correlation ID %d does not match request %d", id, kc.correlationID)
+	}
+	return resp[4:], nil
+}
+
+// Metadata v4 请求：查询一个主题，不存在时由 broker 按配置自动创建
+func metadataRequest(topic string) []byte {
+	var w kafkaWriter
+	w.int32(1)
+	w.string(topic)
+	w.int8(1)
+	return w.b
+}
+
+// 解析 Metadata v4 响应，返回 broker 地址、主题各分区的 leader 和主题的错误码
+func parseMetadataResponse(b []byte, topic string) (map[int32]string, []int32, int16, error) {
+	r := kafkaReader{b: b}
+	r.int32() // throttle_time_ms
+	brokers := make(map[int32]string)
+	for n := r.int32(); n > 0 && r.err == nil; n-- {
+		id := r.int32()
+		host := r.string()
+		port := r.int32()
+		r.string() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	r.string() // cluster_id
+	r.int32()  // controller_id
+
+	var leaders []int32
+	code := int16(kafkaErrUnknownTopicOrPartition)
+	for n := r.int32(); n > 0 && r.err == nil; n-- {
+		topicCode := r.int16()
+		name := r.string()
+		r.int8() // is_internal
+		var parts []int32
+		var indexes []int32
+		for p := r.int32(); p > 0 && r.err == nil; p-- {
+			r.int16() // partition error_code
+			indexes = append(indexes, r.int32())
+			parts = append(parts, r.int32())
+			r.int32Array() // replica_nodes
+			r.int32Array() // isr_nodes
+		}
+		if name == topic {
+			code = topicCode
+			leaders = make([]int32, len(parts))
+			for i, idx := range indexes {
+				if int(idx) >= len(leaders) || idx < 0 {
+					return nil, nil, 0, fmt.Errorf("kafka metadata %s: invalid partition %d", topic, idx)
+				}
+				leaders[idx] = parts[i]
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, nil, 0, r.err
+	}
+	return brokers, leaders, code, nil
+}
+
+// Produce v3 请求：一个分区，一条记录
+func produceRequest(topic string, partition int, key, value []byte) []byte {
+	batch := recordBatch(key, value, time.Now().UnixMilli())
+	var w kafkaWriter
+	w.int16(-1) // transactional_id 为 null
+	w.int16(-1) // acks=all
+	w.int32(10000)
+	w.int32(1)
+	w.string(topic)
+	w.int32(1)
+	w.int32(int32(partition))
+	w.int32(int32(len(batch)))
+	w.b = append(w.b, batch...)
+	return w.b
+}
+
+// 解析 Produce v3 响应，返回第一个分区的错误码
+func parseProduceResponse(b []byte) (int16, error) {
+	r := kafkaReader{b: b}
+	if n := r.int32(); n < 1 {
+		return 0, errors.New("kafka produce: empty response")
+	}
+	r.string() // name
+	if n := r.int32(); n < 1 {
+		return 0, errors.New("kafka produce: empty response")
+	}
+	r.int32() // partition index
+	code := r.int16()
+	return code, r.err
+}
+
+// RecordBatch（magic 2），只包含一条记录，CRC 为 CRC-32C
+func recordBatch(key, value []byte, timestamp int64) []byte {
+	var rec kafkaWriter
+	rec.int8(0)   // attributes
+	rec.varint(0) // timestamp delta
+	rec.varint(0) // offset delta
+	rec.varBytes(key)
+	rec.varBytes(value)
+	rec.varint(0) // headers
+
+	var body kafkaWriter
+	body.int16(0) // attributes：不压缩，CreateTime
+	body.int32(0) // last offset delta
+	body.int64(timestamp)
+	body.int64(timestamp)
+	body.int64(-1) // producer id
+	body.int16(-1) // producer epoch
+	body.int32(-1) // base sequence
+	body.int32(1)  // 记录数
+	body.varint(int64(len(rec.b)))
+	body.b = append(body.b, rec.b...)
+
+	var w kafkaWriter
+	w.int64(0) // base offset
+	w.int32(int32(4 + 1 + 4 + len(body.b)))
+	w.int32(-1) // partition leader epoch
+	w.int8(2)   // magic
+	w.int32(int32(crc32.Checksum(body.b, crc32c)))
+	w.b = append(w.b, body.b...)
+	return w.b
+}
+
+// Kafka 协议的编码：大端整数，字符串带 int16 长度，记录中的变长整数为 zigzag 编码
+type kafkaWriter struct {
+	b []byte
+}
+
+func (w *kafkaWriter) int8(v int8)   { w.b = append(w.b, byte(v)) }
+func (w *kafkaWriter) int16(v int16) { w.b = binary.BigEndian.AppendUint16(w.b, uint16(v)) }
+func (w *kafkaWriter) int32(v int32) { w.b = binary.BigEndian.AppendUint32(w.b, uint32(v)) }
+func (w *kafkaWriter) int64(v int64) { w.b = binary.BigEndian.AppendUint64(w.b, uint64(v)) }
+func (w *kafkaWriter) varint(v int64) {
+	w.b = binary.AppendVarint(w.b, v)
+}
+
+func (w *kafkaWriter) string(s string) {
+	w.int16(int16(len(s)))
+	w.b = append(w.b, s...)
+}
+
+// 变长长度的字节串，nil 编码为 -1
+func (w *kafkaWriter) varBytes(b []byte) {
+	if b == nil {
+		w.varint(-1)
+		return
+	}
+	w.varint(int64(len(b)))
+	w.b = append(w.b, b...)
+}
+
+// Kafka 协议的解码，出错后记录第一个错误，之后的读取返回零值
+type kafkaReader struct {
+	b   []byte
+	err error
+}
+
+func (r *kafkaReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.b) < n {
+		r.err = errors.New("kafka: truncated response")
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *kafkaReader) int8() int8 {
+	if b := r.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (r *kafkaReader) int16() int16 {
+	if b := r.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int32() int32 {
+	if b := r.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+// 字符串，null（长度 -1）返回空字符串
+func (r *kafkaReader) string() string {
+	n := r.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(r.next(int(n)))
+}
+
+func (r *kafkaReader) int32Array() {
+	for n := r.int32(); n > 0 && r.err == nil; n-- {
+		r.int32()
+	}
+}
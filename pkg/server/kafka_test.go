@@ -0,0 +1,233 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"testing"
+)
+
+// Java 客户端 UtilsTest 中 murmur2 的测试向量，分区号为 toPositive(murmur2(key)) % partitions
+func TestKafkaPartition(t *testing.T) {
+	tests := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+	for key, hash := range tests {
+		want := int(hash & 0x7fffffff)
+		if got := kafkaPartition([]byte(key), 1<<31); got != want {
+			t.Errorf("kafkaPartition(%q) = %d, want %d", key, got, want)
+		}
+		if got := kafkaPartition([]byte(key), 12); got != want%12 {
+			t.Errorf("kafkaPartition(%q, 12) = %d, want %d", key, got, want%12)
+		}
+	}
+}
+
+func TestCRC32C(t *testing.T) {
+	// CRC-32C 的标准校验值
+	if got := crc32.Checksum([]byte("123456789"), crc32c); got != 0xe3069283 {
+		t.Errorf("crc32c = %#x, want 0xe3069283", got)
+	}
+}
+
+func TestRecordBatch(t *testing.T) {
+	body := []byte{
+		0x00, 0x00, // attributes
+		0x00, 0x00, 0x00, 0x00, // last offset delta
+		0, 0, 0, 0, 0, 0, 0, 1, // base timestamp
+		0, 0, 0, 0, 0, 0, 0, 1, // max timestamp
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // producer id
+		0xff, 0xff, // producer epoch
+		0xff, 0xff, 0xff, 0xff, // base sequence
+		0x00, 0x00, 0x00, 0x01, // 记录数
+		0x10,       // 记录长度 8（zigzag）
+		0x00,       // attributes
+		0x00, 0x00, // timestamp delta, offset delta
+		0x02, 'k', // key
+		0x02, 'v', // value
+		0x00, // headers
+	}
+	var want []byte
+	want = append(want, 0, 0, 0, 0, 0, 0, 0, 0) // base offset
+	want = binary.BigEndian.AppendUint32(want, uint32(4+1+4+len(body)))
+	want = append(want, 0xff, 0xff, 0xff, 0xff, 2) // partition leader epoch, magic
+	want = binary.BigEndian.AppendUint32(want, crc32.Checksum(body, crc32c))
+	want = append(want, body...)
+
+	if got := recordBatch([]byte("k"), []byte("v"), 1); !bytes.Equal(got, want) {
+		t.Errorf("recordBatch:\n% x\nwant:\n% x", got, want)
+	}
+
+	// key 为 nil 时长度为 -1
+	got := recordBatch(nil, []byte("v"), 1)
+	if i := bytes.Index(got, []byte{0x01, 0x02, 'v'}); i < 0 {
+		t.Errorf("null key not encoded as -1: % x", got)
+	}
+}
+
+func TestProduceRequest(t *testing.T) {
+	req := produceRequest("rss.items", 2, []byte("k"), []byte("v"))
+	r := kafkaReader{b: req}
+	if id := r.int16(); id != -1 {
+		t.Errorf("transactional_id length = %d, want -1 (null)", id)
+	}
+	if acks := r.int16(); acks != -1 {
+		t.Errorf("acks = %d, want -1", acks)
+	}
+	if timeout := r.int32(); timeout != 10000 {
+		t.Errorf("timeout = %d", timeout)
+	}
+	if n := r.int32(); n != 1 {
+		t.Errorf("topics = %d", n)
+	}
+	if name := r.string(); name != "rss.items" {
+		t.Errorf("topic = %q", name)
+	}
+	if n := r.int32(); n != 1 {
+		t.Errorf("partitions = %d", n)
+	}
+	if p := r.int32(); p != 2 {
+		t.Errorf("partition = %d", p)
+	}
+	size := r.int32()
+	batch := r.next(int(size))
+	if r.err != nil || len(r.b) != 0 {
+		t.Fatalf("records size %d does not match the request (err %v, %d bytes left)", size, r.err, len(r.b))
+	}
+	if batch[16] != 2 {
+		t.Errorf("magic = %d, want 2", batch[16])
+	}
+	if crc := binary.BigEndian.Uint32(batch[17:]); crc != crc32.Checksum(batch[21:], crc32c) {
+		t.Errorf("crc = %#x does not match the batch", crc)
+	}
+}
+
+func TestParseMetadataResponse(t *testing.T) {
+	var w kafkaWriter
+	w.int32(0) // throttle_time_ms
+	w.int32(2) // brokers
+	for _, b := range []struct {
+		id   int32
+		host string
+		port int32
+	}{{1, "kafka-1", 9092}, {2, "kafka-2", 9093}} {
+		w.int32(b.id)
+		w.string(b.host)
+		w.int32(b.port)
+		w.int16(-1) // rack
+	}
+	w.string("cluster")
+	w.int32(1) // controller_id
+	w.int32(2) // topics
+	// 其他主题
+	w.int16(0)
+	w.string("other")
+	w.int8(0)
+	w.int32(0)
+	// 分区乱序返回
+	w.int16(0)
+	w.string("rss.items")
+	w.int8(0)
+	w.int32(2)
+	for _, p := range [][2]int32{{1, 2}, {0, 1}} {
+		w.int16(0)
+		w.int32(p[0]) // partition
+		w.int32(p[1]) // leader
+		w.int32(1)    // replicas
+		w.int32(p[1])
+		w.int32(1) // isr
+		w.int32(p[1])
+	}
+
+	brokers, leaders, code, err := parseMetadataResponse(w.b, "rss.items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 0 {
+		t.Errorf("code = %d", code)
+	}
+	if brokers[1] != "kafka-1:9092" || brokers[2] != "kafka-2:9093" {
+		t.Errorf("brokers = %v", brokers)
+	}
+	if len(leaders) != 2 || leaders[0] != 1 || leaders[1] != 2 {
+		t.Errorf("leaders = %v, want [1 2]", leaders)
+	}
+
+	// 响应中没有该主题
+	if _, _, code, err := parseMetadataResponse(w.b, "missing"); err != nil || code != kafkaErrUnknownTopicOrPartition {
+		t.Errorf("missing topic: code %d, err %v", code, err)
+	}
+	// 截断的响应
+	if _, _, _, err := parseMetadataResponse(w.b[:len(w.b)-3], "rss.items"); err == nil {
+		t.Error("truncated response: expected an error")
+	}
+}
+
+func TestParseProduceResponse(t *testing.T) {
+	var w kafkaWriter
+	w.int32(1)
+	w.string("rss.items")
+	w.int32(1)
+	w.int32(0)  // partition
+	w.int16(6)  // NOT_LEADER_FOR_PARTITION
+	w.int64(-1) // base offset
+	w.int64(-1) // log append time
+	w.int32(0)  // throttle_time_ms
+	code, err := parseProduceResponse(w.b)
+	if err != nil || code != kafkaErrNotLeader {
+		t.Errorf("parseProduceResponse = %d, %v", code, err)
+	}
+	if _, err := parseProduceResponse([]byte{0, 0, 0, 0}); err == nil {
+		t.Error("empty response: expected an error")
+	}
+}
+
+// 请求头 v1：长度、api_key、api_version、correlation_id、client_id
+func TestKafkaRoundTrip(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	kc := &kafkaConn{conn: client}
+
+	errc := make(chan error, 1)
+	go func() {
+		defer server.Close()
+		var size [4]byte
+		if _, err := io.ReadFull(server, size[:]); err != nil {
+			errc <- err
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(server, req); err != nil {
+			errc <- err
+			return
+		}
+		want := []byte{0, 3, 0, 4, 0, 0, 0, 1, 0, 10}
+		want = append(want, "rss-zhuaqu"...)
+		want = append(want, "body"...)
+		if !bytes.Equal(req, want) {
+			t.Errorf("request:\n% x\nwant:\n% x", req, want)
+		}
+		resp := []byte{0, 0, 0, 7, 0, 0, 0, 1, 'o', 'k', '!'}
+		_, err := server.Write(resp)
+		errc <- err
+	}()
+
+	resp, err := kc.roundTrip(context.Background(), kafkaAPIMetadata, 4, []byte("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp) != "ok!" {
+		t.Errorf("response = %q", resp)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+func newInstanceID() string {
+	host, _ := os.Hostname()
+	return host + "-" + randomID()
+}
+
+// 是否由本实例抓取：维护模式下不抓取，未开启选举时总是抓取，开启时只有 leader 抓取
+func (srv *Server) refreshesEnabled() bool {
+	return !srv.maintenanceMode() && (!srv.cfg.LeaderElection || srv.isLeader.Load())
+}
+
+// 开始参与选举，先同步竞选一次，之后每 leaderTTL/3 续约或竞选
+func (srv *Server) startLeaderElection() error {
+	elector, ok := srv.store.(LeaderElector)
+	if !ok {
+		return fmt.Errorf("leader election requires the redis storage")
+	}
+
+	campaign := func() {
+		leader, err := elector.Campaign(srv.instanceID, srv.cfg.LeaderTTL)
+		if err != nil {
+			slog.Error("Leader election failed", "error", err)
+			leader = false
+		}
+		if leader != srv.isLeader.Swap(leader) {
+			if leader {
+				slog.Info("Became the refresh leader", "instance", srv.instanceID)
+			} else {
+				slog.Info("No longer the refresh leader", "instance", srv.instanceID)
+			}
+		}
+	}
+
+	campaign()
+	if !srv.isLeader.Load() {
+		slog.Info("Following, serving from shared storage", "instance", srv.instanceID)
+	}
+	go func() {
+		for range time.Tick(srv.cfg.LeaderTTL / 3) {
+			campaign()
+		}
+	}()
+	return nil
+}
+
+// 退出前主动让出 leader，其他实例无需等待租约过期
+func (srv *Server) resignLeader() {
+	if !srv.isLeader.Load() {
+		return
+	}
+	if elector, ok := srv.store.(LeaderElector); ok {
+		if err := elector.Resign(srv.instanceID); err != nil {
+			slog.Error("Failed to resign leadership", "error", err)
+		}
+	}
+}
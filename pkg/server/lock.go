@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// 等待其他实例释放锁时的轮询间隔
+const refreshLockPoll = 500 * time.Millisecond
+
+// 存储支持 Locker 时获取网站的刷新锁，锁被其他实例持有时等待其释放。
+// 等待期间其他实例刷新了缓存时不再抓取，refreshed 为 true 并返回新缓存。ctx 取消时返回其错误
+func (srv *Server) acquireRefreshLock(ctx context.Context, site string) (unlock func(), fc FeedCache, refreshed bool, err error) {
+	noop := func() {}
+	locker, ok := srv.store.(Locker)
+	if !ok {
+		return noop, FeedCache{}, false, nil
+	}
+
+	start := time.Now()
+	for {
+		unlock, ok, err := locker.TryLock(site, srv.cfg.RefreshLockTTL)
+		if err != nil {
+			slog.Warn("Failed to acquire refresh lock, refreshing without it", "site", site, "error", err)
+			return noop, FeedCache{}, false, nil
+		}
+		if ok {
+			if cached, ok := srv.getCachedFeed(site); ok && cached.UpdatedAt.After(start) {
+				unlock()
+				return noop, cached, true, nil
+			}
+			return unlock, FeedCache{}, false, nil
+		}
+
+		if time.Since(start) > srv.cfg.RefreshLockTTL {
+			slog.Warn("Timed out waiting for refresh lock, refreshing without it", "site", site)
+			return noop, FeedCache{}, false, nil
+		}
+		select {
+		case <-time.After(refreshLockPoll):
+		case <-ctx.Done():
+			return noop, FeedCache{}, false, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (srv *Server) maintenanceMode() bool {
+	srv.maintenanceMu.RLock()
+	defer srv.maintenanceMu.RUnlock()
+	return !srv.maintenanceSince.IsZero()
+}
+
+func (srv *Server) setMaintenance(enabled bool, reason string) {
+	srv.maintenanceMu.Lock()
+	defer srv.maintenanceMu.Unlock()
+	if !enabled {
+		srv.maintenanceSince, srv.maintenanceReason = time.Time{}, ""
+		return
+	}
+	if srv.maintenanceSince.IsZero() {
+		srv.maintenanceSince = time.Now()
+	}
+	srv.maintenanceReason = reason
+}
+
+type maintenanceStatus struct {
+	Enabled bool       `json:"enabled"`
+	Since   *time.Time `json:"since,omitempty"`
+	Reason  string     `json:"reason,omitempty"`
+}
+
+func (srv *Server) getMaintenance() maintenanceStatus {
+	srv.maintenanceMu.RLock()
+	defer srv.maintenanceMu.RUnlock()
+	if srv.maintenanceSince.IsZero() {
+		return maintenanceStatus{}
+	}
+	since := srv.maintenanceSince
+	return maintenanceStatus{Enabled: true, Since: &since, Reason: srv.maintenanceReason}
+}
+
+// 维护模式下返回的订阅源带上说明其新旧程度的响应头：
+// X-Maintenance-Mode 为进入维护模式的时间，Age 为缓存生成至今的秒数，已过期时加上 Warning
+func (srv *Server) setMaintenanceHeaders(w http.ResponseWriter, fc FeedCache) {
+	m := srv.getMaintenance()
+	if !m.Enabled {
+		return
+	}
+	w.Header().Set("X-Maintenance-Mode", "since="+m.Since.UTC().Format(time.RFC3339))
+	if !fc.UpdatedAt.IsZero() {
+		w.Header().Set("Age", strconv.Itoa(int(time.Since(fc.UpdatedAt).Seconds())))
+	}
+	if time.Now().After(fc.ExpireAt) && w.Header().Get("Warning") == "" {
+		w.Header().Set("Warning", `110 - "Response is Stale"`)
+	}
+}
+
+// /admin/maintenance：GET 返回维护模式的状态；
+// POST ?enabled=true|false&reason=... 开启或关闭，enabled 默认为 true
+func (srv *Server) maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, srv.getMaintenance())
+	case http.MethodPost:
+		enabled := true
+		if v := r.URL.Query().Get("enabled"); v != "" {
+			var err error
+			if enabled, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "Invalid 'enabled' parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		reason := r.URL.Query().Get("reason")
+		srv.setMaintenance(enabled, reason)
+		if enabled {
+			slog.Warn("Maintenance mode enabled, background refreshes paused", "reason", reason)
+		} else {
+			slog.Info("Maintenance mode disabled, refreshes resumed")
+		}
+		writeJSON(w, http.StatusOK, srv.getMaintenance())
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 合并订阅源最多包含的网站数
+const maxMergedSites = 20
+
+// 请求的地址前缀（scheme://host）
+func (srv *Server) requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := srv.forwardedHeader(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	host := r.Host
+	if h := srv.forwardedHeader(r, "X-Forwarded-Host"); h != "" {
+		host = h
+	}
+	return scheme + "://" + host + srv.requestBasePath(r)
+}
+
+// 获取网站的订阅源用于合并：有缓存时直接使用（过期时在后台刷新），没有时同步抓取
+func (srv *Server) siteFeed(ctx context.Context, site string) (FeedCache, error) {
+	if cached, ok := srv.getCachedFeed(site); ok {
+		if time.Now().After(cached.ExpireAt) && srv.refreshesEnabled() && !srv.siteDisabled(site) {
+			go srv.refreshCache(site)
+		}
+		return cached, nil
+	}
+	if srv.maintenanceMode() {
+		return FeedCache{}, fmt.Errorf("feed is not cached and refreshes are paused for maintenance")
+	}
+	if !srv.refreshesEnabled() {
+		return FeedCache{}, fmt.Errorf("feed is not cached yet")
+	}
+	if srv.siteDisabled(site) {
+		return FeedCache{}, fmt.Errorf("site is disabled")
+	}
+	ctx, cancel := srv.fetchContext(ctx)
+	defer cancel()
+	return srv.refreshCacheContext(ctx, site)
+}
+
+// /rss?sites=a,b,c：把多个网站的条目按发布时间倒序合并为一个订阅源，
+// title 为订阅源标题。单个网站失败时跳过，全部失败时返回 502
+func (srv *Server) mergedFeedHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	var sites []string
+	seen := make(map[string]bool)
+	for _, site := range splitList(params.Get("sites")) {
+		if !seen[site] {
+			seen[site] = true
+			sites = append(sites, site)
+		}
+	}
+	if len(sites) == 0 {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Missing 'sites' parameter")
+		return
+	}
+	if len(sites) > maxMergedSites {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("At most %d sites can be merged", maxMergedSites))
+		return
+	}
+	for _, site := range sites {
+		if _, ok := srv.getSiteConfig(site); !ok {
+			writeError(w, r, http.StatusNotFound, ErrCodeSiteNotFound, fmt.Sprintf("Unknown site: %s", site))
+			return
+		}
+		if !srv.checkFeedAuth(w, r, site) {
+			return
+		}
+		srv.countRequest(site)
+		srv.touchCachedFeed(site)
+	}
+
+	feeds := make([]FeedCache, len(sites))
+	errs := make([]error, len(sites))
+	var wg sync.WaitGroup
+	for i, site := range sites {
+		wg.Add(1)
+		go func(i int, site string) {
+			defer wg.Done()
+			feeds[i], errs[i] = srv.siteFeed(r.Context(), site)
+		}(i, site)
+	}
+	wg.Wait()
+
+	var names []string
+	merged := FeedCache{}
+	var items []Item
+	for i, site := range sites {
+		if errs[i] != nil {
+			slog.Warn("Skipping site in merged feed", "site", site, "error", errs[i])
+			continue
+		}
+		fc := feeds[i]
+		names = append(names, fc.Feed.Channel.Title)
+		if merged.ExpireAt.IsZero() || fc.ExpireAt.Before(merged.ExpireAt) {
+			merged.ExpireAt = fc.ExpireAt
+		}
+		if lastBuildAfter(fc.Feed.Channel.LastBuildDate, merged.Feed.Channel.LastBuildDate) {
+			merged.Feed.Channel.LastBuildDate = fc.Feed.Channel.LastBuildDate
+		}
+
+		source := &ItemSource{URL: srv.requestBaseURL(r) + "/rss?site=" + url.QueryEscape(site), Name: fc.Feed.Channel.Title}
+		for _, item := range fc.Feed.Channel.Items {
+			item.Source = source
+			items = append(items, item)
+		}
+	}
+	if len(names) == 0 {
+		writeError(w, r, fetchErrorStatus(errs[0], http.StatusBadGateway), fetchErrorCode(errs[0]), fmt.Sprintf("Failed to generate RSS: %v", errs[0]))
+		return
+	}
+
+	// 发布时间格式固定，按字符串比较即为时间顺序
+	sort.SliceStable(items, func(i, j int) bool { return items[i].PubDate > items[j].PubDate })
+	// 不同网站转载的同一篇文章只保留最早发布的，dedup=0 时不去重
+	if params.Get("dedup") != "0" {
+		items, _ = srv.dedupMerged(items, srv.cfg.MergeDedupThreshold)
+	}
+	// 指定了 limit 时由 shapeFeed 截取
+	if len(items) > srv.cfg.FeedItems && !params.Has("limit") {
+		items = items[:srv.cfg.FeedItems]
+	}
+
+	title := params.Get("title")
+	if title == "" {
+		title = strings.Join(names, " + ")
+	}
+	merged.Feed = RSSFeed{
+		Version: "2.0",
+		MediaNS: mediaRSSNamespace,
+		Channel: Channel{
+			Title:         title,
+			Link:          srv.requestBaseURL(r) + r.RequestURI,
+			Description:   "Merged feed of " + strings.Join(names, ", "),
+			LastBuildDate: merged.Feed.Channel.LastBuildDate,
+			Items:         items,
+		},
+	}
+	merged.Hash = feedHash(merged.Feed)
+	srv.writeFeedOf(w, r, sites, merged)
+}
+
+// a 是否晚于 b，b 为空时为 true
+func lastBuildAfter(a, b string) bool {
+	ta, err := time.Parse(time.RFC1123Z, a)
+	if err != nil {
+		return false
+	}
+	tb, err := time.Parse(time.RFC1123Z, b)
+	return err != nil || ta.After(tb)
+}
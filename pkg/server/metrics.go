@@ -0,0 +1,269 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Prometheus 指标，以文本格式在 /metrics 输出。只用到计数器、仪表和直方图，
+// 没有引入 client_golang
+
+// 直方图的桶（秒）
+var (
+	fetchBuckets   = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+	requestBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+)
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64 // 每个桶的计数（非累计）
+	count   uint64
+	sum     float64
+}
+
+func (h *histogram) observe(v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(h.buckets))
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func (srv *Server) observeFetch(site string, d time.Duration, err error) {
+	srv.metricsMu.Lock()
+	defer srv.metricsMu.Unlock()
+	h, ok := srv.fetchDurations[site]
+	if !ok {
+		h = &histogram{buckets: fetchBuckets}
+		srv.fetchDurations[site] = h
+	}
+	h.observe(d.Seconds())
+	if err != nil {
+		srv.fetchErrors[[2]string{site, errorClass(err)}]++
+	}
+}
+
+// result 为 hit、stale 或 miss
+func (srv *Server) observeCacheRequest(site, result string) {
+	srv.metricsMu.Lock()
+	srv.cacheRequests[[2]string{site, result}]++
+	srv.metricsMu.Unlock()
+}
+
+func (srv *Server) observeFeedItems(site string, n int) {
+	srv.metricsMu.Lock()
+	srv.feedItems[site] = n
+	srv.metricsMu.Unlock()
+}
+
+func (srv *Server) observeRequest(handler string, code int, d time.Duration) {
+	srv.metricsMu.Lock()
+	defer srv.metricsMu.Unlock()
+	key := [2]string{handler, strconv.Itoa(code)}
+	h, ok := srv.requestDurations[key]
+	if !ok {
+		h = &histogram{buckets: requestBuckets}
+		srv.requestDurations[key] = h
+	}
+	h.observe(d.Seconds())
+}
+
+func (srv *Server) observeNotification(sink, result string) {
+	srv.metricsMu.Lock()
+	defer srv.metricsMu.Unlock()
+	srv.notifications[[2]string{sink, result}]++
+}
+
+// 抓取错误的分类，用于告警时区分网络问题和页面结构变化
+func errorClass(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &opErr):
+		return "connection"
+	}
+	return "other"
+}
+
+// 记录响应的状态码和字节数
+type statusRecorder struct {
+	http.ResponseWriter
+	code  int
+	bytes int64
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// 供 http.ResponseController 访问底层的 ResponseWriter
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// 不记录链路的路由，避免探针和抓取指标产生大量 span
+var untracedRoutes = map[string]bool{"/metrics": true, "/status": true, "/healthz": true, "/readyz": true}
+
+// 记录每个请求的耗时，按注册的路由和状态码分组，避免路径过多。同时为请求开始服务端 span
+func (srv *Server) instrumentHandler(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		_, pattern := mux.Handler(r)
+
+		var sp *span
+		if !untracedRoutes[pattern] {
+			var ctx context.Context
+			ctx, sp = srv.startServerSpan(r, r.Method+" "+pattern)
+			r = r.WithContext(ctx)
+		}
+		mux.ServeHTTP(rec, r)
+		srv.observeRequest(pattern, rec.code, time.Since(start))
+
+		sp.SetAttr("http.request_id", requestID(r.Context()))
+		sp.SetAttr("http.method", r.Method)
+		sp.SetAttr("http.route", pattern)
+		sp.SetAttr("http.status_code", rec.code)
+		if rec.code >= 500 {
+			sp.SetError(fmt.Errorf("%s", http.StatusText(rec.code)))
+		}
+		sp.End()
+	})
+}
+
+// GET /metrics Prometheus 指标。租户网站的指标只在带管理令牌（或该租户密钥）抓取时输出
+func (srv *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	streaks := srv.failureStreaks()
+
+	srv.metricsMu.Lock()
+	defer srv.metricsMu.Unlock()
+
+	writeHelp(w, "rss_fetch_duration_seconds", "histogram", "Time spent fetching and generating a feed.")
+	for _, site := range sortedKeys(srv.fetchDurations) {
+		if !srv.siteVisible(r, site) {
+			continue
+		}
+		writeHistogram(w, "rss_fetch_duration_seconds", fmt.Sprintf("site=%q", site), srv.fetchDurations[site])
+	}
+
+	writeHelp(w, "rss_fetch_errors_total", "counter", "Failed feed refreshes by site and error class.")
+	for _, key := range sortedPairs(srv.fetchErrors) {
+		if !srv.siteVisible(r, key[0]) {
+			continue
+		}
+		fmt.Fprintf(w, "rss_fetch_errors_total{site=%q,class=%q} %d\n", key[0], key[1], srv.fetchErrors[key])
+	}
+
+	writeHelp(w, "rss_consecutive_failures", "gauge", "Consecutive failed refreshes of a site, reset on success.")
+	for _, site := range sortedKeys(streaks) {
+		if !srv.siteVisible(r, site) {
+			continue
+		}
+		fmt.Fprintf(w, "rss_consecutive_failures{site=%q} %d\n", site, streaks[site])
+	}
+
+	writeHelp(w, "rss_cache_requests_total", "counter", "Feed requests by site and cache result (hit, stale, miss).")
+	for _, key := range sortedPairs(srv.cacheRequests) {
+		if !srv.siteVisible(r, key[0]) {
+			continue
+		}
+		fmt.Fprintf(w, "rss_cache_requests_total{site=%q,result=%q} %d\n", key[0], key[1], srv.cacheRequests[key])
+	}
+
+	writeHelp(w, "rss_feed_items", "gauge", "Number of items in the last generated feed.")
+	for _, site := range sortedKeys(srv.feedItems) {
+		if !srv.siteVisible(r, site) {
+			continue
+		}
+		fmt.Fprintf(w, "rss_feed_items{site=%q} %d\n", site, srv.feedItems[site])
+	}
+
+	writeHelp(w, "rss_notifications_total", "counter", "New item notifications by sink and result (sent, retried, failed, dropped).")
+	for _, key := range sortedPairs(srv.notifications) {
+		fmt.Fprintf(w, "rss_notifications_total{sink=%q,result=%q} %d\n", key[0], key[1], srv.notifications[key])
+	}
+
+	writeHelp(w, "http_request_duration_seconds", "histogram", "HTTP request latencies by handler and status code.")
+	for _, key := range sortedPairs(srv.requestDurations) {
+		writeHistogram(w, "http_request_duration_seconds", fmt.Sprintf("handler=%q,code=%q", key[0], key[1]), srv.requestDurations[key])
+	}
+
+	writeHelp(w, "rss_refreshes_in_flight", "gauge", "Feed refreshes currently running.")
+	fmt.Fprintf(w, "rss_refreshes_in_flight %d\n", srv.refreshesInFlight.Load())
+	writeHelp(w, "rss_warmup_queue_length", "gauge", "Sites waiting in the warmup queue.")
+	fmt.Fprintf(w, "rss_warmup_queue_length %d\n", srv.warmupQueued.Load())
+	if srv.cfg.MaxConns > 0 {
+		writeHelp(w, "http_open_connections", "gauge", "Open connections to the main server, limited by -max-conns.")
+		fmt.Fprintf(w, "http_open_connections %d\n", srv.openConns.Load())
+	}
+	writeHelp(w, "go_goroutines", "gauge", "Number of goroutines that currently exist.")
+	fmt.Fprintf(w, "go_goroutines %d\n", runtime.NumGoroutine())
+}
+
+func writeHelp(w http.ResponseWriter, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeHistogram(w http.ResponseWriter, name, labels string, h *histogram) {
+	var cumulative uint64
+	for i, b := range h.buckets {
+		if h.counts != nil {
+			cumulative += h.counts[i]
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, strconv.FormatFloat(b, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %g\n", name, labels, h.sum)
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedPairs[V any](m map[[2]string]V) [][2]string {
+	keys := make([][2]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"log/slog"
+	"strings"
+	"time"
+
+	"rss-zhuaqu/pkg/scraper"
+)
+
+// 监视页面内容变化的网站类型
+const SiteTypeMonitor = "monitor"
+
+// 行数乘积超过这个值时不再计算逐行差异，直接显示新旧全文
+const maxDiffCells = 1000000
+
+// 抓取 monitor 网站的 WatchSelector 内容，与上次的内容不同时返回一条包含差异的新条目。
+// 同时返回本次的内容，由调用方随缓存保存
+func (srv *Server) monitorItems(ctx context.Context, site string, config SiteConfig, prev string, now time.Time) ([]Item, string, error) {
+	doc, err := srv.fetchDocument(ctx, config.URL)
+	if err != nil {
+		return nil, "", err
+	}
+	sel := doc.Find(config.WatchSelector)
+	if sel.Length() == 0 {
+		return nil, "", fmt.Errorf("WatchSelector %q matched nothing", config.WatchSelector)
+	}
+
+	var lines []string
+	for i := range sel.Nodes {
+		lines = append(lines, scraper.NormalizeText(sel.Eq(i).Text()))
+	}
+	value := strings.Join(lines, "\n")
+
+	if value == prev {
+		return nil, value, nil
+	}
+
+	// 没有上次的内容（缓存被清除或淘汰）但已有历史条目时只记录当前内容，避免误报
+	if prev == "" {
+		history, err := srv.store.ItemHistory(site, 1)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(history) > 0 {
+			slog.Info("No previous value, recording the current one", "site", site)
+			return nil, value, nil
+		}
+	}
+
+	title := fmt.Sprintf("%s: %s", config.Name, scraper.TruncateText(strings.ReplaceAll(value, "\n", " "), 80))
+	desc := "<pre>" + html.EscapeString(value) + "</pre>"
+	if prev != "" {
+		desc = "<pre>" + html.EscapeString(strings.Join(diffLines(prev, value), "\n")) + "</pre>"
+	}
+
+	sum := sha256.Sum256([]byte(value))
+	item := Item{
+		Title:       title,
+		Link:        config.URL,
+		Description: desc,
+		PubDate:     now.Format(pubDateLayout),
+		// 内容变回以前的值时也算一次新的变化，GUID 中加上时间
+		GUID: fmt.Sprintf("%s#%s-%d", config.URL, hex.EncodeToString(sum[:6]), now.Unix()),
+	}
+	return []Item{item}, value, nil
+}
+
+// 逐行比较新旧内容，删除的行以 "- " 开头，新增的行以 "+ " 开头，未变的行以两个空格开头
+func diffLines(oldText, newText string) []string {
+	a, b := strings.Split(oldText, "\n"), strings.Split(newText, "\n")
+	if len(a)*len(b) > maxDiffCells {
+		var out []string
+		for _, l := range a {
+			out = append(out, "- "+l)
+		}
+		for _, l := range b {
+			out = append(out, "+ "+l)
+		}
+		return out
+	}
+
+	// 最长公共子序列
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, "  "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "- "+a[i])
+			i++
+		default:
+			out = append(out, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		out = append(out, "+ "+b[j])
+	}
+	return out
+}
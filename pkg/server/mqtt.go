@@ -0,0 +1,223 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MQTT 发布：新条目发布到网站对应的主题（默认 rss/<网站名>），供 Home Assistant、Node-RED 等订阅。
+// 实现 MQTT 3.1.1 的 CONNECT 和 PUBLISH（QoS 0 或 1）
+
+const defaultMQTTTopic = "rss/{site}"
+
+func (srv *Server) mqttSink(site string, config SiteConfig, items []Item) []delivery {
+	routes := srv.cfg.MQTTTopics
+	if len(routes) == 0 {
+		routes = routeFlag{{Target: defaultMQTTTopic}}
+	}
+	return srv.busDeliveries("mqtt", srv.mqttPublisher, routes, site, config, items)
+}
+
+type mqttClient struct {
+	url      *url.URL
+	clientID string
+	qos      int
+	retain   bool
+
+	mu       sync.Mutex
+	conn     net.Conn
+	r        *bufio.Reader
+	packetID uint16
+}
+
+func newMQTTClient(addr string) *mqttClient {
+	if !strings.Contains(addr, "://") {
+		addr = "mqtt://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		u = &url.URL{Scheme: "mqtt", Host: addr}
+	}
+	if u.Port() == "" {
+		port := "1883"
+		if u.Scheme == "mqtts" || u.Scheme == "ssl" {
+			port = "8883"
+		}
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return &mqttClient{url: u}
+}
+
+// MQTT 控制报文类型
+const (
+	mqttConnect = 1
+	mqttConnack = 2
+	mqttPublish = 3
+	mqttPuback  = 4
+)
+
+func (c *mqttClient) connect(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.url.Host)
+	if err != nil {
+		return err
+	}
+	if c.url.Scheme == "mqtts" || c.url.Scheme == "ssl" {
+		conn = tls.Client(conn, &tls.Config{ServerName: c.url.Hostname()})
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// 可变报头：协议名、级别 4（3.1.1）、连接标志、保持连接时间（0 表示不检测）
+	flags := byte(0x02) // clean session
+	var payload []byte
+	payload = mqttAppendString(payload, c.clientID)
+	if u := c.url.User; u != nil {
+		flags |= 0x80
+		payload = mqttAppendString(payload, u.Username())
+		if pass, ok := u.Password(); ok {
+			flags |= 0x40
+			payload = mqttAppendString(payload, pass)
+		}
+	}
+	body := mqttAppendString(nil, "MQTT")
+	body = append(body, 4, flags, 0, 0)
+	body = append(body, payload...)
+	if _, err := conn.Write(mqttPacket(mqttConnect<<4, body)); err != nil {
+		conn.Close()
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+	typ, resp, err := mqttReadPacket(r)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if typ != mqttConnack || len(resp) < 2 {
+		conn.Close()
+		return fmt.Errorf("mqtt: expected CONNACK, got packet type %d", typ)
+	}
+	if resp[1] != 0 {
+		conn.Close()
+		return fmt.Errorf("mqtt: connection refused, return code %d", resp[1])
+	}
+	c.conn, c.r = conn, r
+	return nil
+}
+
+func (c *mqttClient) close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn, c.r = nil, nil
+	}
+}
+
+// 发布一条消息，QoS 1 时等待 PUBACK。出错时关闭连接，下次发布时重新连接。key 不使用
+func (c *mqttClient) Publish(ctx context.Context, topic, key string, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return err
+		}
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(10 * time.Second)
+	}
+	c.conn.SetDeadline(deadline)
+
+	header := byte(mqttPublish<<4) | byte(c.qos<<1)
+	if c.retain {
+		header |= 0x01
+	}
+	body := mqttAppendString(nil, topic)
+	if c.qos > 0 {
+		c.packetID++
+		if c.packetID == 0 {
+			c.packetID = 1
+		}
+		body = binary.BigEndian.AppendUint16(body, c.packetID)
+	}
+	body = append(body, value...)
+	if _, err := c.conn.Write(mqttPacket(header, body)); err != nil {
+		c.close()
+		return err
+	}
+	if c.qos == 0 {
+		return nil
+	}
+
+	for {
+		typ, resp, err := mqttReadPacket(c.r)
+		if err != nil {
+			c.close()
+			return err
+		}
+		if typ == mqttPuback && len(resp) >= 2 && binary.BigEndian.Uint16(resp) == c.packetID {
+			return nil
+		}
+	}
+}
+
+// 报文：固定报头、剩余长度（变长编码）和报文内容
+func mqttPacket(header byte, body []byte) []byte {
+	p := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		p = append(p, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(p, body...)
+}
+
+func mqttAppendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// 读取一个报文，返回类型和内容
+func mqttReadPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, shift := 0, 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return 0, nil, errors.New("mqtt: invalid remaining length")
+		}
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
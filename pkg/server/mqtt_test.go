@@ -0,0 +1,140 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// MQTT 3.1.1 规范 2.2.3 中剩余长度的编码示例
+func TestMQTTRemainingLength(t *testing.T) {
+	tests := []struct {
+		n    int
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{16383, []byte{0xff, 0x7f}},
+		{16384, []byte{0x80, 0x80, 0x01}},
+		{2097151, []byte{0xff, 0xff, 0x7f}},
+		{2097152, []byte{0x80, 0x80, 0x80, 0x01}},
+	}
+	for _, tt := range tests {
+		body := bytes.Repeat([]byte{'x'}, tt.n)
+		p := mqttPacket(mqttPublish<<4, body)
+		if p[0] != mqttPublish<<4 || !bytes.Equal(p[1:1+len(tt.want)], tt.want) {
+			t.Errorf("remaining length %d = % x, want % x", tt.n, p[1:1+len(tt.want)], tt.want)
+			continue
+		}
+		typ, got, err := mqttReadPacket(bufio.NewReader(bytes.NewReader(p)))
+		if err != nil || typ != mqttPublish || len(got) != tt.n {
+			t.Errorf("mqttReadPacket(%d) = %d, %d bytes, %v", tt.n, typ, len(got), err)
+		}
+	}
+
+	// 剩余长度最多 4 个字节
+	if _, _, err := mqttReadPacket(bufio.NewReader(bytes.NewReader([]byte{0x30, 0xff, 0xff, 0xff, 0xff, 0x01}))); err == nil {
+		t.Error("5-byte remaining length: expected an error")
+	}
+}
+
+// 启动一个只接受一个连接的 broker，handle 处理该连接
+func startTestBroker(t *testing.T, handle func(r *bufio.Reader, conn net.Conn)) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(bufio.NewReader(conn), conn)
+	}()
+	return ln.Addr().String()
+}
+
+func TestMQTTPublish(t *testing.T) {
+	connect := make(chan []byte, 1)
+	publish := make(chan []byte, 1)
+	var publishHeader byte
+	addr := startTestBroker(t, func(r *bufio.Reader, conn net.Conn) {
+		typ, body, err := mqttReadPacket(r)
+		if err != nil || typ != mqttConnect {
+			t.Errorf("expected CONNECT, got %d, %v", typ, err)
+			return
+		}
+		connect <- body
+		conn.Write([]byte{mqttConnack << 4, 2, 0, 0})
+
+		header, _ := r.Peek(1)
+		publishHeader = header[0]
+		typ, body, err = mqttReadPacket(r)
+		if err != nil || typ != mqttPublish {
+			t.Errorf("expected PUBLISH, got %d, %v", typ, err)
+			return
+		}
+		publish <- body
+		conn.Write(mqttPacket(mqttPuback<<4, body[len(body)-len("hello")-2:len(body)-len("hello")]))
+	})
+
+	c := newMQTTClient("mqtt://user:pass@" + addr)
+	c.clientID, c.qos, c.retain = "rss", 1, true
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Publish(ctx, "rss/blog", "blog", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	defer c.close()
+
+	// 协议名 MQTT、级别 4、标志（用户名、密码、clean session）、keep alive 0，之后为客户端 ID、用户名、密码
+	wantConnect := []byte{0, 4, 'M', 'Q', 'T', 'T', 4, 0xc2, 0, 0, 0, 3, 'r', 's', 's', 0, 4, 'u', 's', 'e', 'r', 0, 4, 'p', 'a', 's', 's'}
+	if got := <-connect; !bytes.Equal(got, wantConnect) {
+		t.Errorf("CONNECT:\n% x\nwant:\n% x", got, wantConnect)
+	}
+	// QoS 1、retain：主题、报文标识符 1、内容
+	wantPublish := append([]byte{0, 8}, "rss/blog"...)
+	wantPublish = append(wantPublish, 0, 1)
+	wantPublish = append(wantPublish, "hello"...)
+	if got := <-publish; !bytes.Equal(got, wantPublish) {
+		t.Errorf("PUBLISH:\n% x\nwant:\n% x", got, wantPublish)
+	}
+	if publishHeader != 0x33 {
+		t.Errorf("PUBLISH header = %#x, want 0x33", publishHeader)
+	}
+}
+
+func TestMQTTConnectRefused(t *testing.T) {
+	addr := startTestBroker(t, func(r *bufio.Reader, conn net.Conn) {
+		mqttReadPacket(r)
+		conn.Write([]byte{mqttConnack << 4, 2, 0, 5}) // not authorized
+	})
+	c := newMQTTClient(addr)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := c.Publish(ctx, "rss/blog", "blog", []byte("hello"))
+	if err == nil || !strings.Contains(err.Error(), "return code 5") {
+		t.Errorf("Publish() error = %v, want connection refused with return code 5", err)
+	}
+}
+
+func TestNewMQTTClient(t *testing.T) {
+	tests := map[string]string{
+		"broker":                  "broker:1883",
+		"mqtt://broker":           "broker:1883",
+		"mqtts://broker":          "broker:8883",
+		"mqtt://u:p@broker:11883": "broker:11883",
+	}
+	for addr, want := range tests {
+		if got := newMQTTClient(addr).url.Host; got != want {
+			t.Errorf("newMQTTClient(%q) host = %q, want %q", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATS 客户端，只实现发布：CONNECT 后用 PUB 发布消息，再用 PING/PONG 确认服务器已处理。
+// 地址为 nats://[user:password@]host:port 或 tls://host:port，令牌认证为 nats://token@host:port
+type natsClient struct {
+	url *url.URL
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newNATSClient(addr string) *natsClient {
+	if !strings.Contains(addr, "://") {
+		addr = "nats://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		u = &url.URL{Scheme: "nats", Host: addr}
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	return &natsClient{url: u}
+}
+
+// 服务器的 INFO
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+	MaxPayload  int  `json:"max_payload"`
+}
+
+func (c *natsClient) connect(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.url.Host)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	payload, ok := strings.CutPrefix(strings.TrimSpace(line), "INFO ")
+	if !ok {
+		conn.Close()
+		return fmt.Errorf("unexpected greeting %q", strings.TrimSpace(line))
+	}
+	var info natsInfo
+	json.Unmarshal([]byte(payload), &info)
+	if info.TLSRequired || c.url.Scheme == "tls" {
+		conn = tls.Client(conn, &tls.Config{ServerName: c.url.Hostname()})
+		r = bufio.NewReader(conn)
+	}
+
+	opts := map[string]interface{}{"verbose": false, "pedantic": false, "lang": "go", "version": "1.0", "name": "rss-zhuaqu", "protocol": 0}
+	if u := c.url.User; u != nil {
+		if pass, ok := u.Password(); ok {
+			opts["user"], opts["pass"] = u.Username(), pass
+		} else {
+			opts["auth_token"] = u.Username()
+		}
+	}
+	data, _ := json.Marshal(opts)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", data); err != nil {
+		conn.Close()
+		return err
+	}
+	c.conn, c.r = conn, r
+	if err := c.waitPong(); err != nil {
+		c.close()
+		return fmt.Errorf("connect: %w", err)
+	}
+	return nil
+}
+
+// 读到 PONG 为止，期间回应服务器的 PING，-ERR 返回错误
+func (c *natsClient) waitPong() error {
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := c.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+func (c *natsClient) close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn, c.r = nil, nil
+	}
+}
+
+// 发布一条消息，出错时关闭连接，下次发布时重新连接。key 不使用
+func (c *natsClient) Publish(ctx context.Context, subject, key string, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return err
+		}
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(10 * time.Second)
+	}
+	c.conn.SetDeadline(deadline)
+
+	msg := fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", subject, len(value), value)
+	if _, err := c.conn.Write([]byte(msg)); err != nil {
+		c.close()
+		return err
+	}
+	if err := c.waitPong(); err != nil {
+		c.close()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 新条目推送：刷新发现新条目后，各推送方式（webhook 等）生成要投递的消息，
+// 由后台队列发送，失败时按退避时间重试
+
+var (
+	// 每条消息最多发送的次数（包括第一次）
+	notifyAttempts = 4
+	// 第一次重试前等待的时间，之后每次乘以 4
+	notifyRetryDelay = 2 * time.Second
+)
+
+// 同时发送消息的 goroutine 数
+const notifyWorkers = 4
+
+// 重试等待时间的上限，包括服务端通过 Retry-After 要求的等待时间
+const maxRetryDelay = 5 * time.Minute
+
+// 一条要投递的消息
+type delivery struct {
+	// 推送方式和目标，用于日志和指标
+	sink   string
+	target string
+	site   string
+	send   func(ctx context.Context) error
+
+	attempt int
+}
+
+// 推送方式：返回网站的新条目需要投递的消息，不需要推送时返回 nil
+type itemSink func(site string, config SiteConfig, items []Item) []delivery
+
+// 把新条目交给各推送方式
+func (srv *Server) deliverNewItems(site string, items []Item) {
+	if len(srv.itemSinks) == 0 || len(items) == 0 {
+		return
+	}
+	config, _ := srv.getSiteConfig(site)
+	for _, sink := range srv.itemSinks {
+		for _, d := range sink(site, config, items) {
+			srv.enqueueDelivery(d)
+		}
+	}
+}
+
+func (srv *Server) enqueueDelivery(d delivery) {
+	select {
+	case srv.deliveries <- d:
+	default:
+		slog.Warn("Notification queue is full, message dropped", "sink", d.sink, "target", d.target, "site", d.site)
+		srv.observeNotification(d.sink, "dropped")
+	}
+}
+
+// 启动发送消息的 goroutine
+func (srv *Server) startNotifier() {
+	for i := 0; i < notifyWorkers; i++ {
+		go func() {
+			for d := range srv.deliveries {
+				srv.sendDelivery(d)
+			}
+		}()
+	}
+}
+
+func (srv *Server) sendDelivery(d delivery) {
+	ctx, cancel := context.WithTimeout(srv.appCtx, 30*time.Second)
+	err := d.send(ctx)
+	cancel()
+	d.attempt++
+	if err == nil {
+		slog.Debug("Notification sent", "sink", d.sink, "target", d.target, "site", d.site)
+		srv.observeNotification(d.sink, "sent")
+		return
+	}
+
+	wait, retry := retryDelay(err, d.attempt)
+	if !retry || d.attempt >= notifyAttempts || srv.appCtx.Err() != nil {
+		slog.Error("Failed to send notification", "sink", d.sink, "target", d.target, "site", d.site, "attempts", d.attempt, "error", err)
+		srv.observeNotification(d.sink, "failed")
+		return
+	}
+	slog.Warn("Failed to send notification, retrying", "sink", d.sink, "target", d.target, "site", d.site, "attempt", d.attempt, "retry_in", wait, "error", err)
+	srv.observeNotification(d.sink, "retried")
+	time.AfterFunc(wait, func() { srv.enqueueDelivery(d) })
+}
+
+// 发送失败后是否重试以及等待的时间：网络错误、429 和 5xx 重试，其他状态码不重试；
+// SMTP 只重试 4xx 临时错误
+func retryDelay(err error, attempt int) (time.Duration, bool) {
+	wait := notifyRetryDelay
+	for i := 1; i < attempt; i++ {
+		wait *= 4
+	}
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) && smtpErr.Code >= 500 {
+		return 0, false
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.Status != http.StatusTooManyRequests && statusErr.Status < 500 {
+			return 0, false
+		}
+		if statusErr.RetryAfter > 0 {
+			wait = statusErr.RetryAfter
+		}
+	}
+	return min(wait, maxRetryDelay), true
+}
+
+// 推送目标返回了错误的状态码
+type httpStatusError struct {
+	Status     int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *httpStatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("HTTP %d", e.Status)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.Status, e.Body)
+}
+
+// POST 请求体，返回非 2xx 状态码时返回 *httpStatusError
+func postBody(ctx context.Context, target, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentType)
+	return doNotifyRequest(req)
+}
+
+// 发送请求并检查状态码
+func doNotifyRequest(req *http.Request) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	statusErr := &httpStatusError{Status: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		statusErr.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return statusErr
+}
+
+// 可以重复指定的参数，每次的值也可以是逗号分隔的列表
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *listFlag) Set(value string) error {
+	*f = append(*f, splitList(value)...)
+	return nil
+}
+
+// 推送目标的网站筛选，每项为网站名或 tag:标签，为空时匹配所有网站。
+// 租户的网站只匹配明确写出其名称（<租户>:<网站>）的筛选，不会发到运营方的全局推送目标
+type siteFilter []string
+
+func (f siteFilter) match(site string, config SiteConfig) bool {
+	tenant := isTenantSite(site)
+	if len(f) == 0 {
+		return !tenant
+	}
+	for _, v := range f {
+		if tag, ok := strings.CutPrefix(v, "tag:"); ok {
+			if !tenant && slices.Contains(config.Tags, tag) {
+				return true
+			}
+		} else if v == site {
+			return true
+		}
+	}
+	return false
+}
+
+// 推送目标及其网站筛选，参数格式为 目标[=网站或tag:标签,...]
+type notifyRoute struct {
+	Target string
+	Filter siteFilter
+}
+
+// 可以重复指定的推送目标参数
+type routeFlag []notifyRoute
+
+func (f *routeFlag) String() string {
+	var parts []string
+	for _, r := range *f {
+		if len(r.Filter) == 0 {
+			parts = append(parts, r.Target)
+		} else {
+			parts = append(parts, r.Target+"="+strings.Join(r.Filter, ","))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (f *routeFlag) Set(value string) error {
+	target, filter, _ := strings.Cut(value, "=")
+	if target = strings.TrimSpace(target); target == "" {
+		return fmt.Errorf("expected target[=site|tag:name,...], got %q", value)
+	}
+	*f = append(*f, notifyRoute{Target: target, Filter: splitList(filter)})
+	return nil
+}
+
+// 按目标限制发送频率：同一目标两次发送至少间隔 interval，ctx 结束时返回错误
+type pacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time
+}
+
+func newPacer(interval time.Duration) *pacer {
+	return &pacer{interval: interval, next: make(map[string]time.Time)}
+}
+
+func (p *pacer) wait(ctx context.Context, target string) error {
+	p.mu.Lock()
+	now := time.Now()
+	at := p.next[target]
+	if at.Before(now) {
+		at = now
+	}
+	p.next[target] = at.Add(p.interval)
+	p.mu.Unlock()
+
+	select {
+	case <-time.After(time.Until(at)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
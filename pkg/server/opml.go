@@ -0,0 +1,61 @@
+package server
+
+import (
+	"encoding/xml"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+)
+
+type opmlOutline struct {
+	Type    string `xml:"type,attr"`
+	Text    string `xml:"text,attr"`
+	Title   string `xml:"title,attr"`
+	XMLURL  string `xml:"xmlUrl,attr"`
+	HTMLURL string `xml:"htmlUrl,attr,omitempty"`
+}
+
+type opmlDoc struct {
+	XMLName     xml.Name      `xml:"opml"`
+	Version     string        `xml:"version,attr"`
+	Title       string        `xml:"head>title"`
+	DateCreated string        `xml:"head>dateCreated"`
+	Outlines    []opmlOutline `xml:"body>outline"`
+}
+
+// /opml?tag=：以 OPML 导出网站列表，方便阅读器一次订阅所有网站
+func (srv *Server) opmlHandler(w http.ResponseWriter, r *http.Request) {
+	tag := r.URL.Query().Get("tag")
+	base := srv.requestBaseURL(r)
+
+	doc := opmlDoc{Version: "2.0", Title: "RSS生成服务", DateCreated: time.Now().UTC().Format(time.RFC1123)}
+	for site, config := range srv.getAllSiteConfig() {
+		if (tag != "" && !slices.Contains(config.Tags, tag)) || !srv.siteVisible(r, site) {
+			continue
+		}
+		name := config.Name
+		if name == "" {
+			name = site
+		}
+		doc.Outlines = append(doc.Outlines, opmlOutline{
+			Type:    "rss",
+			Text:    name,
+			Title:   name,
+			XMLURL:  siteFeedURLs(base, site)[FormatRSS],
+			HTMLURL: config.URL,
+		})
+	}
+	sort.Slice(doc.Outlines, func(i, j int) bool { return doc.Outlines[i].XMLURL < doc.Outlines[j].XMLURL })
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		slog.Error("Failed to encode OPML", "error", err)
+		http.Error(w, "Failed to encode OPML", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
@@ -0,0 +1,409 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"rss-zhuaqu/pkg/scraper"
+)
+
+// 流水线步骤类型
+const (
+	StepFetch     = "fetch"
+	StepPaginate  = "paginate"
+	StepExtract   = "extract"
+	StepDetail    = "detail"
+	StepTransform = "transform"
+	StepSummarize = "summarize"
+)
+
+// 抓取流水线中的一个步骤，不同类型的步骤使用不同的字段
+type PipelineStep struct {
+	Type string
+
+	// fetch：列表页地址，默认为网站 URL
+	URL string
+
+	// paginate：下一页链接的选择器，以及最多抓取的页数（默认 3）
+	NextSelector string
+	MaxPages     int
+
+	// extract：从列表页提取条目，未设置的选择器沿用网站配置。
+	// detail：抓取条目链接指向的详情页，用选择器覆盖对应字段，
+	// 未设置的选择器保持原值
+	ItemSelector  string
+	TitleSelector string
+	LinkSelector  string
+	DescSelector  string
+	DateSelector  string
+	ImageSelector string
+
+	// detail：最多抓取的详情页数量（0 表示全部）及并发数（默认 4）。
+	// summarize：最多生成摘要的条目数及并发数（默认 2）
+	MaxItems    int
+	Concurrency int
+
+	// summarize：发送给模型的提示词，为空时使用 -llm-prompt
+	Prompt string
+
+	// transform：对字段（title、link、description、date、image）执行的操作，
+	// 可选 replace、regex、prefix、suffix、trim
+	Field       string
+	Op          string
+	Pattern     string
+	Replacement string
+}
+
+// 流水线执行过程中的条目，字段为提取出的原始值
+type scrapedItem struct {
+	Title       string
+	Link        string
+	Description string
+	Date        string
+	Image       string
+
+	// 列表页中条目的链接密度和是否有作者标记，用于质量评分
+	LinkDensity float64
+	Author      bool
+}
+
+// 抓取到的页面
+type page struct {
+	url string
+	doc *goquery.Document
+}
+
+// 流水线执行状态
+type pipelineState struct {
+	srv    *Server
+	ctx    context.Context
+	config SiteConfig
+	pages  []page
+	items  []scrapedItem
+	// 超过 MaxItems 被丢弃的条目数
+	dropped int
+}
+
+// 网站配置的流水线。未配置 Pipeline 时，平铺的选择器配置
+// 等价于“抓取首页 → 提取条目”两个步骤
+func sitePipeline(config SiteConfig) []PipelineStep {
+	if len(config.Pipeline) > 0 {
+		return config.Pipeline
+	}
+	return []PipelineStep{
+		{Type: StepFetch},
+		{Type: StepExtract},
+	}
+}
+
+// 依次执行流水线的各个步骤，返回提取到的条目和超过 MaxItems 被丢弃的条目数
+func (srv *Server) runPipeline(ctx context.Context, config SiteConfig) ([]scrapedItem, int, error) {
+	state := &pipelineState{srv: srv, ctx: ctx, config: config}
+
+	for i, step := range sitePipeline(config) {
+		var err error
+		switch step.Type {
+		case StepFetch:
+			err = state.fetch(step)
+		case StepPaginate:
+			err = state.paginate(step)
+		case StepExtract:
+			state.extract(step)
+		case StepDetail:
+			err = state.detail(step)
+		case StepTransform:
+			err = state.transform(step)
+		case StepSummarize:
+			err = state.summarize(step)
+		default:
+			err = fmt.Errorf("unknown step type %q", step.Type)
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("pipeline step %d (%s): %w", i+1, step.Type, err)
+		}
+	}
+
+	return state.items, state.dropped, nil
+}
+
+// 抓取页面，ctx 取消时中止请求
+func (srv *Server) fetchDocument(ctx context.Context, pageURL string) (*goquery.Document, error) {
+	fetchCtx, sp := srv.startSpan(ctx, "fetch", spanKindClient)
+	defer sp.End()
+	sp.SetAttr("http.url", pageURL)
+
+	resp, err := srv.pageScraper().Get(fetchCtx, pageURL)
+	var statusErr *scraper.StatusError
+	if errors.As(err, &statusErr) {
+		sp.SetAttr("http.status_code", statusErr.Status)
+	}
+	if err != nil {
+		sp.SetError(err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	sp.SetAttr("http.status_code", resp.StatusCode)
+
+	_, parse := srv.startSpan(ctx, "parse", spanKindInternal)
+	doc, err := scraper.Parse(resp)
+	parse.SetError(err)
+	parse.End()
+	return doc, err
+}
+
+func (p *pipelineState) fetch(step PipelineStep) error {
+	pageURL := step.URL
+	if pageURL == "" {
+		pageURL = p.config.URL
+	}
+
+	doc, err := p.srv.fetchDocument(p.ctx, pageURL)
+	if err != nil {
+		return err
+	}
+	p.pages = append(p.pages, page{url: pageURL, doc: doc})
+	return nil
+}
+
+// 沿着“下一页”链接继续抓取
+func (p *pipelineState) paginate(step PipelineStep) error {
+	if len(p.pages) == 0 {
+		return fmt.Errorf("no page fetched before pagination")
+	}
+	if step.NextSelector == "" {
+		return fmt.Errorf("NextSelector is required")
+	}
+
+	maxPages := step.MaxPages
+	if maxPages <= 0 {
+		maxPages = 3
+	}
+
+	visited := map[string]bool{}
+	for _, pg := range p.pages {
+		visited[pg.url] = true
+	}
+
+	last := p.pages[len(p.pages)-1]
+	for n := 1; n < maxPages; n++ {
+		next, ok := last.doc.Find(step.NextSelector).First().Attr("href")
+		if !ok || strings.TrimSpace(next) == "" {
+			break
+		}
+		next = scraper.ResolveURL(last.url, strings.TrimSpace(next))
+		if visited[next] {
+			break
+		}
+		visited[next] = true
+
+		doc, err := p.srv.fetchDocument(p.ctx, next)
+		if err != nil {
+			return err
+		}
+		last = page{url: next, doc: doc}
+		p.pages = append(p.pages, last)
+	}
+	return nil
+}
+
+// 步骤中未设置的选择器沿用网站配置
+func stepSelectors(step PipelineStep, c SiteConfig) scraper.Selectors {
+	sel := func(stepSel, configSel string) string {
+		if stepSel != "" {
+			return stepSel
+		}
+		return configSel
+	}
+	return scraper.Selectors{
+		Item:        sel(step.ItemSelector, c.ItemSelector),
+		Title:       sel(step.TitleSelector, c.TitleSelector),
+		Link:        sel(step.LinkSelector, c.LinkSelector),
+		Description: sel(step.DescSelector, c.DescSelector),
+		Date:        sel(step.DateSelector, c.DateSelector),
+		Image:       sel(step.ImageSelector, c.ImageSelector),
+		DateFormat:  c.DateFormat,
+	}
+}
+
+// 选择器在一个条目节点中的匹配结果，用于 test 命令调试选择器
+type fieldTrace = scraper.FieldTrace
+
+// 从一个条目节点中提取字段，trace 不为 nil 时记录每个选择器的匹配结果
+func scrapeNode(s *goquery.Selection, sels scraper.Selectors, baseURL string, trace *[]fieldTrace) scrapedItem {
+	f := scraper.ExtractNode(s, sels, baseURL, trace)
+	return scrapedItem{
+		Title:       f.Title,
+		Link:        f.Link,
+		Description: f.Description,
+		Date:        f.Date,
+		Image:       f.Image,
+		LinkDensity: linkDensity(s),
+		Author:      hasAuthor(s),
+	}
+}
+
+// 从已抓取的页面中提取条目，最多提取 MaxItems 条，相对链接按条目所在页面的地址解析
+func (p *pipelineState) extract(step PipelineStep) {
+	maxItems := siteMaxItems(p.config)
+	sels := stepSelectors(step, p.config)
+
+	for _, pg := range p.pages {
+		matched := pg.doc.Find(sels.Item)
+		if room := maxItems - len(p.items); matched.Length() > room {
+			p.dropped += matched.Length() - max(room, 0)
+			matched = matched.Slice(0, max(room, 0))
+		}
+		matched.Each(func(i int, s *goquery.Selection) {
+			p.items = append(p.items, scrapeNode(s, sels, pg.url, nil))
+		})
+	}
+}
+
+// 抓取条目的详情页，补充或覆盖字段。单个详情页失败时保留列表页的内容，
+// 但 ctx 取消或超时时整个抓取失败
+func (p *pipelineState) detail(step PipelineStep) error {
+	n := len(p.items)
+	if step.MaxItems > 0 && step.MaxItems < n {
+		n = step.MaxItems
+	}
+	concurrency := step.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i := 0; i < n; i++ {
+		if p.items[i].Link == "" {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(item *scrapedItem) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			doc, err := p.srv.fetchDocument(p.ctx, item.Link)
+			if err != nil {
+				// 详情页抓取失败时保留列表页的内容
+				return
+			}
+			root := doc.Selection
+			if step.ItemSelector != "" {
+				root = doc.Find(step.ItemSelector).First()
+			}
+
+			if step.TitleSelector != "" {
+				if v := scraper.NormalizeText(root.Find(step.TitleSelector).Text()); v != "" {
+					item.Title = v
+				}
+			}
+			if step.DescSelector != "" {
+				if v := scraper.NormalizeText(root.Find(step.DescSelector).Text()); v != "" {
+					item.Description = v
+				}
+			}
+			if step.DateSelector != "" {
+				if v := strings.TrimSpace(root.Find(step.DateSelector).Text()); v != "" {
+					item.Date = v
+				}
+			}
+			if step.ImageSelector != "" {
+				if v := scraper.ExtractImage(root.Find(step.ImageSelector), item.Link); v != "" {
+					item.Image = v
+				}
+			}
+		}(&p.items[i])
+	}
+	wg.Wait()
+	return p.ctx.Err()
+}
+
+// 对条目字段执行文本变换
+func (p *pipelineState) transform(step PipelineStep) error {
+	var apply func(string) string
+	switch step.Op {
+	case "replace":
+		apply = func(s string) string { return strings.ReplaceAll(s, step.Pattern, step.Replacement) }
+	case "regex":
+		re, err := regexp.Compile(step.Pattern)
+		if err != nil {
+			return err
+		}
+		apply = func(s string) string { return re.ReplaceAllString(s, step.Replacement) }
+	case "prefix":
+		apply = func(s string) string { return step.Pattern + s }
+	case "suffix":
+		apply = func(s string) string { return s + step.Pattern }
+	case "trim":
+		apply = func(s string) string { return strings.Trim(s, step.Pattern) }
+	default:
+		return fmt.Errorf("unknown transform op %q", step.Op)
+	}
+
+	for i := range p.items {
+		field := itemField(&p.items[i], step.Field)
+		if field == nil {
+			return fmt.Errorf("unknown field %q", step.Field)
+		}
+		if *field != "" {
+			*field = apply(*field)
+		}
+	}
+	return nil
+}
+
+// 按字段名取得条目字段的指针
+func itemField(item *scrapedItem, name string) *string {
+	switch strings.ToLower(name) {
+	case FieldTitle:
+		return &item.Title
+	case FieldLink:
+		return &item.Link
+	case FieldDescription:
+		return &item.Description
+	case FieldDate:
+		return &item.Date
+	case FieldImage:
+		return &item.Image
+	}
+	return nil
+}
+
+// 检查流水线配置
+func validatePipeline(steps []PipelineStep) error {
+	for i, step := range steps {
+		var err error
+		switch step.Type {
+		case StepFetch:
+			if step.URL != "" {
+				_, err = url.Parse(step.URL)
+			}
+		case StepPaginate:
+			if step.NextSelector == "" {
+				err = fmt.Errorf("NextSelector is required")
+			}
+		case StepExtract, StepDetail, StepSummarize:
+		case StepTransform:
+			if itemField(&scrapedItem{}, step.Field) == nil {
+				err = fmt.Errorf("unknown field %q", step.Field)
+			} else if step.Op == "regex" {
+				_, err = regexp.Compile(step.Pattern)
+			}
+		default:
+			err = fmt.Errorf("unknown step type %q", step.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("pipeline step %d (%s): %w", i+1, step.Type, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPipelinePaginateRelativeLinks(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/list/":
+			fmt.Fprint(w, `<div class="item"><a href="a.html">A</a></div><a class="next" href="/archive/2/">next</a>`)
+		case "/archive/2/":
+			fmt.Fprintf(w, `<div class="item"><a href="b.html">B</a></div><div class="item"><a href="%s/c.html">C</a></div>`, ts.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	config := SiteConfig{
+		URL:           ts.URL + "/list/",
+		ItemSelector:  ".item",
+		TitleSelector: "a",
+		LinkSelector:  "a",
+		Pipeline: []PipelineStep{
+			{Type: StepFetch},
+			{Type: StepPaginate, NextSelector: "a.next", MaxPages: 2},
+			{Type: StepExtract},
+		},
+	}
+	items, _, err := newServer().runPipeline(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{ts.URL + "/list/a.html", ts.URL + "/archive/2/b.html", ts.URL + "/c.html"}
+	if len(items) != len(want) {
+		t.Fatalf("got %d items, want %d", len(items), len(want))
+	}
+	for i, item := range items {
+		if item.Link != want[i] {
+			t.Errorf("item %d link = %s, want %s", i, item.Link, want[i])
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// 选择器调试页面：输入网址和选择器，实时查看匹配到的条目节点和生成的条目。
+// 与 test 命令使用相同的提取逻辑，不写缓存，也不触发通知
+
+//go:embed playground.html
+var playgroundHTML string
+
+var playgroundTemplate = template.Must(template.New("playground").Parse(playgroundHTML))
+
+// 调试页面提交的网址和选择器。Site 不为空时以该网站的配置为基础（MaxItems、MinQuality 等），
+// 选择器以提交的为准
+type playgroundRequest struct {
+	Site          string
+	URL           string
+	ItemSelector  string
+	TitleSelector string
+	LinkSelector  string
+	DescSelector  string
+	DateSelector  string
+	DateFormat    string
+	ImageSelector string
+}
+
+// 调试结果，Nodes 最多 playgroundMaxNodes 个
+type playgroundResult struct {
+	Total int
+	Kept  int
+	Nodes []nodeTrace
+}
+
+const (
+	playgroundMaxNodes = 50
+	// 抓取的页面缓存一分钟，修改选择器时不重复请求网站
+	playgroundPageTTL   = time.Minute
+	playgroundMaxPages  = 8
+	playgroundMaxBodyKB = 64
+)
+
+type playgroundPage struct {
+	doc      *goquery.Document
+	expireAt time.Time
+}
+
+// 返回缓存的页面，过期或不存在时重新抓取
+func (srv *Server) playgroundDocument(r *http.Request, pageURL string) (*goquery.Document, error) {
+	now := time.Now()
+	srv.playgroundPagesMu.Lock()
+	p, ok := srv.playgroundPages[pageURL]
+	srv.playgroundPagesMu.Unlock()
+	if ok && now.Before(p.expireAt) {
+		return p.doc, nil
+	}
+
+	doc, err := srv.fetchDocument(r.Context(), pageURL)
+	if err != nil {
+		return nil, err
+	}
+	srv.playgroundPagesMu.Lock()
+	defer srv.playgroundPagesMu.Unlock()
+	for u, p := range srv.playgroundPages {
+		if now.After(p.expireAt) || len(srv.playgroundPages) >= playgroundMaxPages {
+			delete(srv.playgroundPages, u)
+		}
+	}
+	srv.playgroundPages[pageURL] = playgroundPage{doc: doc, expireAt: now.Add(playgroundPageTTL)}
+	return doc, nil
+}
+
+// 网站配置中第一个 extract 步骤实际使用的网址和选择器
+func playgroundFromSite(site string, config SiteConfig) playgroundRequest {
+	var step PipelineStep
+	for _, s := range sitePipeline(config) {
+		if s.Type == StepExtract {
+			step = s
+			break
+		}
+	}
+	sels := stepSelectors(step, config)
+	return playgroundRequest{
+		Site:          site,
+		URL:           config.URL,
+		ItemSelector:  sels.Item,
+		TitleSelector: sels.Title,
+		LinkSelector:  sels.Link,
+		DescSelector:  sels.Description,
+		DateSelector:  sels.Date,
+		DateFormat:    sels.DateFormat,
+		ImageSelector: sels.Image,
+	}
+}
+
+// GET /admin/playground[?site=x] 选择器调试页面，site 指定时预先填入该网站的配置；
+// POST 提交 playgroundRequest，返回 playgroundResult
+func (srv *Server) playgroundHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		srv.renderPlayground(w, r)
+	case http.MethodPost:
+		srv.runPlayground(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (srv *Server) renderPlayground(w http.ResponseWriter, r *http.Request) {
+	configs := srv.getAllSiteConfig()
+	sites := make([]string, 0, len(configs))
+	for site := range configs {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+
+	initial := playgroundRequest{}
+	if site := r.URL.Query().Get("site"); site != "" {
+		config, ok := configs[site]
+		if !ok {
+			writeError(w, r, http.StatusNotFound, ErrCodeSiteNotFound, fmt.Sprintf("Unknown site: %s", site))
+			return
+		}
+		initial = playgroundFromSite(site, config)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := playgroundTemplate.Execute(w, map[string]interface{}{"Sites": sites, "Initial": initial, "Base": srv.requestBasePath(r)}); err != nil {
+		slog.Error("Failed to render playground", "error", err)
+	}
+}
+
+func (srv *Server) runPlayground(w http.ResponseWriter, r *http.Request) {
+	var req playgroundRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, playgroundMaxBodyKB<<10)).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	if !isHTTPURL(req.URL) {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "URL must be an http or https URL")
+		return
+	}
+	if req.ItemSelector == "" {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "ItemSelector is required")
+		return
+	}
+	// goquery 对无效的选择器不报错，只是匹配不到节点，这里先编译一次给出明确的错误
+	for _, s := range []struct{ name, sel string }{
+		{"ItemSelector", req.ItemSelector},
+		{"TitleSelector", req.TitleSelector},
+		{"LinkSelector", req.LinkSelector},
+		{"DescSelector", req.DescSelector},
+		{"DateSelector", req.DateSelector},
+		{"ImageSelector", req.ImageSelector},
+	} {
+		if s.sel == "" {
+			continue
+		}
+		if _, err := cascadia.Compile(s.sel); err != nil {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("Invalid %s: %v", s.name, err))
+			return
+		}
+	}
+
+	var config SiteConfig
+	if req.Site != "" {
+		base, ok := srv.getSiteConfig(req.Site)
+		if !ok {
+			writeError(w, r, http.StatusNotFound, ErrCodeSiteNotFound, fmt.Sprintf("Unknown site: %s", req.Site))
+			return
+		}
+		config = base
+	}
+	config.Pipeline = nil
+	config.URL = req.URL
+	config.ItemSelector = req.ItemSelector
+	config.TitleSelector = req.TitleSelector
+	config.LinkSelector = req.LinkSelector
+	config.DescSelector = req.DescSelector
+	config.DateSelector = req.DateSelector
+	config.DateFormat = req.DateFormat
+	config.ImageSelector = req.ImageSelector
+
+	doc, err := srv.playgroundDocument(r, req.URL)
+	if err != nil {
+		slog.Warn("Playground fetch failed", "url", req.URL, "error", err)
+		writeError(w, r, fetchErrorStatus(err, http.StatusBadGateway), fetchErrorCode(err), fmt.Sprintf("Failed to fetch %s: %v", req.URL, err))
+		return
+	}
+	state := &pipelineState{srv: srv, ctx: r.Context(), config: config, pages: []page{{url: req.URL, doc: doc}}}
+	nodes := state.traceExtract(PipelineStep{Type: StepExtract})
+
+	res := playgroundResult{Total: len(nodes), Nodes: nodes}
+	for _, n := range nodes {
+		if n.Skipped == "" {
+			res.Kept++
+		}
+	}
+	if len(res.Nodes) > playgroundMaxNodes {
+		res.Nodes = res.Nodes[:playgroundMaxNodes]
+	}
+	if res.Nodes == nil {
+		res.Nodes = []nodeTrace{}
+	}
+	writeJSON(w, http.StatusOK, res)
+}
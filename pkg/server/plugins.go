@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+
+	"rss-zhuaqu/pkg/hooks"
+	"rss-zhuaqu/pkg/scraper"
+)
+
+// 扩展：嵌入服务时通过 New 的 WithItemHook、WithFetchMiddleware、WithHTTPMiddleware 选项
+// 注册钩子和中间件，不需要修改已有代码。接口定义在 pkg/hooks 中
+
+// 注册条目后处理钩子，每次刷新时对提取出的条目按注册顺序执行，丢弃的条目记录为 dropped by hook
+func (srv *Server) registerItemHook(h hooks.ItemHook) {
+	srv.itemHooks = append(srv.itemHooks, h)
+}
+
+// 注册抓取中间件，包装抓取网站页面（列表页、分页和详情页）的请求，先注册的在最外层
+func (srv *Server) registerFetchMiddleware(mw hooks.FetchMiddleware) {
+	srv.fetchMiddleware = append(srv.fetchMiddleware, mw)
+}
+
+// 注册 HTTP 中间件，包装所有路由，在访问控制、限流、超时等内置处理之后执行，先注册的在最外层
+func (srv *Server) registerHTTPMiddleware(mw hooks.HTTPMiddleware) {
+	srv.httpMiddleware = append(srv.httpMiddleware, mw)
+}
+
+// 添加条目后处理钩子，见 registerItemHook
+func WithItemHook(h hooks.ItemHook) Option {
+	return func(srv *Server) { srv.registerItemHook(h) }
+}
+
+// 添加抓取中间件，见 registerFetchMiddleware
+func WithFetchMiddleware(mw hooks.FetchMiddleware) Option {
+	return func(srv *Server) { srv.registerFetchMiddleware(mw) }
+}
+
+// 添加 HTTP 中间件，见 registerHTTPMiddleware
+func WithHTTPMiddleware(mw hooks.HTTPMiddleware) Option {
+	return func(srv *Server) { srv.registerHTTPMiddleware(mw) }
+}
+
+// 抓取网站页面（列表页、分页、详情页和上游订阅源）使用的抓取器，请求经过注册的抓取中间件，
+// 最内层在请求中加入链路追踪的 traceparent
+func (srv *Server) pageScraper() *scraper.Scraper {
+	srv.pageScraperOnce.Do(func() {
+		mws := append(append([]hooks.FetchMiddleware(nil), srv.fetchMiddleware...), traceFetchMiddleware)
+		srv.pageScraperInst = scraper.New(scraper.WithFetchMiddleware(mws...))
+	})
+	return srv.pageScraperInst
+}
+
+func (srv *Server) pluginHandler(next http.Handler) http.Handler {
+	return hooks.ChainHTTP(next, srv.httpMiddleware...)
+}
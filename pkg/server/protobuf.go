@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// protobuf 编码的最小实现，只支持 rss.proto 中用到的类型
+
+const (
+	pbVarint  = 0
+	pbFixed64 = 1
+	pbBytes   = 2
+	pbFixed32 = 5
+)
+
+// 按字段编号写入消息，proto3 的默认值（0、空字符串、false）不写入
+type pbWriter struct {
+	buf []byte
+}
+
+func (w *pbWriter) tag(field, wireType int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(field)<<3|uint64(wireType))
+}
+
+func (w *pbWriter) String(field int, s string) {
+	if s == "" {
+		return
+	}
+	w.tag(field, pbBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *pbWriter) Strings(field int, list []string) {
+	for _, s := range list {
+		w.tag(field, pbBytes)
+		w.buf = binary.AppendUvarint(w.buf, uint64(len(s)))
+		w.buf = append(w.buf, s...)
+	}
+}
+
+func (w *pbWriter) Int64(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	w.tag(field, pbVarint)
+	w.buf = binary.AppendUvarint(w.buf, uint64(v))
+}
+
+func (w *pbWriter) Bool(field int, v bool) {
+	if v {
+		w.tag(field, pbVarint)
+		w.buf = append(w.buf, 1)
+	}
+}
+
+// 嵌套消息，空消息也会写入
+func (w *pbWriter) Message(field int, msg []byte) {
+	w.tag(field, pbBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(msg)))
+	w.buf = append(w.buf, msg...)
+}
+
+// 解析出的一个字段，varint 类型的值在 num 中，长度前缀类型的值在 data 中
+type pbField struct {
+	field int
+	num   uint64
+	data  []byte
+}
+
+var errBadProtobuf = errors.New("malformed protobuf message")
+
+// 按顺序解析消息中的字段，跳过 fixed32/fixed64 字段
+func parseProtobuf(b []byte) ([]pbField, error) {
+	var fields []pbField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 || key>>3 == 0 || key>>3 > math.MaxInt32 {
+			return nil, errBadProtobuf
+		}
+		b = b[n:]
+		f := pbField{field: int(key >> 3)}
+		switch key & 7 {
+		case pbVarint:
+			if f.num, n = binary.Uvarint(b); n <= 0 {
+				return nil, errBadProtobuf
+			}
+			b = b[n:]
+		case pbBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return nil, errBadProtobuf
+			}
+			f.data = b[n : n+int(size)]
+			b = b[n+int(size):]
+		case pbFixed64:
+			if len(b) < 8 {
+				return nil, errBadProtobuf
+			}
+			b = b[8:]
+			continue
+		case pbFixed32:
+			if len(b) < 4 {
+				return nil, errBadProtobuf
+			}
+			b = b[4:]
+			continue
+		default:
+			return nil, errBadProtobuf
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
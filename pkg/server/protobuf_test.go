@@ -0,0 +1,98 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+)
+
+// protobuf 编码文档（Encoding）中的示例
+func TestPBWriter(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w *pbWriter)
+		want  []byte
+	}{
+		{"varint 150", func(w *pbWriter) { w.Int64(1, 150) }, []byte{0x08, 0x96, 0x01}},
+		{"string", func(w *pbWriter) { w.String(2, "testing") }, []byte{0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g'}},
+		{"nested", func(w *pbWriter) { w.Message(3, []byte{0x08, 0x96, 0x01}) }, []byte{0x1a, 0x03, 0x08, 0x96, 0x01}},
+		{"negative int64", func(w *pbWriter) { w.Int64(1, -1) }, []byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"bool", func(w *pbWriter) { w.Bool(4, true) }, []byte{0x20, 0x01}},
+		{"repeated", func(w *pbWriter) { w.Strings(5, []string{"a", ""}) }, []byte{0x2a, 0x01, 'a', 0x2a, 0x00}},
+		{"large field", func(w *pbWriter) { w.Int64(16, 1) }, []byte{0x80, 0x01, 0x01}},
+		// proto3 的默认值不写入，空的嵌套消息仍写入
+		{"defaults", func(w *pbWriter) {
+			w.Int64(1, 0)
+			w.String(2, "")
+			w.Bool(4, false)
+			w.Message(3, nil)
+		}, []byte{0x1a, 0x00}},
+	}
+	for _, tt := range tests {
+		var w pbWriter
+		tt.write(&w)
+		if !bytes.Equal(w.buf, tt.want) {
+			t.Errorf("%s: % x, want % x", tt.name, w.buf, tt.want)
+		}
+	}
+}
+
+func TestParseProtobuf(t *testing.T) {
+	msg := []byte{
+		0x08, 0x96, 0x01, // 1: 150
+		0x11, 1, 2, 3, 4, 5, 6, 7, 8, // 2: fixed64，跳过
+		0x1a, 0x03, 0x08, 0x96, 0x01, // 3: 嵌套消息
+		0x25, 1, 2, 3, 4, // 4: fixed32，跳过
+		0x2a, 0x00, // 5: 空字符串
+		0x80, 0x01, 0x07, // 16: 7
+	}
+	fields, err := parseProtobuf(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []pbField{
+		{field: 1, num: 150},
+		{field: 3, data: []byte{0x08, 0x96, 0x01}},
+		{field: 5, data: []byte{}},
+		{field: 16, num: 7},
+	}
+	if len(fields) != len(want) {
+		t.Fatalf("got %d fields %+v, want %d", len(fields), fields, len(want))
+	}
+	for i, f := range fields {
+		if f.field != want[i].field || f.num != want[i].num || !bytes.Equal(f.data, want[i].data) {
+			t.Errorf("field %d = %+v, want %+v", i, f, want[i])
+		}
+	}
+
+	// 写入后再解析得到相同的值
+	var w pbWriter
+	w.String(2, "testing")
+	w.Int64(7, -1)
+	fields, err = parseProtobuf(w.buf)
+	if err != nil || len(fields) != 2 || string(fields[0].data) != "testing" || int64(fields[1].num) != -1 {
+		t.Errorf("round trip = %+v, %v", fields, err)
+	}
+
+	if fields, err := parseProtobuf(nil); err != nil || len(fields) != 0 {
+		t.Errorf("empty message = %+v, %v", fields, err)
+	}
+}
+
+func TestParseProtobufMalformed(t *testing.T) {
+	tests := map[string][]byte{
+		"truncated varint":  {0x08, 0x96},
+		"truncated key":     {0x80},
+		"field 0":           {0x00, 0x01},
+		"truncated bytes":   {0x12, 0x07, 't', 'e'},
+		"huge length":       {0x12, 0xff, 0xff, 0xff, 0xff, 0x0f},
+		"truncated fixed64": {0x11, 1, 2, 3},
+		"truncated fixed32": {0x25, 1, 2},
+		"group":             {0x0b},
+		"wire type 7":       {0x0f},
+	}
+	for name, msg := range tests {
+		if _, err := parseProtobuf(msg); err != errBadProtobuf {
+			t.Errorf("%s: err = %v, want errBadProtobuf", name, err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// 规范化 -base-path：以 / 开头，不以 / 结尾
+func normalizeBasePath(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// 直接连接的地址（代理本身或客户端）
+func peerIP(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// 请求是否来自信任的代理
+func (srv *Server) fromTrustedProxy(r *http.Request) bool {
+	addr, ok := peerIP(r)
+	return ok && containsAddr(srv.trustedProxies, addr)
+}
+
+// 信任的代理传来的请求头，取第一个值
+func (srv *Server) forwardedHeader(r *http.Request, name string) string {
+	if !srv.fromTrustedProxy(r) {
+		return ""
+	}
+	v, _, _ := strings.Cut(r.Header.Get(name), ",")
+	return strings.TrimSpace(v)
+}
+
+// 请求对外的路径前缀：信任的代理的 X-Forwarded-Prefix，否则为 -base-path
+func (srv *Server) requestBasePath(r *http.Request) string {
+	if prefix := srv.forwardedHeader(r, "X-Forwarded-Prefix"); prefix != "" {
+		return normalizeBasePath(prefix)
+	}
+	return srv.basePath
+}
+
+// 去掉请求路径中的 -base-path 前缀，代理转发时去掉或保留前缀都可以
+func (srv *Server) basePathHandler(next http.Handler) http.Handler {
+	if srv.basePath == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, ok := strings.CutPrefix(r.URL.Path, srv.basePath)
+		if !ok || (path != "" && path[0] != '/') {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if path == "" {
+			path = "/"
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = path
+		r2.URL.RawPath = ""
+		r2.RequestURI = strings.TrimPrefix(r.RequestURI, srv.basePath)
+		if !strings.HasPrefix(r2.RequestURI, "/") {
+			r2.RequestURI = "/" + r2.RequestURI
+		}
+		next.ServeHTTP(w, r2)
+	})
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"rss-zhuaqu/pkg/feed"
+)
+
+// 生成订阅源的 RSS 和 JSON Feed 文件内容
+func renderFeedFiles(site string, f RSSFeed) (map[string][]byte, error) {
+	var rss bytes.Buffer
+	rss.WriteString(xml.Header)
+	if err := xml.NewEncoder(&rss).Encode(f); err != nil {
+		return nil, err
+	}
+
+	var jf bytes.Buffer
+	enc := json.NewEncoder(&jf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(feed.NewJSONFeed(f, "")); err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{
+		site + ".xml":  rss.Bytes(),
+		site + ".json": jf.Bytes(),
+	}, nil
+}
+
+// 把订阅源写入发布目录，先写临时文件再改名，静态服务器不会读到写了一半的文件
+func publishFeed(dir, site string, feed RSSFeed) error {
+	files, err := renderFeedFiles(site, feed)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0o644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 发布到静态目录和对象存储，dir 为空或 s3 为 nil 时跳过对应目标
+func (srv *Server) publishFeedTargets(dir string, s3 *s3Publisher, site string, feed RSSFeed) error {
+	if dir != "" {
+		if err := publishFeed(dir, site, feed); err != nil {
+			return err
+		}
+	}
+	if s3 != nil {
+		config, _ := srv.getSiteConfig(site)
+		if err := s3.publish(site, feed, siteCacheTTL(config)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 刷新后发布订阅源，出错时只记录日志
+func (srv *Server) publishRefreshed(site string, feed RSSFeed) {
+	if err := srv.publishFeedTargets(srv.cfg.PublishDir, srv.s3Target, site, feed); err != nil {
+		slog.Error("Failed to publish feed", "site", site, "error", err)
+	}
+}
+
+// publish 命令：抓取全部网站，写入发布目录（以及 -s3-bucket）后退出
+func (srv *Server) runPublishCommand(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: publish [dir]")
+	}
+	dir, s3 := srv.cfg.PublishDir, srv.s3Target
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	if dir == "" && s3 == nil {
+		return fmt.Errorf("usage: publish <dir>, or set -s3-bucket")
+	}
+	// 由本命令统计发布结果，刷新时不再重复发布
+	srv.cfg.PublishDir, srv.s3Target = "", nil
+
+	var sites []string
+	for site := range srv.getAllSiteConfig() {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+
+	var failed int
+	for _, site := range sites {
+		config, _ := srv.getSiteConfig(site)
+		srv.loadSiteImage(site, config)
+
+		fc, err := srv.refreshCache(site)
+		if err != nil {
+			failed++
+			continue
+		}
+		if err := srv.publishFeedTargets(dir, s3, site, fc.Feed); err != nil {
+			slog.Error("Failed to publish feed", "site", site, "error", err)
+			failed++
+		}
+	}
+
+	slog.Info("Published feeds", "published", len(sites)-failed, "total", len(sites))
+	if failed > 0 {
+		return fmt.Errorf("%d feeds failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 兼容对象存储（AWS S3、MinIO、R2，以及开启互操作的 GCS）的上传目标
+type s3Publisher struct {
+	endpoint  *url.URL
+	pathStyle bool
+	bucket    string
+	region    string
+	prefix    string
+
+	accessKey    string
+	secretKey    string
+	sessionToken string
+
+	client *http.Client
+}
+
+// 发布文件的类型
+var feedContentTypes = map[string]string{
+	".xml":  "application/rss+xml; charset=utf-8",
+	".json": "application/feed+json; charset=utf-8",
+}
+
+// 创建上传目标，endpoint 为空时使用 AWS S3，凭据从 AWS_ACCESS_KEY_ID、
+// AWS_SECRET_ACCESS_KEY 和 AWS_SESSION_TOKEN 环境变量读取
+func newS3Publisher(bucket, endpoint, region, prefix string) (*s3Publisher, error) {
+	p := &s3Publisher{
+		bucket:       bucket,
+		region:       region,
+		prefix:       strings.Trim(prefix, "/"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       &http.Client{Timeout: time.Minute},
+	}
+	if p.accessKey == "" || p.secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required")
+	}
+
+	// 自定义 endpoint 使用 path-style（endpoint/bucket/key），AWS 使用 virtual-hosted style
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, region)
+	} else {
+		p.pathStyle = true
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "https://" + endpoint
+		}
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	p.endpoint = u
+	return p, nil
+}
+
+// 上传订阅源文件，Cache-Control 的 max-age 为网站的缓存有效期
+func (p *s3Publisher) publish(site string, feed RSSFeed, maxAge time.Duration) error {
+	files, err := renderFeedFiles(site, feed)
+	if err != nil {
+		return err
+	}
+
+	cacheControl := fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+	for name, data := range files {
+		key := name
+		if p.prefix != "" {
+			key = p.prefix + "/" + name
+		}
+		if err := p.put(key, data, feedContentTypes[path.Ext(name)], cacheControl); err != nil {
+			return fmt.Errorf("upload %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// PUT 上传对象，使用 AWS Signature Version 4 签名
+func (p *s3Publisher) put(key string, data []byte, contentType, cacheControl string) error {
+	objectPath := "/" + key
+	if p.pathStyle {
+		objectPath = "/" + p.bucket + objectPath
+	}
+	objectPath = strings.TrimSuffix(p.endpoint.Path, "/") + objectPath
+	encodedPath := s3EscapePath(objectPath)
+
+	req, err := http.NewRequest(http.MethodPut, p.endpoint.Scheme+"://"+p.endpoint.Host+encodedPath, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	payloadHash := sha256Hex(data)
+	headers := map[string]string{
+		"cache-control":        cacheControl,
+		"content-type":         contentType,
+		"host":                 p.endpoint.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           now.Format("20060102T150405Z"),
+	}
+	if p.sessionToken != "" {
+		headers["x-amz-security-token"] = p.sessionToken
+	}
+	for k, v := range headers {
+		if k != "host" {
+			req.Header.Set(k, v)
+		}
+	}
+	req.Header.Set("Authorization", p.authorization(now, encodedPath, headers, payloadHash))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// 计算 SigV4 的 Authorization 头
+func (p *s3Publisher) authorization(now time.Time, encodedPath string, headers map[string]string, payloadHash string) string {
+	canonicalRequest, signedHeaders := s3CanonicalRequest(http.MethodPut, encodedPath, headers, payloadHash)
+
+	date := now.Format("20060102")
+	scope := date + "/" + p.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		headers["x-amz-date"],
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+p.secretKey), date)
+	key = hmacSHA256(key, p.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.accessKey, scope, signedHeaders, signature)
+}
+
+// SigV4 的规范请求（没有查询参数），同时返回签名的头名称列表。headers 的键为小写的头名称
+func s3CanonicalRequest(method, encodedPath string, headers map[string]string, payloadHash string) (string, string) {
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	return strings.Join([]string{
+		method,
+		encodedPath,
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n"), signedHeaders
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// 按 SigV4 的规则编码路径：除字母、数字、-_.~ 和 / 外全部百分号编码
+func s3EscapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// 手机推送：通过自建或公共的 ntfy、Gotify 服务器推送新条目，一次刷新的新条目合并为一条通知
+
+// 网站未配置 Priority 时通知的优先级（1 最低，5 最高，与 ntfy 相同）
+const defaultPushPriority = 3
+
+// 一条通知：只有一个条目时标题为条目标题，点击打开条目；否则列出各条目的标题，点击打开网站
+type pushMessage struct {
+	Title    string
+	Message  string
+	Click    string
+	Priority int
+	Tags     []string
+}
+
+// 通知内容最多列出的条目数
+const pushMaxItems = 10
+
+func newPushMessage(site string, config SiteConfig, items []Item) pushMessage {
+	name := config.Name
+	if name == "" {
+		name = site
+	}
+	msg := pushMessage{Priority: config.Priority, Tags: config.Tags}
+	if msg.Priority == 0 {
+		msg.Priority = defaultPushPriority
+	}
+	if len(items) == 1 {
+		msg.Title = items[0].Title
+		msg.Message = name
+		msg.Click = items[0].Link
+		if msg.Title == "" {
+			msg.Title = name
+		}
+		return msg
+	}
+
+	msg.Title = fmt.Sprintf("%s: %d new items", name, len(items))
+	msg.Click = config.URL
+	var lines []string
+	for i, item := range items {
+		if i == pushMaxItems {
+			lines = append(lines, fmt.Sprintf("… and %d more", len(items)-pushMaxItems))
+			break
+		}
+		title := item.Title
+		if title == "" {
+			title = item.Link
+		}
+		lines = append(lines, "• "+title)
+	}
+	msg.Message = strings.Join(lines, "\n")
+	return msg
+}
+
+func (srv *Server) ntfySink(site string, config SiteConfig, items []Item) []delivery {
+	var ds []delivery
+	for _, route := range srv.cfg.NtfyTopics {
+		if !route.Filter.match(site, config) {
+			continue
+		}
+		target, msg := route.Target, newPushMessage(site, config, items)
+		ds = append(ds, delivery{
+			sink:   "ntfy",
+			target: target,
+			site:   site,
+			send: func(ctx context.Context) error {
+				return srv.sendNtfy(ctx, target, msg)
+			},
+		})
+	}
+	return ds
+}
+
+// 用 JSON 发布到 ntfy：POST 到服务器根路径，主题放在请求体中，标题等可以包含非 ASCII 字符
+func (srv *Server) sendNtfy(ctx context.Context, topicURL string, msg pushMessage) error {
+	u, err := url.Parse(topicURL)
+	if err != nil {
+		return err
+	}
+	// 主题为路径的最后一段，之前的部分为服务器地址（可以部署在子路径下）
+	base, topic := path.Split(strings.TrimSuffix(u.Path, "/"))
+	u.Path = base
+	body, err := json.Marshal(map[string]interface{}{
+		"topic":    topic,
+		"title":    msg.Title,
+		"message":  msg.Message,
+		"click":    msg.Click,
+		"priority": msg.Priority,
+		"tags":     msg.Tags,
+	})
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	if srv.cfg.NtfyToken != "" {
+		header.Set("Authorization", "Bearer "+srv.cfg.NtfyToken)
+	}
+	return postBody(ctx, u.String(), "application/json", body, header)
+}
+
+func (srv *Server) gotifySink(site string, config SiteConfig, items []Item) []delivery {
+	if srv.cfg.GotifyURL == "" || !siteFilter(srv.cfg.GotifySites).match(site, config) {
+		return nil
+	}
+	msg := newPushMessage(site, config, items)
+	return []delivery{{
+		sink:   "gotify",
+		target: srv.cfg.GotifyURL,
+		site:   site,
+		send: func(ctx context.Context) error {
+			return srv.sendGotify(ctx, msg)
+		},
+	}}
+}
+
+// Gotify 的优先级为 0–10，ntfy 的 1–5 乘以 2
+func (srv *Server) sendGotify(ctx context.Context, msg pushMessage) error {
+	payload := map[string]interface{}{
+		"title":    msg.Title,
+		"message":  msg.Message,
+		"priority": msg.Priority * 2,
+	}
+	if msg.Click != "" {
+		payload["extras"] = map[string]interface{}{
+			"client::notification": map[string]interface{}{"click": map[string]string{"url": msg.Click}},
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return postBody(ctx, strings.TrimSuffix(srv.cfg.GotifyURL, "/")+"/message", "application/json", body, http.Header{"X-Gotify-Key": {srv.cfg.GotifyToken}})
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"rss-zhuaqu/pkg/scraper"
+)
+
+// 条目质量评分：按标题和摘要长度、链接密度、是否有日期和作者计算 0–100 的分数。
+// 网站配置 MinQuality 后，低于该分数的条目被跳过，过滤选择器误匹配到的导航片段和只有链接的预告
+
+// 各项的满分，合计 100
+const (
+	qualityTitle       = 20
+	qualityDescription = 30
+	qualityLinkDensity = 20
+	qualityDate        = 15
+	qualityAuthor      = 15
+
+	// 标题、摘要达到这么多字符时得满分
+	qualityTitleChars       = 20
+	qualityDescriptionChars = 200
+)
+
+// 常见的作者标记
+const authorSelector = `[rel="author"], [itemprop="author"], .author, .byline`
+
+// 条目中链接文字占全部文字的比例，没有文字时为 1
+func linkDensity(s *goquery.Selection) float64 {
+	total := utf8.RuneCountInString(scraper.NormalizeText(s.Text()))
+	if total == 0 {
+		return 1
+	}
+	var links int
+	s.Find("a").Each(func(_ int, a *goquery.Selection) {
+		links += utf8.RuneCountInString(scraper.NormalizeText(a.Text()))
+	})
+	return min(float64(links)/float64(total), 1)
+}
+
+func hasAuthor(s *goquery.Selection) bool {
+	return scraper.NormalizeText(s.Find(authorSelector).First().Text()) != ""
+}
+
+// 条目的质量分数
+func qualityScore(si scrapedItem) int {
+	ratio := func(n, full int) float64 { return min(float64(n)/float64(full), 1) }
+	score := float64(qualityTitle)*ratio(utf8.RuneCountInString(si.Title), qualityTitleChars) +
+		float64(qualityDescription)*ratio(utf8.RuneCountInString(si.Description), qualityDescriptionChars) +
+		float64(qualityLinkDensity)*(1-si.LinkDensity)
+	if si.Date != "" {
+		score += qualityDate
+	}
+	if si.Author {
+		score += qualityAuthor
+	}
+	return int(score + 0.5)
+}
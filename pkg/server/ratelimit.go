@@ -0,0 +1,101 @@
+package server
+
+import (
+	"crypto/subtle"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 超过这个时间没有请求的客户端会被清理
+const rateBucketIdle = 10 * time.Minute
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// 取走一个令牌，不足时返回需要等待的时间
+func (srv *Server) takeToken(key string, now time.Time) (bool, time.Duration) {
+	srv.rateBucketsMu.Lock()
+	defer srv.rateBucketsMu.Unlock()
+
+	b, ok := srv.rateBuckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(srv.cfg.RateBurst), last: now}
+		srv.rateBuckets[key] = b
+	}
+	b.tokens = math.Min(float64(srv.cfg.RateBurst), b.tokens+now.Sub(b.last).Seconds()*srv.cfg.RateLimit)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / srv.cfg.RateLimit * float64(time.Second))
+}
+
+// 定期清理长时间没有请求的客户端
+func (srv *Server) startRateBucketCleaner() {
+	go func() {
+		for range time.Tick(time.Minute) {
+			srv.rateBucketsMu.Lock()
+			for key, b := range srv.rateBuckets {
+				if time.Since(b.last) > rateBucketIdle {
+					delete(srv.rateBuckets, key)
+				}
+			}
+			srv.rateBucketsMu.Unlock()
+		}
+	}()
+}
+
+// 限流的客户端标识：带有已知的访问令牌、有效的签名地址或管理令牌时按令牌，否则按 IP。
+// 未知的令牌和签名无效的地址按 IP 计算，避免随意构造令牌或借用地址中公开的令牌 ID 绕过限制
+func (srv *Server) rateLimitKey(r *http.Request) string {
+	params := r.URL.Query()
+	if id := srv.knownFeedToken(params.Get("token")); id != "" {
+		return "token:" + id
+	}
+	if id := params.Get("key"); id != "" && params.Get("token") == "" && srv.validFeedToken(r, requestFeedSite(r)) {
+		return "token:" + id
+	}
+	if id := srv.requestTenant(r); id != "" {
+		return "tenant:" + id
+	}
+	if t, ok := srv.tenantByKey(tenantKeyFromPath(r.URL.Path)); ok {
+		return "tenant:" + t.ID
+	}
+	if srv.cfg.AdminToken != "" && subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")), []byte(srv.cfg.AdminToken)) == 1 {
+		return "admin"
+	}
+	if addr, ok := srv.clientIP(r); ok {
+		return "ip:" + addr.String()
+	}
+	return "ip:" + r.RemoteAddr
+}
+
+// 客户端地址，用于日志
+func (srv *Server) clientAddr(r *http.Request) string {
+	if addr, ok := srv.clientIP(r); ok {
+		return addr.String()
+	}
+	return r.RemoteAddr
+}
+
+// 超过频率限制时返回 429，健康检查不限流
+func (srv *Server) rateLimitHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if srv.cfg.RateLimit <= 0 || r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ok, wait := srv.takeToken(srv.rateLimitKey(r), time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
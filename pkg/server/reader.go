@@ -0,0 +1,225 @@
+package server
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"os"
+	"slices"
+	"sort"
+)
+
+// 阅读器同步接口（Fever 等）共用的账号、条目编号和已读/收藏状态
+
+type readerState struct {
+	Read  map[int64]bool
+	Saved map[int64]bool
+	// 条目（网站和 GUID）的编号，首次出现在阅读器中时按 NextID 递增分配
+	IDs    map[string]int64
+	NextID int64
+}
+
+// 文件中按编号排序保存
+type readerStateJSON struct {
+	Read   []int64          `json:"read"`
+	Saved  []int64          `json:"saved"`
+	IDs    map[string]int64 `json:"ids,omitempty"`
+	NextID int64            `json:"next_id,omitempty"`
+}
+
+// 每个网站最多提供的最新条目数
+const readerItemsPerSite = 1000
+
+func (srv *Server) readerEnabled() bool {
+	return srv.cfg.ReaderUser != "" && srv.cfg.ReaderPassword != ""
+}
+
+func readerItemKey(site, guid string) string {
+	return site + "\n" + guid
+}
+
+// 旧版本由首次出现时间和 21 位哈希生成的编号，可能重复，只用于迁移已有的已读、收藏状态
+func legacyReaderItemID(site string, rec storedItem) int64 {
+	h := fnv.New32a()
+	h.Write([]byte(readerItemKey(site, rec.Item.GUID)))
+	return rec.FirstSeen<<21 | int64(h.Sum32()&(1<<21-1))
+}
+
+// 网站和标签的数字编号
+func readerNameID(name string) int64 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int64(h.Sum32() & 0x7fffffff)
+}
+
+// 阅读器中的一个条目
+type readerItem struct {
+	ID   int64
+	Site string
+	storedItem
+}
+
+// 阅读器账号能看到的网站：除租户的网站以外的所有网站
+func (srv *Server) readerSiteConfigs() map[string]SiteConfig {
+	configs := make(map[string]SiteConfig)
+	for site, config := range srv.getAllSiteConfig() {
+		if !isTenantSite(site) {
+			configs[site] = config
+		}
+	}
+	return configs
+}
+
+// 每个网站最新的 readerItemsPerSite 个历史条目，按编号从小到大排序。
+// 还没有编号的条目按首次出现时间分配编号并写入状态文件
+func (srv *Server) allReaderItems() ([]readerItem, error) {
+	var items []readerItem
+	for site := range srv.readerSiteConfigs() {
+		recs, err := srv.store.QueryItems(site, ItemQuery{Limit: readerItemsPerSite})
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range recs {
+			items = append(items, readerItem{Site: site, storedItem: rec})
+		}
+	}
+	// 先出现的条目编号更小，同时出现的按页面上从下到上的顺序
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].FirstSeen != items[j].FirstSeen {
+			return items[i].FirstSeen < items[j].FirstSeen
+		}
+		return items[i].Position > items[j].Position
+	})
+
+	srv.readStateMu.Lock()
+	defer srv.readStateMu.Unlock()
+	assigned := false
+	for i := range items {
+		item := &items[i]
+		key := readerItemKey(item.Site, item.Item.GUID)
+		id, ok := srv.readState.IDs[key]
+		if !ok {
+			srv.readState.NextID++
+			id = srv.readState.NextID
+			srv.readState.IDs[key] = id
+			srv.migrateItemState(legacyReaderItemID(item.Site, item.storedItem), id)
+			assigned = true
+		}
+		item.ID = id
+	}
+	if assigned {
+		if err := srv.saveReaderState(srv.cfg.ReaderStateFile); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items, nil
+}
+
+// 把旧编号的已读、收藏状态转到新编号上，调用方持有 readStateMu
+func (srv *Server) migrateItemState(legacy, id int64) {
+	if srv.readState.Read[legacy] {
+		delete(srv.readState.Read, legacy)
+		srv.readState.Read[id] = true
+	}
+	if srv.readState.Saved[legacy] {
+		delete(srv.readState.Saved, legacy)
+		srv.readState.Saved[id] = true
+	}
+}
+
+// 所有标签，按名称排序
+func (srv *Server) readerTags() []string {
+	var tags []string
+	for _, config := range srv.readerSiteConfigs() {
+		for _, tag := range config.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// 读取状态文件，文件不存在时所有条目都未读
+func (srv *Server) loadReaderState(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var st readerStateJSON
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	srv.readStateMu.Lock()
+	defer srv.readStateMu.Unlock()
+	for _, id := range st.Read {
+		srv.readState.Read[id] = true
+	}
+	for _, id := range st.Saved {
+		srv.readState.Saved[id] = true
+	}
+	for key, id := range st.IDs {
+		srv.readState.IDs[key] = id
+	}
+	srv.readState.NextID = st.NextID
+	return nil
+}
+
+// 写入状态文件，调用方持有 readStateMu
+func (srv *Server) saveReaderState(path string) error {
+	data, err := json.Marshal(readerStateJSON{
+		Read:   sortedIDs(srv.readState.Read),
+		Saved:  sortedIDs(srv.readState.Saved),
+		IDs:    srv.readState.IDs,
+		NextID: srv.readState.NextID,
+	})
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func sortedIDs(set map[int64]bool) []int64 {
+	ids := make([]int64, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (srv *Server) itemRead(id int64) bool {
+	srv.readStateMu.Lock()
+	defer srv.readStateMu.Unlock()
+	return srv.readState.Read[id]
+}
+
+func (srv *Server) itemSaved(id int64) bool {
+	srv.readStateMu.Lock()
+	defer srv.readStateMu.Unlock()
+	return srv.readState.Saved[id]
+}
+
+// 修改条目的已读或收藏状态并写入文件
+func (srv *Server) setItemState(set func(st readerState, id int64), ids ...int64) error {
+	srv.readStateMu.Lock()
+	defer srv.readStateMu.Unlock()
+	for _, id := range ids {
+		set(srv.readState, id)
+	}
+	return srv.saveReaderState(srv.cfg.ReaderStateFile)
+}
+
+func markRead(st readerState, id int64)    { st.Read[id] = true }
+func markUnread(st readerState, id int64)  { delete(st.Read, id) }
+func markSaved(st readerState, id int64)   { st.Saved[id] = true }
+func markUnsaved(st readerState, id int64) { delete(st.Saved, id) }
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 稍后阅读：网站配置的 ReadLater 规则把匹配的新条目保存到 Pocket 或 Wallabag
+
+var (
+	pocketAPI = "https://getpocket.com"
+)
+
+// 网站的稍后阅读规则
+type ReadLaterRule struct {
+	// pocket 或 wallabag
+	Service string
+	// 标题或摘要包含任一关键词（不区分大小写）时保存，为空时保存所有新条目
+	Keywords []string `json:",omitempty"`
+	// 保存时添加的标签
+	Tags []string `json:",omitempty"`
+}
+
+func validateReadLaterRule(rule ReadLaterRule) error {
+	switch rule.Service {
+	case "pocket", "wallabag":
+		return nil
+	}
+	return fmt.Errorf("unknown ReadLater service %q, expected pocket or wallabag", rule.Service)
+}
+
+func (rule ReadLaterRule) match(item Item) bool {
+	if len(rule.Keywords) == 0 {
+		return true
+	}
+	text := strings.ToLower(item.Title + "\n" + plainText(item.Description))
+	for _, kw := range rule.Keywords {
+		if kw != "" && strings.Contains(text, strings.ToLower(kw)) {
+			return true
+		}
+	}
+	return false
+}
+
+// 条目保存到 Pocket 或 Wallabag，服务没有配置账号时跳过
+func (srv *Server) readLaterSink(site string, config SiteConfig, items []Item) []delivery {
+	var ds []delivery
+	for _, rule := range config.ReadLater {
+		save := srv.readLaterSaver(rule.Service)
+		if save == nil {
+			continue
+		}
+		tags := append(append([]string(nil), config.Tags...), rule.Tags...)
+		for _, item := range items {
+			if item.Link == "" || !rule.match(item) {
+				continue
+			}
+			item := item
+			ds = append(ds, delivery{
+				sink:   rule.Service,
+				target: item.Link,
+				site:   site,
+				send: func(ctx context.Context) error {
+					return save(ctx, item, tags)
+				},
+			})
+		}
+	}
+	return ds
+}
+
+func (srv *Server) readLaterSaver(service string) func(ctx context.Context, item Item, tags []string) error {
+	switch service {
+	case "pocket":
+		if srv.cfg.PocketConsumerKey != "" && srv.cfg.PocketAccessToken != "" {
+			return srv.saveToPocket
+		}
+	case "wallabag":
+		if srv.cfg.WallabagURL != "" {
+			return srv.wallabag.save
+		}
+	}
+	return nil
+}
+
+// Pocket v3 add 接口
+func (srv *Server) saveToPocket(ctx context.Context, item Item, tags []string) error {
+	body, err := json.Marshal(map[string]string{
+		"url":          item.Link,
+		"title":        item.Title,
+		"tags":         strings.Join(tags, ","),
+		"consumer_key": srv.cfg.PocketConsumerKey,
+		"access_token": srv.cfg.PocketAccessToken,
+	})
+	if err != nil {
+		return err
+	}
+	return postBody(ctx, strings.TrimSuffix(pocketAPI, "/")+"/v3/add", "application/json; charset=UTF-8", body, http.Header{"X-Accept": {"application/json"}})
+}
+
+// Wallabag API 客户端，OAuth 令牌过期前重复使用
+type wallabagClient struct {
+	srv     *Server
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// 用密码模式获取访问令牌
+func (c *wallabagClient) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+	form := url.Values{
+		"grant_type":    {"password"},
+		"client_id":     {c.srv.cfg.WallabagClientID},
+		"client_secret": {c.srv.cfg.WallabagClientSecret},
+		"username":      {c.srv.cfg.WallabagUser},
+		"password":      {c.srv.cfg.WallabagPassword},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.srv.cfg.WallabagURL, "/")+"/oauth/v2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := readerRequest(req, &resp); err != nil {
+		return "", fmt.Errorf("wallabag login: %w", err)
+	}
+	if resp.AccessToken == "" {
+		return "", fmt.Errorf("wallabag login: no access token in response")
+	}
+	c.token = resp.AccessToken
+	// 提前一分钟刷新
+	c.expires = time.Now().Add(time.Duration(resp.ExpiresIn)*time.Second - time.Minute)
+	return c.token, nil
+}
+
+func (c *wallabagClient) save(ctx context.Context, item Item, tags []string) error {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]string{
+		"url":   item.Link,
+		"title": item.Title,
+		"tags":  strings.Join(tags, ","),
+	})
+	if err != nil {
+		return err
+	}
+	err = postBody(ctx, strings.TrimSuffix(c.srv.cfg.WallabagURL, "/")+"/api/entries.json", "application/json", body, http.Header{"Authorization": {"Bearer " + token}})
+	if se, ok := err.(*httpStatusError); ok && se.Status == http.StatusUnauthorized {
+		// 令牌被撤销，重试时重新登录（不包装 httpStatusError，401 才会重试）
+		c.mu.Lock()
+		c.token = ""
+		c.mu.Unlock()
+		return fmt.Errorf("access token rejected: %v", err)
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// 网站的保留策略
+func (srv *Server) siteRetention(config SiteConfig) (time.Duration, int) {
+	age, keep := srv.cfg.RetentionAge, srv.cfg.RetentionItems
+	if config.RetentionAge > 0 {
+		age = time.Duration(config.RetentionAge)
+	}
+	if config.RetentionItems > 0 {
+		keep = config.RetentionItems
+	}
+	// 订阅源中的条目总是保留，否则下次刷新时会被当成新条目
+	if keep > 0 && keep < srv.siteFeedItems(config) {
+		keep = srv.siteFeedItems(config)
+	}
+	return age, keep
+}
+
+// 按保留策略删除网站的历史条目，返回删除的条数
+func (srv *Server) pruneItems(site string, config SiteConfig, now time.Time) (int, error) {
+	age, keep := srv.siteRetention(config)
+	if age <= 0 && keep <= 0 {
+		return 0, nil
+	}
+
+	recs, err := srv.store.QueryItems(site, ItemQuery{})
+	if err != nil {
+		return 0, err
+	}
+
+	// QueryItems 已按首次出现时间从新到旧排序，超出条数的是最旧的条目
+	var guids []string
+	cutoff := now.Add(-age).Unix()
+	for i, rec := range recs {
+		if (keep > 0 && i >= keep) || (age > 0 && rec.LastSeen < cutoff) {
+			guids = append(guids, rec.Item.GUID)
+		}
+	}
+	if len(guids) == 0 {
+		return 0, nil
+	}
+	return len(guids), srv.store.DeleteItems(site, guids)
+}
+
+// 对所有网站执行一次清理
+func (srv *Server) runRetentionGC() {
+	configs := srv.getAllSiteConfig()
+	sites := make([]string, 0, len(configs))
+	for site := range configs {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+
+	now := time.Now()
+	for _, site := range sites {
+		n, err := srv.pruneItems(site, configs[site], now)
+		if err != nil {
+			slog.Error("Failed to prune items", "site", site, "error", err)
+			continue
+		}
+		if n > 0 {
+			slog.Info("Pruned items", "site", site, "items", n)
+		}
+	}
+}
+
+// 每隔 interval 清理一次历史条目。开启 leader 选举时只由 leader 清理
+func (srv *Server) startRetentionGC(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(interval) {
+			if srv.refreshesEnabled() {
+				srv.runRetentionGC()
+			}
+		}
+	}()
+}
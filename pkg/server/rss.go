@@ -0,0 +1,724 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"rss-zhuaqu/pkg/feed"
+	"rss-zhuaqu/pkg/hooks"
+	"rss-zhuaqu/pkg/scraper"
+)
+
+// 订阅源的数据结构定义在 pkg/feed 中
+type (
+	RSSFeed        = feed.RSSFeed
+	Channel        = feed.Channel
+	Item           = feed.Item
+	ItemSource     = feed.ItemSource
+	MediaThumbnail = feed.MediaThumbnail
+)
+
+const mediaRSSNamespace = feed.MediaRSSNamespace
+
+// 条目发布时间的格式
+const pubDateLayout = feed.PubDateLayout
+
+// 网站配置
+type SiteConfig struct {
+	Name          string
+	URL           string
+	ItemSelector  string
+	TitleSelector string
+	LinkSelector  string
+	DescSelector  string
+	DateSelector  string
+	DateFormat    string
+	ImageSelector string
+
+	// 摘要最大字符数，0 表示不限制
+	MaxDescriptionLength int
+
+	// 必填字段（title、link、description、date、image），
+	// 缺失任一必填字段的条目会被跳过，默认为 title 和 link
+	RequiredFields []string
+
+	// 去除标题相同或相近的条目（同一文章出现在多个栏目时）
+	DedupTitles bool
+	// 标题相似度阈值（0~1），0 表示只去除完全相同的标题
+	DedupThreshold float64
+
+	// 抓取流水线，为空时按上面的选择器抓取首页
+	Pipeline []PipelineStep
+
+	// 缓存有效期，也是定时刷新的间隔，默认 10 分钟
+	CacheTTL Duration
+	// 缓存过期后仍可返回旧缓存（同时在后台刷新）的最长时间，0 表示不限制
+	MaxStale Duration
+	// 旧缓存超过 MaxStale 后的处理方式：block（默认）同步抓取，error 直接返回 503 并在后台刷新
+	StaleAction string
+
+	// 订阅源的条目数，当前页面条目不足时用历史条目补足，默认为 -feed-items
+	FeedItems int
+
+	// 每次抓取最多提取的条目数，超出的节点直接丢弃，默认 500。
+	// 防止选择器误匹配上千个节点时撑大订阅源和内存
+	MaxItems int
+
+	// 历史条目的保留时间（按最后一次出现的时间），默认为 -retention-age
+	RetentionAge Duration
+	// 最多保留的历史条目数，不少于订阅源的条目数，默认为 -retention-items
+	RetentionItems int
+
+	// 网站类型：为空时抓取文章列表，monitor 监视页面上 WatchSelector 的内容，
+	// 内容变化时生成一条新条目
+	Type          string
+	WatchSelector string
+
+	// 关键网站：没有缓存时 /readyz 返回未就绪
+	Critical bool
+
+	// 订阅源响应的 Cache-Control，为空时按缓存剩余有效期生成
+	CacheControl string
+
+	// 全文模式（fulltext=1）提取文章正文的选择器，为空时自动查找
+	ContentSelector string
+
+	// 访问订阅源需要的 Basic 认证，格式为 user:password，为空时使用 -feed-auth
+	BasicAuth string
+	// 访问订阅源需要令牌或签名地址（也接受 BasicAuth）
+	RequireToken bool
+
+	// 分类标签，用于 /sites?tag= 筛选
+	Tags []string
+
+	// 新条目发送到的 webhook 地址，另外还会发送到 -webhook
+	Webhooks []string
+	// 新条目发送到的 Slack、Discord incoming webhook，另外还会发送到 -slack-webhook、-discord-webhook
+	Slack   []string
+	Discord []string
+
+	// 匹配的新条目保存到 Pocket 或 Wallabag
+	ReadLater []ReadLaterRule
+
+	// ntfy、Gotify 通知的优先级，1 最低，5 最高，默认 3
+	Priority int
+
+	// 允许通过 lang 参数获取的译文语言，如 en、ja
+	Translate []string
+
+	// 条目的最低质量分数（0–100），低于该分数的条目被跳过，0 表示不过滤
+	MinQuality int
+
+	// 网站的上游订阅源，声明了 WebSub hub 时订阅该源，收到推送后立即刷新网站
+	UpstreamFeed string
+}
+
+// 未配置 MaxItems 时每次抓取最多提取的条目数
+const defaultMaxItems = 500
+
+// 网站每次抓取最多提取的条目数
+func siteMaxItems(config SiteConfig) int {
+	if config.MaxItems > 0 {
+		return config.MaxItems
+	}
+	return defaultMaxItems
+}
+
+// 旧缓存超过 MaxStale 后的处理方式
+const (
+	StaleActionBlock = "block"
+	StaleActionError = "error"
+)
+
+// 未配置 CacheTTL 时的缓存有效期
+const defaultCacheTTL = 10 * time.Minute
+
+// 网站的缓存有效期
+func siteCacheTTL(config SiteConfig) time.Duration {
+	if config.CacheTTL > 0 {
+		return time.Duration(config.CacheTTL)
+	}
+	return defaultCacheTTL
+}
+
+// 订阅源内容（不含 lastBuildDate）的哈希
+func feedHash(feed RSSFeed) string {
+	feed.Channel.LastBuildDate = ""
+	data, _ := json.Marshal(feed)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// 按缓存的剩余有效期设置 Cache-Control 和 Expires，中间缓存和阅读器在缓存过期前不必再请求。
+// 已过期的缓存 max-age 为 0。单个网站配置了 CacheControl 时直接使用
+func (srv *Server) setCacheHeaders(w http.ResponseWriter, sites []string, fc FeedCache) {
+	if len(sites) == 1 {
+		if config, _ := srv.getSiteConfig(sites[0]); config.CacheControl != "" {
+			w.Header().Set("Cache-Control", config.CacheControl)
+			return
+		}
+	}
+
+	maxAge := int(time.Until(fc.ExpireAt).Seconds())
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	protected := false
+	for _, site := range sites {
+		protected = protected || srv.feedProtected(site)
+	}
+	value := fmt.Sprintf("max-age=%d", maxAge)
+	if protected {
+		// 需要认证的订阅源不能被共享缓存保存
+		value = "private, " + value
+	} else if srv.cfg.CacheControl != "" {
+		value = srv.cfg.CacheControl + ", " + value
+	}
+	w.Header().Set("Cache-Control", value)
+	w.Header().Set("Expires", time.Now().Add(time.Duration(maxAge)*time.Second).UTC().Format(http.TimeFormat))
+}
+
+// 返回缓存的订阅源。ETag 为内容哈希，Last-Modified 为 lastBuildDate，
+// 阅读器带 If-None-Match 或 If-Modified-Since 请求且内容未变时返回 304
+func (srv *Server) writeFeed(w http.ResponseWriter, r *http.Request, site string, fc FeedCache) {
+	srv.writeFeedOf(w, r, []string{site}, fc)
+}
+
+// 同 writeFeed，订阅源由多个网站的条目组成
+func (srv *Server) writeFeedOf(w http.ResponseWriter, r *http.Request, sites []string, fc FeedCache) {
+	format, err := requestedFormat(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeUnknownFormat, err.Error())
+		return
+	}
+	if fc, err = srv.shapeFeed(r, sites, fc); err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	_, sp := srv.startSpan(r.Context(), "encode", spanKindInternal)
+	sp.SetAttr("format", format)
+	// 开启 WebSub 时 self 为固定的 topic 地址，并通过 Link 头声明 hub
+	feedURL, hub := srv.requestBaseURL(r)+r.RequestURI, ""
+	if len(sites) == 1 && srv.websubEnabled(sites[0]) {
+		feedURL, hub = srv.websubTopic(sites[0], format), srv.cfg.WebSubHub
+		w.Header().Add("Link", "<"+hub+`>; rel="hub"`)
+		w.Header().Add("Link", "<"+feedURL+`>; rel="self"`)
+	}
+	data, err := feed.Encode(fc.Feed, format, feedURL, hub)
+	sp.SetError(err)
+	sp.End()
+	if err != nil {
+		slog.Error("Failed to encode feed", "sites", sites, "format", format, "error", err)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeEncodeFailed, fmt.Sprintf("Failed to encode feed: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", feedFormatTypes[format])
+	if !r.URL.Query().Has("format") {
+		// 同一地址按 Accept 返回不同格式
+		w.Header().Add("Vary", "Accept")
+	}
+	srv.setCacheHeaders(w, sites, fc)
+	srv.setMaintenanceHeaders(w, fc)
+	if fc.Hash != "" {
+		// 不同格式是不同的表示，ETag 也不同
+		etag := fc.Hash[:16]
+		if format != FormatRSS {
+			etag += "-" + format
+		}
+		w.Header().Set("ETag", `"`+etag+`"`)
+	}
+	modified, _ := time.Parse(time.RFC1123Z, fc.Feed.Channel.LastBuildDate)
+	http.ServeContent(w, r, "", modified, bytes.NewReader(data))
+}
+
+// 初始化缓存：没有缓存或缓存已过期的网站按优先级预热，之后每个网站按自己的缓存有效期定时刷新。
+// 配置变化后再次调用时只处理新增的网站
+func (srv *Server) initCache() {
+	configs := srv.getAllSiteConfig()
+
+	var stale []string
+	srv.scheduledSitesMu.Lock()
+	for site, config := range configs {
+		if srv.scheduledSites[site] {
+			continue
+		}
+		srv.scheduledSites[site] = true
+
+		// 存储中未过期的缓存等到过期时再刷新
+		cached, ok := srv.getCachedFeed(site)
+		if ok {
+			srv.restoreRequests(site, cached.Requests)
+		}
+		if wait := time.Until(cached.ExpireAt); ok && wait > 0 {
+			go func(site string, config SiteConfig) {
+				srv.loadSiteImage(site, config)
+				srv.scheduleRefresh(site, wait)
+			}(site, config)
+			continue
+		}
+		stale = append(stale, site)
+	}
+	srv.scheduledSitesMu.Unlock()
+
+	// 全部预热完成后 /readyz 才就绪（没有关键网站时）
+	pending := int64(len(stale))
+	if pending == 0 {
+		srv.warmedUp.Store(true)
+	}
+	srv.warmup(stale, func(site string) {
+		config, _ := srv.getSiteConfig(site)
+		go srv.scheduleRefresh(site, siteCacheTTL(config))
+		if atomic.AddInt64(&pending, -1) == 0 {
+			srv.warmedUp.Store(true)
+		}
+	})
+}
+
+// 等待 wait 后开始按缓存有效期定时刷新，每次等待都加上随机抖动。网站从配置中删除后停止
+func (srv *Server) scheduleRefresh(site string, wait time.Duration) {
+	for {
+		sleptAt := time.Now()
+		d := srv.jitter(wait)
+		srv.setNextRefresh(site, sleptAt.Add(d))
+		time.Sleep(d)
+
+		config, ok := srv.getSiteConfig(site)
+		if !ok {
+			srv.scheduledSitesMu.Lock()
+			delete(srv.scheduledSites, site)
+			srv.scheduledSitesMu.Unlock()
+			srv.setNextRefresh(site, time.Time{})
+			return
+		}
+
+		// 不是 leader 时只从共享存储读取，暂停的网站不刷新
+		if !srv.refreshesEnabled() || srv.siteDisabled(site) {
+			wait = siteCacheTTL(config)
+			continue
+		}
+
+		// 等待期间缓存已经被刷新过（请求触发、手动刷新或共享存储的其他实例），等到新缓存过期
+		if cached, ok := srv.getCachedFeed(site); ok && cached.UpdatedAt.After(sleptAt) {
+			wait = time.Until(cached.ExpireAt)
+			continue
+		}
+		srv.refreshCache(site)
+		wait = siteCacheTTL(config)
+	}
+}
+
+// 刷新指定网站的缓存，同一网站同时只会有一次刷新，其余调用等待并共享结果
+func (srv *Server) refreshCache(site string) (FeedCache, error) {
+	return srv.refreshCacheContext(srv.appCtx, site)
+}
+
+// 同 refreshCache，ctx 中的 span 作为刷新的父 span。
+// ctx 取消或超时时不再等待，返回 ctx 的错误；已经开始的刷新由发起它的 ctx 控制
+func (srv *Server) refreshCacheContext(ctx context.Context, site string) (FeedCache, error) {
+	ch := srv.fetchGroup.DoChan(site, func() (interface{}, error) {
+		return srv.doRefreshCache(ctx, site)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return FeedCache{}, res.Err
+		}
+		return res.Val.(FeedCache), nil
+	case <-ctx.Done():
+		return FeedCache{}, fmt.Errorf("fetch of %s did not finish: %w", site, ctx.Err())
+	}
+}
+
+func (srv *Server) doRefreshCache(ctx context.Context, site string) (FeedCache, error) {
+	defer srv.sentryRecover(map[string]string{"site": site})
+	srv.refreshes.Add(1)
+	defer srv.refreshes.Done()
+	srv.refreshesInFlight.Add(1)
+	defer srv.refreshesInFlight.Add(-1)
+
+	// 共享存储时先获取分布式锁，等待期间其他实例已经刷新的直接使用其结果
+	unlock, fc, refreshed, err := srv.acquireRefreshLock(ctx, site)
+	if err != nil {
+		return FeedCache{}, err
+	}
+	defer unlock()
+	if refreshed {
+		slog.Debug("Cache was refreshed by another instance", "site", site)
+		return fc, nil
+	}
+
+	slog.Debug("Refreshing cache", "site", site)
+
+	ctx, sp := srv.startSpan(ctx, "refresh", spanKindInternal)
+	defer sp.End()
+	sp.SetAttr("site", site)
+
+	start := time.Now()
+	prev, _ := srv.getCachedFeed(site)
+	fc, err = srv.fetchAndGenerateRSS(ctx, site, prev)
+	srv.recordRefresh(site, start, err)
+	sp.SetError(err)
+	if err != nil {
+		slog.Warn("Failed to refresh cache", "site", site, "duration", time.Since(start), "error", err, "error_class", errorClass(err))
+		srv.recordFailure(site, err)
+		srv.captureRefreshError(site, err)
+		return FeedCache{}, err
+	}
+	srv.clearFailure(site)
+
+	// 内容没有变化时沿用上次的 lastBuildDate，阅读器不会看到虚假的更新
+	hash := feedHash(fc.Feed)
+	now := time.Now()
+	fc.Feed.Channel.LastBuildDate = now.Format(time.RFC1123Z)
+	if prev.Hash == hash && prev.Feed.Channel.LastBuildDate != "" {
+		fc.Feed.Channel.LastBuildDate = prev.Feed.Channel.LastBuildDate
+	}
+
+	config, _ := srv.getSiteConfig(site)
+	fc.UpdatedAt = now
+	fc.ExpireAt = now.Add(siteCacheTTL(config))
+	fc.Requests = srv.siteRequests(site)
+	fc.Hash = hash
+	srv.setCachedFeed(site, fc)
+	srv.publishRefreshed(site, fc.Feed)
+	// 内容变化时通知 WebSub hub 并推送新条目，首次抓取时还没有订阅者
+	if prev.Hash != "" && prev.Hash != hash {
+		go srv.pingWebSubHub(site)
+		if added := newItems(prev.Feed.Channel.Items, fc.Feed.Channel.Items); len(added) > 0 {
+			srv.notifyNewItems(site, added)
+		}
+	}
+	srv.observeFeedItems(site, len(fc.Feed.Channel.Items))
+
+	if fc.Skipped.Total() > 0 {
+		slog.Info("Cache refreshed", "site", site, "duration", time.Since(start), "items", len(fc.Feed.Channel.Items),
+			"skipped", fc.Skipped.Total(), "skip_reasons", fc.Skipped.String())
+	} else {
+		slog.Info("Cache refreshed", "site", site, "duration", time.Since(start), "items", len(fc.Feed.Channel.Items))
+	}
+	return fc, nil
+}
+
+// 生成RSS的HTTP处理函数
+func (srv *Server) generateRSSHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("sites") {
+		srv.mergedFeedHandler(w, r)
+		return
+	}
+	if r.URL.Query().Has("autotag") {
+		srv.autoTagFeedHandler(w, r)
+		return
+	}
+	site := r.URL.Query().Get("site")
+	if site == "" {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Missing 'site' parameter")
+		return
+	}
+	if !srv.checkFeedAuth(w, r, site) {
+		return
+	}
+	config, exists := srv.getSiteConfig(site)
+	if exists {
+		srv.countRequest(site)
+		srv.touchCachedFeed(site)
+	}
+	spanFromContext(r.Context()).SetAttr("site", site)
+
+	// 检查缓存
+	cached, ok := srv.getCachedFeed(site)
+
+	// 如果缓存存在且未过期，直接返回
+	if ok && time.Now().Before(cached.ExpireAt) {
+		srv.recordHit(site)
+		srv.writeFeed(w, r, site, cached)
+		return
+	}
+
+	// 在管理页面中暂停的网站不抓取，返回已有的缓存
+	if srv.siteDisabled(site) {
+		if !ok {
+			writeError(w, r, http.StatusServiceUnavailable, ErrCodeSiteDisabled, "Site is disabled")
+			return
+		}
+		srv.recordStaleHit(site)
+		w.Header().Set("Warning", `110 - "Response is Stale"`)
+		srv.writeFeed(w, r, site, cached)
+		return
+	}
+
+	// 维护模式下只返回已有的缓存
+	if srv.maintenanceMode() {
+		if !ok {
+			writeError(w, r, http.StatusServiceUnavailable, ErrCodeMaintenance, "Feed is not cached and refreshes are paused for maintenance")
+			return
+		}
+		srv.recordStaleHit(site)
+		srv.writeFeed(w, r, site, cached)
+		return
+	}
+
+	// 开启 leader 选举时 follower 不抓取，只返回共享存储中的缓存
+	if !srv.refreshesEnabled() {
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(srv.cfg.LeaderTTL.Seconds())))
+			writeError(w, r, http.StatusServiceUnavailable, ErrCodeNotCached, "Feed is not cached yet, waiting for the refresh leader")
+			return
+		}
+		srv.recordStaleHit(site)
+		w.Header().Set("Warning", `110 - "Response is Stale"`)
+		srv.writeFeed(w, r, site, cached)
+		return
+	}
+
+	if ok {
+		// 缓存已过期但仍在可用时间内，返回旧缓存并异步刷新
+		if config.MaxStale <= 0 || time.Since(cached.ExpireAt) <= time.Duration(config.MaxStale) {
+			go srv.refreshCache(site)
+			srv.recordStaleHit(site)
+			w.Header().Set("Warning", `110 - "Response is Stale"`)
+			srv.writeFeed(w, r, site, cached)
+			return
+		}
+
+		// 旧缓存超过可用时间，按配置直接返回 503，或继续往下同步获取
+		if config.StaleAction == StaleActionError {
+			go srv.refreshCache(site)
+			w.Header().Set("Retry-After", "30")
+			writeError(w, r, http.StatusServiceUnavailable, ErrCodeFeedTooStale, "Cached feed is too stale, refreshing")
+			return
+		}
+	}
+
+	// 最近抓取失败过，直接返回上次的错误
+	if f, ok := srv.recentFailure(site); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(f.Until).Seconds())+1))
+		writeError(w, r, codeStatus(f.Code, http.StatusInternalServerError), f.Code, fmt.Sprintf("Failed to generate RSS: %s", f.Err))
+		return
+	}
+
+	// 首次请求或旧缓存不可用，同步获取。多个客户端同时请求时只抓取一次
+	srv.recordMiss(site)
+	ctx, cancel := srv.fetchContext(r.Context())
+	defer cancel()
+	fc, err := srv.refreshCacheContext(ctx, site)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if ok {
+			status = http.StatusServiceUnavailable
+		}
+		writeError(w, r, fetchErrorStatus(err, status), fetchErrorCode(err), fmt.Sprintf("Failed to generate RSS: %v", err))
+		return
+	}
+
+	srv.writeFeed(w, r, site, fc)
+}
+
+// 获取所有网站配置
+func (srv *Server) getAllSiteConfig() map[string]SiteConfig {
+	srv.siteConfigsMu.RLock()
+	defer srv.siteConfigsMu.RUnlock()
+	if srv.siteConfigs != nil {
+		return srv.siteConfigs
+	}
+	return defaultSiteConfigs()
+}
+
+// 内置的网站配置
+func defaultSiteConfigs() map[string]SiteConfig {
+	return map[string]SiteConfig{
+		"example": {
+			Name:          "示例网站",
+			URL:           "https://example.com",
+			ItemSelector:  "article h2",
+			TitleSelector: "article h2",
+			LinkSelector:  "article a",
+			DescSelector:  "article p.summary",
+			DateSelector:  "article time",
+			DateFormat:    "2006-01-02",
+		},
+		"abc": {
+			Name:          "abc网站",
+			URL:           "https://www.abc.com/",
+			ItemSelector:  ".content article",
+			TitleSelector: "header a",
+			LinkSelector:  "header a",
+			DescSelector:  "p.note",
+			DateSelector:  "div.meta time",
+			DateFormat:    "2006-01-02",
+		},
+	}
+}
+
+// 获取网站配置
+func (srv *Server) getSiteConfig(site string) (SiteConfig, bool) {
+	configs := srv.getAllSiteConfig()
+
+	config, exists := configs[site]
+	return config, exists
+}
+
+// 抓取内容并生成RSS，返回的缓存包含订阅源和被跳过条目的原因统计，prev 为上次的缓存
+func (srv *Server) fetchAndGenerateRSS(ctx context.Context, site string, prev FeedCache) (FeedCache, error) {
+	config, exists := srv.getSiteConfig(site)
+	if !exists {
+		return FeedCache{}, fmt.Errorf("%w: %s", errSiteNotFound, site)
+	}
+
+	now := time.Now()
+	fc := FeedCache{Skipped: make(SkipStats), SiteImage: srv.siteImage(site)}
+	var items []Item
+	var err error
+	if config.Type == SiteTypeMonitor {
+		items, fc.MonitorValue, err = srv.monitorItems(ctx, site, config, prev.MonitorValue, now)
+	} else {
+		items, fc.Skipped, err = srv.scrapeItems(ctx, site, config, now)
+	}
+	if err != nil {
+		return FeedCache{}, err
+	}
+
+	var n int
+	if items, n = dedupGUIDs(items); n > 0 {
+		fc.Skipped["duplicate guid"] += n
+	}
+
+	// 当前页面的条目加上存储中的历史条目补足到 N 条，
+	// 已经从网站首页消失的条目仍会保留在订阅源中
+	if err := srv.store.SaveItems(site, items, now); err != nil {
+		return FeedCache{}, fmt.Errorf("%w: save items: %w", errStorage, err)
+	}
+	limit := srv.siteFeedItems(config)
+	guids := make([]string, 0, min(len(items), limit))
+	for _, item := range items[:min(len(items), limit)] {
+		guids = append(guids, item.GUID)
+	}
+	stored, err := srv.store.LookupItems(site, guids)
+	if err != nil {
+		return FeedCache{}, fmt.Errorf("%w: load stored items: %w", errStorage, err)
+	}
+	history, err := srv.store.ItemHistory(site, limit+len(items))
+	if err != nil {
+		return FeedCache{}, fmt.Errorf("%w: load item history: %w", errStorage, err)
+	}
+	items = mergeHistory(items, stored, history, limit)
+
+	fc.Feed = RSSFeed{
+		Version: "2.0",
+		MediaNS: mediaRSSNamespace,
+		Channel: Channel{
+			Title:       config.Name,
+			Link:        config.URL,
+			Description: fmt.Sprintf("RSS feed for %s", config.Name),
+			Image:       srv.getChannelImage(site, config),
+			Items:       items,
+		},
+	}
+	return fc, nil
+}
+
+// 按选择器或流水线抓取条目，同时返回被跳过条目的原因统计
+func (srv *Server) scrapeItems(ctx context.Context, site string, config SiteConfig, now time.Time) ([]Item, SkipStats, error) {
+	scraped, dropped, err := srv.runPipeline(ctx, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(scraped) == 0 {
+		return nil, nil, fmt.Errorf("%w on %s", errParseEmpty, config.URL)
+	}
+
+	_, sp := srv.startSpan(ctx, "extract", spanKindInternal)
+	defer sp.End()
+	sp.SetAttr("items.scraped", len(scraped))
+
+	var items []Item
+	skipped := make(SkipStats)
+	if dropped > 0 {
+		slog.Warn("Too many items matched, extra items dropped", "site", site, "max_items", siteMaxItems(config), "dropped", dropped)
+		skipped["over MaxItems"] += dropped
+	}
+	required := requiredFields(config)
+
+	for _, si := range scraped {
+		desc, pubDate, reason := checkScrapedItem(config, required, si)
+		if reason != "" {
+			skipped.Add(reason)
+			continue
+		}
+
+		// 没有日期的条目以首次抓取到的时间作为发布时间，之后的刷新沿用存储中的时间
+		if pubDate == "" {
+			pubDate = now.Format(pubDateLayout)
+		}
+
+		guid := si.Link
+		if guid == "" {
+			guid = si.Title
+		}
+
+		item := Item{
+			Title:       si.Title,
+			Link:        si.Link,
+			Description: desc,
+			PubDate:     pubDate,
+			GUID:        guid,
+		}
+		if si.Image != "" {
+			item.Description = fmt.Sprintf(`<p><img src="%s" /></p>%s`, html.EscapeString(si.Image), html.EscapeString(desc))
+			item.Thumbnail = &MediaThumbnail{URL: si.Image}
+		}
+		items = append(items, item)
+	}
+
+	if len(srv.itemHooks) > 0 {
+		n := len(items)
+		if items, err = hooks.ApplyItemHooks(ctx, site, items, srv.itemHooks); err != nil {
+			slog.Warn("Item hook failed", "site", site, "error", err)
+		}
+		if n > len(items) {
+			skipped["dropped by hook"] += n - len(items)
+		}
+	}
+
+	if config.DedupTitles {
+		var n int
+		if items, n = dedupItems(items, config.DedupThreshold); n > 0 {
+			skipped["duplicate title"] += n
+		}
+	}
+
+	return items, skipped, nil
+}
+
+// 检查提取到的条目，返回截断后的摘要、格式化的发布时间，以及条目被跳过的原因（为空时保留）
+func checkScrapedItem(config SiteConfig, required map[string]bool, si scrapedItem) (desc, pubDate, reason string) {
+	desc = scraper.TruncateText(si.Description, config.MaxDescriptionLength)
+	if si.Date != "" {
+		if t, err := time.Parse(config.DateFormat, si.Date); err == nil {
+			pubDate = t.Format(pubDateLayout)
+		}
+	}
+
+	reason = checkRequiredFields(required, map[string]string{
+		FieldTitle:       si.Title,
+		FieldLink:        si.Link,
+		FieldDescription: desc,
+		FieldDate:        pubDate,
+		FieldImage:       si.Image,
+	})
+	if reason == "" && config.MinQuality > 0 && qualityScore(si) < config.MinQuality {
+		reason = "low quality"
+	}
+	return desc, pubDate, reason
+}
@@ -0,0 +1,677 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"rss-zhuaqu/pkg/hooks"
+	"rss-zhuaqu/pkg/scraper"
+	"rss-zhuaqu/pkg/storage"
+)
+
+// 订阅源服务，配置和运行状态（网站配置、存储、通知队列等）都保存在 Server 中
+type Server struct {
+	cfg Config
+	// 注册路由的 ServeMux，handler 在外面包上中间件
+	mux *http.ServeMux
+	// 存储由 WithStorage 传入，不再按 -storage、-db 打开
+	storeGiven bool
+
+	// ACME（Let's Encrypt）自动申请证书的域名，设置后忽略 -tls-cert/-tls-key
+	acmeDomains []string
+
+	autoTagsMu sync.RWMutex
+	// 标签对应的匹配规则，同一标签可以有多条规则
+	autoTags map[string][]autoTagMatcher
+
+	natsPublisher  busPublisher
+	kafkaPublisher busPublisher
+
+	// 两个平台都限制每个 webhook 大约每秒一条
+	chatPacer   *pacer
+	slackSink   *chatSink
+	discordSink *chatSink
+
+	// 同一时间只写一次检查点
+	checkpointMu sync.Mutex
+
+	// 从配置文件或数据库加载的网站配置，为空时使用内置配置。
+	// 修改时整体替换，读取方拿到的 map 不会再被修改
+	siteConfigs   map[string]SiteConfig
+	siteConfigsMu sync.RWMutex
+	// siteConfigs 由配置文件或数据库中的网站和租户的网站合并而成
+	baseSiteConfigs   map[string]SiteConfig
+	tenantSiteConfigs map[string]SiteConfig
+
+	// 网站配置数据库，为空时网站配置只读
+	configDB *siteConfigDB
+
+	// 跨域访问的允许来源
+	// 所有接口默认允许的来源（管理接口除外），为空时不允许跨域
+	corsOrigins []string
+
+	// 在管理页面中暂停的网站，只保存在本实例内存中。
+	// 暂停期间不再定时刷新，请求返回已有的缓存
+	disabledSites   map[string]bool
+	disabledSitesMu sync.RWMutex
+
+	digestMu sync.Mutex
+	// 收件人 -> 等待发送的条目
+	digestPending map[string][]digestItem
+	// 下一次按计划发送的时间
+	digestNextAt time.Time
+
+	eventsMu     sync.Mutex
+	eventSeq     int64
+	recentEvents []itemEvent
+	// 订阅者及其关注的网站
+	eventSubs map[chan itemEvent]map[string]bool
+	// 退出时关闭所有事件流，否则长连接会一直占用到退出超时
+	streamsClosing   chan struct{}
+	closeStreamsOnce sync.Once
+
+	failures     map[string]fetchFailure
+	failuresLock sync.RWMutex
+
+	fullTextCache   map[string]fullTextEntry
+	fullTextCacheMu sync.Mutex
+
+	// /graphql 的接口定义：
+	//
+	// 	type Query {
+	// 	  sites(tag: String): [Site!]!
+	// 	  site(site: String!): Site
+	// 	  items(site: String, sites: [String!], tag: String, since: String, until: String, q: String, limit: Int): [Item!]!
+	// 	  status(site: String): [SiteStatus!]!
+	// 	}
+	// 	type Site { site name url tags protected feeds: Feeds status: SiteStatus
+	// 	            items(since: String, until: String, q: String, limit: Int): [Item!]! }
+	// 	type Feeds { rss atom json }
+	// 	type Item { site guid title link description pubDate image firstSeen lastSeen }
+	// 	type SiteStatus { site lastFetchAt lastSuccessAt lastError consecutiveFailures itemCount nextRefreshAt disabled }
+	graphQLSchema gqlSchema
+
+	// 启动时的预热已经完成
+	warmedUp atomic.Bool
+	// 正在退出，不再接收新流量
+	shuttingDown atomic.Bool
+
+	siteImages     map[string]SiteImage
+	siteImagesLock sync.RWMutex
+
+	incidentsMu sync.Mutex
+	incidents   map[string]*incidentState
+
+	feedIPRules  ipRules
+	adminIPRules ipRules
+
+	jobs   map[string]*refreshJob
+	jobsMu sync.Mutex
+
+	isLeader   atomic.Bool
+	instanceID string
+
+	// 维护模式：暂停所有后台刷新（定时刷新、预热、历史条目清理），请求只返回已有的缓存和存储中的订阅源，
+	// 用于网站故障处理或迁移期间。只保存在本实例内存中
+	maintenanceMu     sync.RWMutex
+	maintenanceSince  time.Time
+	maintenanceReason string
+
+	metricsMu        sync.Mutex
+	fetchDurations   map[string]*histogram    // site
+	fetchErrors      map[[2]string]uint64     // site, class
+	cacheRequests    map[[2]string]uint64     // site, result
+	feedItems        map[string]int           // site
+	requestDurations map[[2]string]*histogram // handler, code
+	notifications    map[[2]string]uint64     // sink, result
+	// 正在进行的刷新数和预热队列中等待的网站数
+	refreshesInFlight atomic.Int64
+	warmupQueued      atomic.Int64
+	// 设置 -max-conns 时主服务当前的连接数
+	openConns atomic.Int64
+
+	mqttPublisher busPublisher
+
+	// 等待发送的消息，队列满时丢弃新消息
+	deliveries chan delivery
+	// 推送方式，没有配置目标的推送方式返回 nil
+	itemSinks []itemSink
+
+	playgroundPages   map[string]playgroundPage
+	playgroundPagesMu sync.Mutex
+
+	itemHooks       []hooks.ItemHook
+	fetchMiddleware []hooks.FetchMiddleware
+	httpMiddleware  []hooks.HTTPMiddleware
+	pageScraperOnce sync.Once
+	pageScraperInst *scraper.Scraper
+
+	// 部署在反向代理之后：服务对外的路径前缀，以及信任其 X-Forwarded-* 请求头的代理地址
+	// 如 /feeds，为空时服务在根路径
+	basePath string
+	// 直接连接的地址在这些网段中时，才使用 X-Forwarded-For/Proto/Host/Prefix
+	trustedProxies []netip.Prefix
+
+	// 发布到对象存储的上传目标，为空时不上传
+	s3Target *s3Publisher
+
+	rateBuckets   map[string]*tokenBucket
+	rateBucketsMu sync.Mutex
+
+	readState   readerState
+	readStateMu sync.Mutex
+
+	wallabag *wallabagClient
+
+	// 已经开始定时刷新的网站
+	scheduledSites   map[string]bool
+	scheduledSitesMu sync.Mutex
+	// 同一网站的并发刷新合并为一次
+	fetchGroup singleflight.Group
+
+	// 由 DSN 得到的上报地址和公钥
+	sentryEndpoint   string
+	sentryKey        string
+	sentryReportedMu sync.Mutex
+	sentryReported   map[string]time.Time
+
+	// 后台抓取使用的 context，退出时取消，正在进行的抓取随之中止
+	appCtx    context.Context
+	cancelApp context.CancelFunc
+	// 正在进行的刷新，退出前等待它们结束，避免关闭存储后再写入
+	refreshes sync.WaitGroup
+
+	stats   map[string]*siteStats
+	statsMu sync.Mutex
+
+	// 每个网站下一次定时刷新的时间
+	nextRefreshes   map[string]time.Time
+	nextRefreshesMu sync.Mutex
+
+	// 当前使用的存储，默认为进程内存储
+	store Storage
+	// memory/file 存储中订阅源缓存的内存上限，0 表示不限制
+	cacheMaxBytes int64
+
+	// 网站配置变化后通知同步
+	subscribeTrigger chan struct{}
+
+	summaryMu    sync.Mutex
+	summaryCache map[string]summaryEntry
+	llmBudgetMu  sync.Mutex
+	llmBudget    struct {
+		minute   time.Time
+		perMin   int
+		day      string
+		requests int
+		tokens   int
+	}
+
+	telegramPacer *pacer
+
+	tenants   map[string]tenant // ID -> 租户
+	tenantsMu sync.RWMutex
+
+	feedTokens   map[string]feedToken // ID -> 令牌
+	feedTokensMu sync.RWMutex
+
+	serviceName  string
+	traceQueue   chan *span
+	traceFlushes chan chan struct{}
+
+	activeTranslator translator
+	translationMu    sync.Mutex
+	translationCache map[string]translationEntry
+
+	restHooks   map[string]restHook
+	restHooksMu sync.RWMutex
+
+	// 每个网站被请求的次数，刷新时随缓存一起保存，重启后用于决定预热顺序
+	requestCounts   map[string]int64
+	requestCountsMu sync.Mutex
+
+	upstreamSubsMu sync.Mutex
+	upstreamSubs   map[string]*upstreamSubscription
+	// 网站配置变化后通知检查订阅
+	upstreamSubscribeTrigger chan struct{}
+}
+
+// New 的选项
+type Option func(*Server)
+
+// 使用的配置，默认为 DefaultConfig()
+func WithConfig(cfg Config) Option {
+	return func(srv *Server) { srv.cfg = cfg }
+}
+
+// 使用已经打开的存储，不再按 -storage、-db 打开；Run 结束时会关闭它
+func WithStorage(st storage.Storage) Option {
+	return func(srv *Server) {
+		srv.store = st
+		srv.storeGiven = true
+	}
+}
+
+// 创建服务，配置由 WithConfig 传入；同一进程中可以创建多个互不影响的 Server
+func New(opts ...Option) (*Server, error) {
+	srv := newServer()
+	for _, opt := range opts {
+		opt(srv)
+	}
+	if err := setupLogger(srv.cfg.LogFormat, srv.cfg.LogLevel); err != nil {
+		return nil, err
+	}
+	srv.corsOrigins = splitList(srv.cfg.CORSOrigins)
+	srv.acmeDomains = splitList(srv.cfg.ACMEDomains)
+	srv.routes()
+	return srv, nil
+}
+
+// 创建各项状态的初始值
+func newServer() *Server {
+	srv := &Server{cfg: DefaultConfig(), mux: http.NewServeMux()}
+	srv.chatPacer = newPacer(time.Second)
+	srv.disabledSites = make(map[string]bool)
+	srv.digestPending = make(map[string][]digestItem)
+	srv.eventSubs = make(map[chan itemEvent]map[string]bool)
+	srv.streamsClosing = make(chan struct{})
+	srv.failures = make(map[string]fetchFailure)
+	srv.fullTextCache = make(map[string]fullTextEntry)
+	srv.siteImages = make(map[string]SiteImage)
+	srv.incidents = make(map[string]*incidentState)
+	srv.jobs = make(map[string]*refreshJob)
+	srv.instanceID = newInstanceID()
+	srv.fetchDurations = make(map[string]*histogram)
+	srv.fetchErrors = make(map[[2]string]uint64)
+	srv.cacheRequests = make(map[[2]string]uint64)
+	srv.feedItems = make(map[string]int)
+	srv.requestDurations = make(map[[2]string]*histogram)
+	srv.notifications = make(map[[2]string]uint64)
+	srv.deliveries = make(chan delivery, 1000)
+	srv.playgroundPages = make(map[string]playgroundPage)
+	srv.rateBuckets = make(map[string]*tokenBucket)
+	srv.readState = readerState{Read: map[int64]bool{}, Saved: map[int64]bool{}, IDs: map[string]int64{}}
+	srv.wallabag = &wallabagClient{srv: srv}
+	srv.scheduledSites = make(map[string]bool)
+	srv.sentryReported = make(map[string]time.Time)
+	srv.appCtx, srv.cancelApp = context.WithCancel(context.Background())
+	srv.stats = make(map[string]*siteStats)
+	srv.nextRefreshes = make(map[string]time.Time)
+	srv.store = storage.NewMemory(0)
+	srv.subscribeTrigger = make(chan struct{}, 1)
+	srv.summaryCache = make(map[string]summaryEntry)
+	srv.telegramPacer = newPacer(telegramChatInterval)
+	srv.tenants = make(map[string]tenant)
+	srv.feedTokens = make(map[string]feedToken)
+	srv.graphQLSchema = gqlSchema{
+		"Query": {
+			"sites": {Type: "Site", Args: map[string]string{"tag": "String"}, Resolve: srv.gqlResolveSites},
+			"site":  {Type: "Site", Args: map[string]string{"site": "String!"}, Resolve: srv.gqlResolveSite},
+			"items": {Type: "Item", Args: map[string]string{
+				"site": "String", "sites": "[String!]", "tag": "String",
+				"since": "String", "until": "String", "q": "String", "limit": "Int",
+			}, Resolve: srv.gqlResolveItems},
+			"status": {Type: "SiteStatus", Args: map[string]string{"site": "String"}, Resolve: srv.gqlResolveStatus},
+		},
+		"Site": {
+			"site": gqlScalar(func(p interface{}) interface{} { return p.(string) }),
+			"name": gqlScalar(func(p interface{}) interface{} { c, _ := srv.getSiteConfig(p.(string)); return c.Name }),
+			"url":  gqlScalar(func(p interface{}) interface{} { c, _ := srv.getSiteConfig(p.(string)); return c.URL }),
+			"tags": gqlScalar(func(p interface{}) interface{} {
+				c, _ := srv.getSiteConfig(p.(string))
+				if c.Tags == nil {
+					return []string{}
+				}
+				return c.Tags
+			}),
+			"protected": gqlScalar(func(p interface{}) interface{} { return srv.feedProtected(p.(string)) }),
+			"feeds": {Type: "Feeds", Resolve: func(ctx *gqlContext, p interface{}, args map[string]interface{}) (interface{}, error) {
+				return siteFeedURLs(srv.requestBaseURL(ctx.data.(*http.Request)), p.(string)), nil
+			}},
+			"status": {Type: "SiteStatus", Resolve: func(ctx *gqlContext, p interface{}, args map[string]interface{}) (interface{}, error) {
+				if err := srv.gqlCheckStatusAccess(ctx); err != nil {
+					return nil, err
+				}
+				return srv.getSiteStatus(p.(string)), nil
+			}},
+			"items": {Type: "Item", Args: map[string]string{"since": "String", "until": "String", "q": "String", "limit": "Int"},
+				Resolve: func(ctx *gqlContext, p interface{}, args map[string]interface{}) (interface{}, error) {
+					return srv.gqlQueryItems(ctx, []string{p.(string)}, args)
+				}},
+		},
+		"Feeds": gqlJSONFields(FormatRSS, FormatAtom, FormatJSON),
+		"Item":  gqlJSONFields("site", "guid", "title", "link", "description", "pubDate", "image", "firstSeen", "lastSeen"),
+		"SiteStatus": gqlJSONFields("site", "lastFetchAt", "lastSuccessAt", "lastError",
+			"consecutiveFailures", "itemCount", "nextRefreshAt", "disabled"),
+	}
+	srv.serviceName = "rss-spider"
+	srv.traceQueue = make(chan *span, traceQueueSize)
+	srv.traceFlushes = make(chan chan struct{})
+	srv.translationCache = make(map[string]translationEntry)
+	srv.restHooks = make(map[string]restHook)
+	srv.requestCounts = make(map[string]int64)
+	srv.itemSinks = []itemSink{srv.webhookSink, srv.telegramSink, srv.slackItems, srv.discordItems, srv.digestSink, srv.readLaterSink, srv.natsSink, srv.kafkaSink, srv.mqttSink, srv.ntfySink, srv.gotifySink, srv.restHookSink}
+	srv.upstreamSubs = make(map[string]*upstreamSubscription)
+	srv.upstreamSubscribeTrigger = make(chan struct{}, 1)
+	return srv
+}
+
+// 执行命令（见 commandUsage），args 为空时启动 HTTP 服务，直到收到 SIGINT/SIGTERM 后退出
+func (srv *Server) Run(args []string) error {
+	var cmd string
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	// ACME 模式下未指定端口时使用 443
+	if srv.acmeEnabled() && !srv.cfg.flagSet("port") {
+		srv.cfg.Port = "443"
+	}
+
+	// validate 命令只检查配置文件
+	if cmd == "validate" {
+		if err := srv.runValidateCommand(args[1:], srv.cfg.ConfigFile); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+		return nil
+	}
+
+	// migrate 命令总是执行迁移
+	if cmd == "migrate" {
+		srv.cfg.AutoMigrate = true
+	}
+
+	if srv.cfg.ConfigFile != "" {
+		if err := srv.loadConfig(srv.cfg.ConfigFile); err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+	}
+
+	if srv.cfg.ConfigDB != "" {
+		db, err := srv.openSiteConfigDB(srv.cfg.ConfigDB)
+		if err != nil {
+			return fmt.Errorf("failed to open config database: %w", err)
+		}
+		srv.configDB = db
+
+		configs, err := db.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load site configs from database: %w", err)
+		}
+		// 数据库中还没有网站时用 -config 文件初始化
+		if len(configs) == 0 && srv.siteConfigs != nil {
+			for site, sc := range srv.siteConfigs {
+				if err := db.Put(site, sc); err != nil {
+					return fmt.Errorf("failed to import site %s into config database: %w", site, err)
+				}
+			}
+			slog.Info("Imported sites into the config database", "sites", len(srv.siteConfigs), "config", srv.cfg.ConfigFile)
+			configs = srv.siteConfigs
+		}
+		srv.setSiteConfigs(configs)
+	}
+
+	srv.cacheMaxBytes = srv.cfg.CacheMaxMB << 20
+
+	if err := srv.loadFeedTokens(srv.cfg.TokenFile); err != nil {
+		return fmt.Errorf("failed to load feed tokens from %s: %w", srv.cfg.TokenFile, err)
+	}
+	if err := srv.loadRestHooks(srv.cfg.HooksFile); err != nil {
+		return fmt.Errorf("failed to load REST hooks from %s: %w", srv.cfg.HooksFile, err)
+	}
+
+	srv.basePath = normalizeBasePath(srv.cfg.BasePath)
+	if err := srv.initSentry(); err != nil {
+		return fmt.Errorf("invalid Sentry configuration: %w", err)
+	}
+	if err := srv.initTranslator(); err != nil {
+		return fmt.Errorf("invalid translation configuration: %w", err)
+	}
+	if err := srv.initChatSinks(); err != nil {
+		return fmt.Errorf("invalid message template: %w", err)
+	}
+	if srv.cfg.MergeDedupThreshold <= 0 || srv.cfg.MergeDedupThreshold > 1 {
+		return errors.New("-merge-dedup-threshold must be greater than 0 and at most 1")
+	}
+	if srv.cfg.MQTTQoS != 0 && srv.cfg.MQTTQoS != 1 {
+		return errors.New("-mqtt-qos must be 0 or 1")
+	}
+	srv.initBusPublishers()
+	if err := srv.validateSubscribers(); err != nil {
+		return fmt.Errorf("invalid reader subscription settings: %w", err)
+	}
+	if srv.digestEnabled() {
+		if err := srv.validateDigestSchedule(); err != nil {
+			return fmt.Errorf("invalid email digest schedule: %w", err)
+		}
+		if srv.cfg.SMTPAddr == "" || srv.cfg.SMTPFrom == "" {
+			return errors.New("-digest requires -smtp-addr and -smtp-from")
+		}
+	}
+	if srv.cfg.GotifyURL != "" && srv.cfg.GotifyToken == "" {
+		return errors.New("-gotify-url requires -gotify-token or GOTIFY_TOKEN")
+	}
+	if srv.cfg.TelegramToken != "" && len(srv.cfg.TelegramChats) == 0 {
+		slog.Warn("-telegram-token is set but no -telegram-chat is configured, nothing will be sent")
+	}
+	if srv.cfg.Maintenance {
+		srv.setMaintenance(true, "started with -maintenance")
+		slog.Warn("Starting in maintenance mode, background refreshes paused")
+	}
+	if srv.tenantsEnabled() {
+		if err := srv.loadTenants(srv.cfg.TenantsFile); err != nil {
+			return fmt.Errorf("failed to load tenants from %s: %w", srv.cfg.TenantsFile, err)
+		}
+	}
+	if err := srv.loadReaderState(srv.cfg.ReaderStateFile); err != nil {
+		return fmt.Errorf("failed to load reader state from %s: %w", srv.cfg.ReaderStateFile, err)
+	}
+
+	if srv.cfg.RefreshJitter < 0 || srv.cfg.RefreshJitter > 1 {
+		return errors.New("-refresh-jitter must be between 0 and 1")
+	}
+	if (srv.cfg.TLSCertFile == "") != (srv.cfg.TLSKeyFile == "") {
+		return errors.New("-tls-cert and -tls-key must be set together")
+	}
+	if srv.cfg.WebSubHub != "" && srv.cfg.PublicURL == "" {
+		return errors.New("-websub-hub requires -public-url")
+	}
+	var err error
+	if srv.trustedProxies, err = parsePrefixes(srv.cfg.TrustProxy); err != nil {
+		return fmt.Errorf("invalid -trust-proxy: %w", err)
+	}
+	if srv.feedIPRules, err = newIPRules(srv.cfg.FeedAllow, srv.cfg.FeedDeny); err != nil {
+		return fmt.Errorf("invalid feed IP rules: %w", err)
+	}
+	if srv.adminIPRules, err = newIPRules(srv.cfg.AdminAllow, srv.cfg.AdminDeny); err != nil {
+		return fmt.Errorf("invalid admin IP rules: %w", err)
+	}
+
+	if srv.cfg.S3Bucket != "" {
+		p, err := newS3Publisher(srv.cfg.S3Bucket, srv.cfg.S3Endpoint, srv.cfg.S3Region, srv.cfg.S3Prefix)
+		if err != nil {
+			return fmt.Errorf("failed to configure S3 upload: %w", err)
+		}
+		srv.s3Target = p
+	}
+
+	if srv.cfg.CacheFile != "" {
+		srv.cfg.Storage, srv.cfg.DB = "file", srv.cfg.CacheFile
+	} else if srv.cfg.Redis != "" {
+		srv.cfg.Storage, srv.cfg.DB = "redis", srv.cfg.Redis
+	}
+
+	if !srv.storeGiven {
+		if srv.store, err = srv.openStorage(srv.cfg.Storage, srv.cfg.DB); err != nil {
+			return fmt.Errorf("failed to open storage: %w", err)
+		}
+	}
+
+	// 执行命令后直接退出
+	if len(args) > 0 {
+		err := srv.runCommand(args)
+		if cerr := srv.store.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("command %s failed: %w", args[0], err)
+		}
+		return nil
+	}
+
+	if srv.cfg.CheckpointFile != "" {
+		if err := srv.loadCheckpoint(srv.cfg.CheckpointFile); err != nil {
+			return fmt.Errorf("failed to load checkpoint from %s: %w", srv.cfg.CheckpointFile, err)
+		}
+	}
+	srv.startCheckpointer()
+	srv.startNotifier()
+	srv.startDigester()
+	srv.startSubscriber()
+	srv.startEscalator()
+	srv.startUpstreamSubscriber()
+	if srv.cfg.RateLimit > 0 {
+		srv.startRateBucketCleaner()
+	}
+	if srv.cfg.OTLPEndpoint != "" {
+		if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+			srv.serviceName = name
+		}
+		srv.startTraceExporter()
+	}
+
+	if srv.cfg.LeaderElection {
+		if err := srv.startLeaderElection(); err != nil {
+			return fmt.Errorf("failed to start leader election: %w", err)
+		}
+	}
+
+	// 初始化缓存
+	srv.initCache()
+	if srv.configDB != nil {
+		srv.startConfigReloader(srv.cfg.ConfigDBReload)
+	}
+	srv.startRetentionGC(srv.cfg.GCInterval)
+
+	if srv.cfg.WriteTimeout > 0 && (srv.cfg.RequestTimeout <= 0 || srv.cfg.WriteTimeout <= srv.cfg.RequestTimeout) {
+		slog.Warn("-write-timeout does not exceed -request-timeout, slow requests are cut off without a 504", "write_timeout", srv.cfg.WriteTimeout, "request_timeout", srv.cfg.RequestTimeout)
+	}
+	if srv.cfg.Listen == "" {
+		srv.cfg.Listen = ":" + srv.cfg.Port
+	}
+	hs := srv.tuneServer(&http.Server{Addr: srv.cfg.Listen, Handler: srv.handler()}, false)
+	servers := []*http.Server{hs}
+	switch {
+	case srv.acmeEnabled():
+		// HTTP-01 验证需要 80 端口
+		m := srv.newACMEManager()
+		hs.TLSConfig = newACMETLSConfig(m)
+		redirectAddr := srv.cfg.HTTPSRedirectAddr
+		if redirectAddr == "" {
+			redirectAddr = ":80"
+		}
+		servers = append(servers, srv.startACMEHTTP(m, redirectAddr))
+	case srv.tlsEnabled():
+		hs.TLSConfig = newTLSConfig()
+		if srv.cfg.HTTPSRedirectAddr != "" {
+			servers = append(servers, srv.startHTTPSRedirect(srv.cfg.HTTPSRedirectAddr, srv.cfg.Port))
+		}
+	}
+	if srv.cfg.GRPCAddr != "" {
+		servers = append(servers, srv.startGRPCServer(srv.cfg.GRPCAddr))
+	}
+
+	// 收到 SIGINT/SIGTERM 后优雅退出
+	stopped := make(chan struct{})
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		srv.shutdown(servers...)
+		close(stopped)
+	}()
+
+	ln, err := listen(hs.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", hs.Addr, err)
+	}
+	ln = srv.limitListener(ln, srv.cfg.MaxConns)
+	slog.Info("Server started", "addr", hs.Addr, "tls", srv.tlsEnabled() || srv.acmeEnabled())
+	switch {
+	case srv.acmeEnabled():
+		err = hs.ServeTLS(ln, "", "")
+	case srv.tlsEnabled():
+		err = hs.ServeTLS(ln, srv.cfg.TLSCertFile, srv.cfg.TLSKeyFile)
+	default:
+		err = hs.Serve(ln)
+	}
+	if err != http.ErrServerClosed {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	<-stopped
+	slog.Info("Server stopped")
+	return nil
+}
+
+// 注册所有路由
+func (srv *Server) routes() {
+	srv.mux.HandleFunc("/healthz", healthzHandler)
+	srv.mux.HandleFunc("/readyz", srv.readyzHandler)
+	srv.mux.HandleFunc("/metrics", srv.metricsHandler)
+	srv.mux.HandleFunc("/status", srv.statusHandler)
+	srv.mux.HandleFunc("/sites", srv.sitesHandler)
+	srv.mux.HandleFunc("/opml", srv.opmlHandler)
+	srv.mux.HandleFunc("/events", srv.eventsHandler)
+	srv.mux.HandleFunc("/ws", srv.websocketHandler)
+	srv.mux.HandleFunc("/graphql", srv.graphQLHandler)
+	srv.mux.HandleFunc("/u/{key}/{path...}", srv.tenantHandler)
+	srv.mux.HandleFunc("/fever/", srv.feverHandler)
+	srv.mux.HandleFunc("/accounts/ClientLogin", srv.greaderLoginHandler)
+	srv.mux.HandleFunc(greaderPrefix, srv.greaderHandler)
+	srv.mux.HandleFunc("/rss", srv.generateRSSHandler)
+	srv.mux.HandleFunc("/rss/{file}", srv.feedPathHandler)
+	srv.mux.HandleFunc("/feed/{file}", srv.feedPathHandler)
+	srv.mux.HandleFunc("/websub/callback/{site}", srv.websubCallbackHandler)
+	srv.mux.HandleFunc("/api/history", srv.historyHandler)
+	srv.mux.HandleFunc("/api/triggers/items", srv.triggerItemsHandler)
+	srv.mux.HandleFunc("/api/hooks", srv.requireAdmin(srv.restHooksHandler))
+	srv.mux.HandleFunc("/api/hooks/{id}", srv.requireAdmin(srv.restHookHandler))
+	srv.mux.HandleFunc("/ifttt/v1/status", srv.iftttStatusHandler)
+	srv.mux.HandleFunc("/ifttt/v1/test/setup", srv.iftttTestSetupHandler)
+	srv.mux.HandleFunc("/ifttt/v1/triggers/new_item", srv.iftttNewItemHandler)
+	srv.mux.HandleFunc("/admin/snapshot", srv.requireAdmin(srv.snapshotHandler))
+	srv.mux.HandleFunc("/admin/cache", srv.requireAdmin(srv.cacheHandler))
+	srv.mux.HandleFunc("/admin/refresh", srv.requireAdmin(srv.refreshHandler))
+	srv.mux.HandleFunc("/admin/jobs", srv.requireAdmin(srv.jobHandler))
+	srv.mux.HandleFunc("/admin/sites", srv.requireAdmin(srv.siteConfigHandler))
+	srv.mux.HandleFunc("/admin/stats", srv.requireAdmin(srv.statsHandler))
+	srv.mux.HandleFunc("/admin/tokens", srv.requireAdmin(srv.tokensHandler))
+	srv.mux.HandleFunc("/admin/tenants", srv.requireAdmin(srv.tenantsAdminHandler))
+	srv.mux.HandleFunc("/admin/disable", srv.requireAdmin(srv.disableHandler))
+	srv.mux.HandleFunc("/admin/preview", srv.requireAdmin(srv.previewHandler))
+	srv.mux.HandleFunc("/admin/playground", srv.requireAdmin(srv.playgroundHandler))
+	srv.mux.HandleFunc("/admin/maintenance", srv.requireAdmin(srv.maintenanceHandler))
+	srv.mux.HandleFunc("/admin/digest", srv.requireAdmin(srv.digestHandler))
+	srv.mux.HandleFunc("/admin", srv.requireAdmin(srv.dashboardHandler))
+
+	srv.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "RSS生成服务已启动！\n使用方法: %s/rss?site=example\n网站列表: %s/opml", srv.requestBaseURL(r), srv.requestBaseURL(r))
+	})
+}
+
+// 所有路由外面包上访问日志、访问控制、限流、超时等中间件
+func (srv *Server) handler() http.Handler {
+	return srv.basePathHandler(srv.accessLogHandler(srv.ipFilterHandler(srv.rateLimitHandler(srv.corsHandler(srv.gzipHandler(srv.timeoutHandler(srv.sentryHandler(srv.pluginHandler(srv.instrumentHandler(srv.mux))))))))))
+}
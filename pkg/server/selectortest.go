@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"rss-zhuaqu/pkg/scraper"
+)
+
+// 选择器调试：执行网站的抓取步骤，逐个条目节点记录每个选择器取到的原始值，
+// 不写缓存，也不触发通知
+
+// 一个条目节点的调试结果
+type nodeTrace struct {
+	Page   string
+	Index  int
+	Fields []fieldTrace
+	// 解析后的发布时间，日期为空或不符合 DateFormat 时为空
+	PubDate string
+	Quality int
+	// 条目被跳过的原因，为空时条目会出现在订阅源中
+	Skipped string `json:",omitempty"`
+	// 条目节点的 HTML，超过 nodeHTMLLimit 个字符时截断
+	HTML string
+}
+
+const nodeHTMLLimit = 2000
+
+// 执行流水线中第一个 extract 步骤之前的 fetch、paginate 步骤，再按该步骤的选择器提取条目。
+// 之后的 detail、transform、summarize 步骤不执行；最多记录 MaxItems 个节点
+func (srv *Server) traceSelectors(ctx context.Context, config SiteConfig) ([]nodeTrace, error) {
+	state := &pipelineState{srv: srv, ctx: ctx, config: config}
+	for i, step := range sitePipeline(config) {
+		var err error
+		switch step.Type {
+		case StepFetch:
+			err = state.fetch(step)
+		case StepPaginate:
+			err = state.paginate(step)
+		case StepExtract:
+			return state.traceExtract(step), nil
+		default:
+			err = fmt.Errorf("step type %q before the extract step is not supported", step.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("pipeline step %d (%s): %w", i+1, step.Type, err)
+		}
+	}
+	return nil, fmt.Errorf("pipeline has no extract step")
+}
+
+func (p *pipelineState) traceExtract(step PipelineStep) []nodeTrace {
+	sels := stepSelectors(step, p.config)
+	required := requiredFields(p.config)
+	maxItems := siteMaxItems(p.config)
+
+	var nodes []nodeTrace
+	for _, pg := range p.pages {
+		pg.doc.Find(sels.Item).EachWithBreak(func(i int, s *goquery.Selection) bool {
+			if len(nodes) >= maxItems {
+				return false
+			}
+			n := nodeTrace{Page: pg.url, Index: i + 1}
+			si := scrapeNode(s, sels, pg.url, &n.Fields)
+			_, n.PubDate, n.Skipped = checkScrapedItem(p.config, required, si)
+			n.Quality = qualityScore(si)
+			if h, err := goquery.OuterHtml(s); err == nil {
+				n.HTML = scraper.TruncateText(h, nodeHTMLLimit)
+			}
+			nodes = append(nodes, n)
+			return true
+		})
+	}
+	return nodes
+}
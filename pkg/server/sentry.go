@@ -0,0 +1,243 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Sentry 错误上报：panic 和网站的抓取、解析错误以 envelope 格式发送到 Sentry，
+// 按网站和 error_code 分组，反复出现的提取失败在 Sentry 中可以直接看到和分派。sentryDSN 为空时不上报
+
+// 同一网站同一类错误的最短上报间隔，持续失败的网站不会每次刷新都产生一个事件
+const sentryRepeatInterval = 10 * time.Minute
+
+// 解析 DSN（https://<key>@<host>/<project>），启动时调用
+func (srv *Server) initSentry() error {
+	if srv.cfg.SentryDSN == "" {
+		return nil
+	}
+	u, err := url.Parse(srv.cfg.SentryDSN)
+	if err != nil {
+		return fmt.Errorf("invalid -sentry-dsn: %w", err)
+	}
+	dir, project := "", strings.Trim(u.Path, "/")
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		dir, project = "/"+project[:i], project[i+1:]
+	}
+	if u.User == nil || u.User.Username() == "" || project == "" || u.Host == "" {
+		return fmt.Errorf("invalid -sentry-dsn %q: expected https://<key>@<host>/<project>", u.Redacted())
+	}
+	srv.sentryKey = u.User.Username()
+	srv.sentryEndpoint = fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, dir, project)
+	if srv.cfg.SentryRelease == "" {
+		srv.cfg.SentryRelease = buildRevision()
+	}
+	return nil
+}
+
+// 构建时记录的 VCS 版本，作为默认的 release
+func buildRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+type sentryFrame struct {
+	Function string `json:"function"`
+	Filename string `json:"filename"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+type sentryStacktrace struct {
+	Frames []sentryFrame `json:"frames"`
+}
+
+type sentryMechanism struct {
+	Type    string `json:"type"`
+	Handled bool   `json:"handled"`
+}
+
+type sentryException struct {
+	Type       string            `json:"type"`
+	Value      string            `json:"value"`
+	Stacktrace *sentryStacktrace `json:"stacktrace,omitempty"`
+	Mechanism  *sentryMechanism  `json:"mechanism,omitempty"`
+}
+
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Message     string            `json:"message,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+	Fingerprint []string          `json:"fingerprint,omitempty"`
+	Exception   struct {
+		Values []sentryException `json:"values"`
+	} `json:"exception"`
+}
+
+func (srv *Server) newSentryEvent(level, typ, value string) *sentryEvent {
+	host, _ := os.Hostname()
+	ev := &sentryEvent{
+		EventID:     randomID() + randomID(),
+		Timestamp:   time.Now().UTC(),
+		Platform:    "go",
+		Level:       level,
+		Logger:      "rss-zhuaqu",
+		ServerName:  host,
+		Release:     srv.cfg.SentryRelease,
+		Environment: srv.cfg.SentryEnvironment,
+		Tags:        make(map[string]string),
+		Extra:       make(map[string]string),
+	}
+	ev.Exception.Values = []sentryException{{Type: typ, Value: value}}
+	return ev
+}
+
+// 上报刷新失败的错误，按网站和 error_code 分组。请求取消导致的失败不上报
+func (srv *Server) captureRefreshError(site string, err error) {
+	if srv.sentryEndpoint == "" || err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	code := fetchErrorCode(err)
+	key := site + "\x00" + code
+	srv.sentryReportedMu.Lock()
+	if time.Since(srv.sentryReported[key]) < sentryRepeatInterval {
+		srv.sentryReportedMu.Unlock()
+		return
+	}
+	srv.sentryReported[key] = time.Now()
+	srv.sentryReportedMu.Unlock()
+
+	config, _ := srv.getSiteConfig(site)
+	ev := srv.newSentryEvent("error", code, err.Error())
+	ev.Message = fmt.Sprintf("Failed to refresh %s: %v", site, err)
+	ev.Fingerprint = []string{"refresh", site, code}
+	ev.Tags["site"] = site
+	ev.Tags["error_code"] = code
+	ev.Tags["error_class"] = errorClass(err)
+	ev.Extra["url"] = config.URL
+	ev.Extra["name"] = config.Name
+	ev.Extra["consecutive_failures"] = fmt.Sprint(srv.getStats(site).ConsecutiveFailures)
+	srv.enqueueDelivery(delivery{
+		sink:   "sentry",
+		target: srv.sentryEndpoint,
+		site:   site,
+		send: func(ctx context.Context) error {
+			return srv.sendSentryEvent(ctx, ev)
+		},
+	})
+}
+
+// 在 defer 中调用：捕获 panic 并同步上报，然后继续 panic，保持原有的崩溃或 net/http 的恢复行为
+func (srv *Server) sentryRecover(tags map[string]string) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if srv.sentryEndpoint != "" && v != http.ErrAbortHandler {
+		srv.capturePanic(v, tags)
+	}
+	panic(v)
+}
+
+func (srv *Server) capturePanic(v interface{}, tags map[string]string) {
+	ev := srv.newSentryEvent("fatal", fmt.Sprintf("%T", v), fmt.Sprint(v))
+	if err, ok := v.(error); ok {
+		ev.Exception.Values[0].Value = err.Error()
+	}
+	for k, val := range tags {
+		ev.Tags[k] = val
+	}
+	exc := &ev.Exception.Values[0]
+	exc.Mechanism = &sentryMechanism{Type: "panic"}
+	exc.Stacktrace = &sentryStacktrace{Frames: panicFrames()}
+
+	// 进程可能马上退出，不经过发送队列
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.sendSentryEvent(ctx, ev); err != nil {
+		slog.Error("Failed to report panic to Sentry", "error", err)
+	}
+}
+
+// 发生 panic 的调用栈（从 panic 处开始），Sentry 要求由外到内排列
+func panicFrames() []sentryFrame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(5, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var out []sentryFrame
+	for {
+		f, more := frames.Next()
+		out = append(out, sentryFrame{
+			Function: f.Function,
+			Filename: f.File,
+			Lineno:   f.Line,
+			InApp:    strings.HasPrefix(f.Function, "main."),
+		})
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// 以 envelope 格式发送一个事件：envelope 头、条目头和事件各占一行
+func (srv *Server) sendSentryEvent(ctx context.Context, ev *sentryEvent) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	envHeader, _ := json.Marshal(map[string]interface{}{"event_id": ev.EventID, "sent_at": time.Now().UTC()})
+	itemHeader, _ := json.Marshal(map[string]interface{}{"type": "event", "length": len(payload)})
+	var body []byte
+	body = append(body, envHeader...)
+	body = append(body, '\n')
+	body = append(body, itemHeader...)
+	body = append(body, '\n')
+	body = append(body, payload...)
+	header := http.Header{"X-Sentry-Auth": {"Sentry sentry_version=7, sentry_client=rss-zhuaqu/1.0, sentry_key=" + srv.sentryKey}}
+	return postBody(ctx, srv.sentryEndpoint, "application/x-sentry-envelope", body, header)
+}
+
+// 捕获请求处理中的 panic 并上报
+func (srv *Server) sentryHandler(next http.Handler) http.Handler {
+	if srv.cfg.SentryDSN == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer srv.sentryRecover(map[string]string{
+			"http.method": r.Method,
+			"http.path":   r.URL.Path,
+			"request_id":  requestID(r.Context()),
+		})
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// 给服务器设置超时、请求头大小和 keep-alive。
+// longLived 的服务器（gRPC 流式调用）不设置整个请求的读写超时
+func (srv *Server) tuneServer(hs *http.Server, longLived bool) *http.Server {
+	hs.ReadHeaderTimeout = srv.cfg.ReadHeaderTimeout
+	hs.IdleTimeout = srv.cfg.IdleTimeout
+	hs.MaxHeaderBytes = srv.cfg.MaxHeaderBytes
+	if !longLived {
+		hs.ReadTimeout = srv.cfg.ReadTimeout
+		hs.WriteTimeout = srv.cfg.WriteTimeout
+	}
+	hs.SetKeepAlivesEnabled(srv.cfg.KeepAlives)
+	return hs
+}
+
+// 同时最多接受 n 个连接，超出时 Accept 等待已有连接关闭
+func (srv *Server) limitListener(ln net.Listener, n int) net.Listener {
+	if n <= 0 {
+		return ln
+	}
+	return &limitedListener{Listener: ln, srv: srv, sem: make(chan struct{}, n)}
+}
+
+type limitedListener struct {
+	net.Listener
+	srv *Server
+	sem chan struct{}
+}
+
+func (l *limitedListener) Accept() (net.Conn, error) {
+	l.sem <- struct{}{}
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		<-l.sem
+		return nil, err
+	}
+	l.srv.openConns.Add(1)
+	return &limitedConn{Conn: conn, release: func() { l.srv.openConns.Add(-1); <-l.sem }}, nil
+}
+
+type limitedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
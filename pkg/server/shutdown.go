@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+// 优雅退出：停止接受新连接并等待正在处理的请求，然后取消后台抓取，
+// 等待刷新结束后写入检查点、让出 leader、关闭存储（文件存储会写入磁盘）
+func (srv *Server) shutdown(servers ...*http.Server) {
+	slog.Info("Shutting down, waiting for in-flight requests", "timeout", srv.cfg.ShutdownTimeout)
+	srv.shuttingDown.Store(true)
+	srv.closeStreams()
+
+	ctx, cancel := context.WithTimeout(context.Background(), srv.cfg.ShutdownTimeout)
+	defer cancel()
+	for _, hs := range servers {
+		if err := hs.Shutdown(ctx); err != nil {
+			slog.Error("Failed to shut down HTTP server gracefully", "addr", hs.Addr, "error", err)
+		}
+	}
+
+	srv.cancelApp()
+	done := make(chan struct{})
+	go func() {
+		srv.refreshes.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("Timed out waiting for refreshes to stop")
+	}
+
+	if srv.cfg.CheckpointFile != "" {
+		if err := srv.saveCheckpoint(srv.cfg.CheckpointFile); err != nil {
+			slog.Error("Failed to write checkpoint", "file", srv.cfg.CheckpointFile, "error", err)
+		}
+	}
+	srv.flushTraces()
+	srv.resignLeader()
+	if err := srv.store.Close(); err != nil {
+		slog.Error("Failed to close storage", "error", err)
+	}
+	if srv.configDB != nil {
+		srv.configDB.Close()
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+)
+
+// /sites 返回的网站公开信息
+type siteInfo struct {
+	Site  string            `json:"site"`
+	Name  string            `json:"name"`
+	URL   string            `json:"url"`
+	Feeds map[string]string `json:"feeds"`
+	Tags  []string          `json:"tags"`
+	// 订阅源需要认证或令牌
+	Protected bool `json:"protected"`
+}
+
+// GET /sites 列出配置的网站及其订阅地址，tag=x 时只返回带该标签的网站，
+// 供索引页和客户端选择网站使用
+func (srv *Server) sitesHandler(w http.ResponseWriter, r *http.Request) {
+	tag := r.URL.Query().Get("tag")
+	base := srv.requestBaseURL(r)
+
+	configs := srv.getAllSiteConfig()
+	resp := make([]siteInfo, 0, len(configs))
+	for site, config := range configs {
+		if (tag != "" && !slices.Contains(config.Tags, tag)) || !srv.siteVisible(r, site) {
+			continue
+		}
+		tags := config.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		resp = append(resp, siteInfo{
+			Site:      site,
+			Name:      config.Name,
+			URL:       config.URL,
+			Feeds:     siteFeedURLs(base, site),
+			Tags:      tags,
+			Protected: srv.feedProtected(site),
+		})
+	}
+	sort.Slice(resp, func(i, j int) bool { return resp[i].Site < resp[j].Site })
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// 网站各格式订阅源的地址
+func siteFeedURLs(base, site string) map[string]string {
+	return map[string]string{
+		FormatRSS:  base + "/rss?site=" + url.QueryEscape(site),
+		FormatAtom: base + "/rss/" + url.PathEscape(site) + ".atom",
+		FormatJSON: base + "/feed/" + url.PathEscape(site) + ".json",
+	}
+}
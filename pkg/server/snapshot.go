@@ -0,0 +1,162 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+)
+
+// 缓存和历史条目的快照，用于在主机之间迁移或预热新部署
+type Snapshot struct {
+	Version   int
+	CreatedAt time.Time
+	Feeds     map[string]FeedCache
+	Items     map[string][]storedItem
+}
+
+const snapshotVersion = 1
+
+// 导出的网站：配置中的网站加上存储中有缓存的网站
+func (srv *Server) snapshotSites() ([]string, error) {
+	seen := make(map[string]bool)
+	for site := range srv.getAllSiteConfig() {
+		seen[site] = true
+	}
+	stored, err := srv.store.ListSites()
+	if err != nil {
+		return nil, err
+	}
+	for _, site := range stored {
+		seen[site] = true
+	}
+
+	sites := make([]string, 0, len(seen))
+	for site := range seen {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+	return sites, nil
+}
+
+// 导出存储中的全部缓存和历史条目
+func (srv *Server) exportSnapshot(w io.Writer) error {
+	snap, err := srv.buildSnapshot()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(snap)
+}
+
+// 读取存储中的全部缓存和历史条目
+func (srv *Server) buildSnapshot() (Snapshot, error) {
+	sites, err := srv.snapshotSites()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	snap := Snapshot{
+		Version:   snapshotVersion,
+		CreatedAt: time.Now(),
+		Feeds:     make(map[string]FeedCache),
+		Items:     make(map[string][]storedItem),
+	}
+	for _, site := range sites {
+		fc, ok, err := srv.store.Get(site)
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("site %s: %w", site, err)
+		}
+		if ok {
+			snap.Feeds[site] = fc
+		}
+
+		recs, err := srv.store.QueryItems(site, ItemQuery{})
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("site %s: %w", site, err)
+		}
+		if len(recs) > 0 {
+			snap.Items[site] = recs
+		}
+	}
+	return snap, nil
+}
+
+// 将快照导入存储，已有的缓存和同 GUID 的条目会被覆盖
+func (srv *Server) importSnapshot(r io.Reader) (feeds, items int, err error) {
+	var snap Snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return 0, 0, fmt.Errorf("decode snapshot: %w", err)
+	}
+	return srv.applySnapshot(snap)
+}
+
+// 将快照写入存储
+func (srv *Server) applySnapshot(snap Snapshot) (feeds, items int, err error) {
+	if snap.Version != snapshotVersion {
+		return 0, 0, fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+
+	for site, fc := range snap.Feeds {
+		if err := srv.store.Set(site, fc); err != nil {
+			return feeds, items, fmt.Errorf("site %s: %w", site, err)
+		}
+		feeds++
+	}
+	for site, recs := range snap.Items {
+		if err := srv.store.PutItems(site, recs); err != nil {
+			return feeds, items, fmt.Errorf("site %s: %w", site, err)
+		}
+		items += len(recs)
+	}
+	return feeds, items, nil
+}
+
+// 处理 export/import 命令，文件为 "-" 时使用标准输出/标准输入
+func (srv *Server) runSnapshotCommand(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: export|import <file>")
+	}
+	cmd, path := args[0], args[1]
+
+	switch cmd {
+	case "export":
+		if path == "-" {
+			return srv.exportSnapshot(os.Stdout)
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := srv.exportSnapshot(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		slog.Info("Snapshot exported", "path", path)
+		return nil
+	case "import":
+		var r io.Reader = os.Stdin
+		if path != "-" {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		feeds, items, err := srv.importSnapshot(r)
+		if err != nil {
+			return err
+		}
+		slog.Info("Imported snapshot", "feeds", feeds, "items", items)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", cmd)
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"time"
+)
+
+// 网站的运行统计，只保存在本实例内存中
+type siteStats struct {
+	Hits      int64
+	StaleHits int64
+	Misses    int64
+
+	Refreshes    int64
+	Failures     int64
+	LastRefresh  time.Time
+	LastDuration time.Duration
+	LastError    string
+	LastErrorAt  time.Time
+	LastSuccess  time.Time
+	// 连续失败次数，成功后清零
+	ConsecutiveFailures int64
+}
+
+// 在锁内修改网站的统计
+func (srv *Server) updateStats(site string, f func(st *siteStats)) {
+	srv.statsMu.Lock()
+	defer srv.statsMu.Unlock()
+	st, ok := srv.stats[site]
+	if !ok {
+		st = &siteStats{}
+		srv.stats[site] = st
+	}
+	f(st)
+}
+
+// 请求直接由未过期的缓存返回
+func (srv *Server) recordHit(site string) {
+	srv.updateStats(site, func(st *siteStats) { st.Hits++ })
+	srv.observeCacheRequest(site, "hit")
+}
+
+// 请求由过期的缓存返回
+func (srv *Server) recordStaleHit(site string) {
+	srv.updateStats(site, func(st *siteStats) { st.StaleHits++ })
+	srv.observeCacheRequest(site, "stale")
+}
+
+// 请求需要同步抓取
+func (srv *Server) recordMiss(site string) {
+	srv.updateStats(site, func(st *siteStats) { st.Misses++ })
+	srv.observeCacheRequest(site, "miss")
+}
+
+// 记录一次刷新的耗时和结果
+func (srv *Server) recordRefresh(site string, start time.Time, err error) {
+	var prev, cur int64
+	srv.updateStats(site, func(st *siteStats) {
+		defer func() { cur = st.ConsecutiveFailures }()
+		prev = st.ConsecutiveFailures
+		st.Refreshes++
+		st.LastRefresh = time.Now()
+		st.LastDuration = time.Since(start)
+		if err != nil {
+			st.Failures++
+			st.ConsecutiveFailures++
+			st.LastError = err.Error()
+			st.LastErrorAt = st.LastRefresh
+			return
+		}
+		st.ConsecutiveFailures = 0
+		st.LastSuccess = st.LastRefresh
+	})
+	srv.observeFetch(site, time.Since(start), err)
+	srv.checkFailureStreak(site, prev, cur, err)
+	srv.trackIncident(site, prev, cur, err)
+}
+
+// 各网站的连续失败次数
+func (srv *Server) failureStreaks() map[string]int64 {
+	srv.statsMu.Lock()
+	defer srv.statsMu.Unlock()
+	streaks := make(map[string]int64, len(srv.stats))
+	for site, st := range srv.stats {
+		streaks[site] = st.ConsecutiveFailures
+	}
+	return streaks
+}
+
+// 网站统计的副本
+func (srv *Server) getStats(site string) siteStats {
+	srv.statsMu.Lock()
+	defer srv.statsMu.Unlock()
+	if st, ok := srv.stats[site]; ok {
+		return *st
+	}
+	return siteStats{}
+}
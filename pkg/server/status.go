@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"time"
+)
+
+func (srv *Server) setNextRefresh(site string, t time.Time) {
+	srv.nextRefreshesMu.Lock()
+	defer srv.nextRefreshesMu.Unlock()
+	if t.IsZero() {
+		delete(srv.nextRefreshes, site)
+		return
+	}
+	srv.nextRefreshes[site] = t
+}
+
+func (srv *Server) nextRefresh(site string) time.Time {
+	srv.nextRefreshesMu.Lock()
+	defer srv.nextRefreshesMu.Unlock()
+	return srv.nextRefreshes[site]
+}
+
+// /status 返回的网站状态
+type siteStatus struct {
+	Site                string     `json:"site"`
+	LastFetchAt         *time.Time `json:"lastFetchAt"`
+	LastSuccessAt       *time.Time `json:"lastSuccessAt"`
+	LastError           string     `json:"lastError"`
+	ConsecutiveFailures int64      `json:"consecutiveFailures"`
+	ItemCount           int        `json:"itemCount"`
+	NextRefreshAt       *time.Time `json:"nextRefreshAt"`
+	Disabled            bool       `json:"disabled"`
+}
+
+// 时间为零值时返回 nil，JSON 中输出 null
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// GET /status 以 JSON 返回每个网站的抓取状态，供外部监控使用。租户的网站只对该租户和管理员返回
+func (srv *Server) statusHandler(w http.ResponseWriter, r *http.Request) {
+	configs := srv.getAllSiteConfig()
+	sites := make([]string, 0, len(configs))
+	for site := range configs {
+		// 租户的网站只对该租户和管理员可见
+		if srv.siteVisible(r, site) {
+			sites = append(sites, site)
+		}
+	}
+	sort.Strings(sites)
+
+	resp := make([]siteStatus, 0, len(sites))
+	for _, site := range sites {
+		resp = append(resp, srv.getSiteStatus(site))
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// 网站当前的抓取状态
+func (srv *Server) getSiteStatus(site string) siteStatus {
+	st := srv.getStats(site)
+	ss := siteStatus{
+		Site:                site,
+		LastFetchAt:         timePtr(st.LastRefresh),
+		LastSuccessAt:       timePtr(st.LastSuccess),
+		ConsecutiveFailures: st.ConsecutiveFailures,
+		NextRefreshAt:       timePtr(srv.nextRefresh(site)),
+		Disabled:            srv.siteDisabled(site),
+	}
+	// 最近一次抓取成功时不返回旧的错误
+	if st.ConsecutiveFailures > 0 {
+		ss.LastError = st.LastError
+	}
+	if fc, ok := srv.getCachedFeed(site); ok {
+		ss.ItemCount = len(fc.Feed.Channel.Items)
+		// 重启后统计清零，用缓存的更新时间作为最近一次成功的时间
+		if ss.LastSuccessAt == nil {
+			ss.LastSuccessAt = timePtr(fc.UpdatedAt)
+		}
+	}
+	return ss
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"log/slog"
+
+	"rss-zhuaqu/pkg/storage"
+)
+
+type (
+	Storage       = storage.Storage
+	ItemQuery     = storage.ItemQuery
+	Locker        = storage.Locker
+	LeaderElector = storage.LeaderElector
+	Flusher       = storage.Flusher
+	Toucher       = storage.Toucher
+	FeedCache     = storage.FeedCache
+	SiteImage     = storage.SiteImage
+	SkipStats     = storage.SkipStats
+	storedItem    = storage.StoredItem
+)
+
+// 按命令行参数打开指定类型的存储，path 为文件路径或 Redis 地址
+func (srv *Server) openStorage(kind, path string) (Storage, error) {
+	return storage.Open(kind, path,
+		storage.WithRedisPrefix(srv.cfg.RedisPrefix),
+		storage.WithMemoryLimit(srv.cacheMaxBytes),
+		storage.WithAutoMigrate(srv.cfg.AutoMigrate))
+}
+
+// 读取订阅源缓存，存储出错时视为没有缓存
+func (srv *Server) getCachedFeed(site string) (FeedCache, bool) {
+	fc, ok, err := srv.store.Get(site)
+	if err != nil {
+		slog.Error("Failed to read cache", "site", site, "error", err)
+		return FeedCache{}, false
+	}
+	return fc, ok
+}
+
+// 客户端请求了网站的订阅源，更新内存缓存的淘汰顺序
+func (srv *Server) touchCachedFeed(site string) {
+	if t, ok := srv.store.(Toucher); ok {
+		t.Touch(site)
+	}
+}
+
+// 写入订阅源缓存
+func (srv *Server) setCachedFeed(site string, fc FeedCache) {
+	if err := srv.store.Set(site, fc); err != nil {
+		slog.Error("Failed to write cache", "site", site, "error", err)
+	}
+}
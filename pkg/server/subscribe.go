@@ -0,0 +1,315 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 把生成的订阅源订阅到自建的 Miniflux、FreshRSS，添加网站后阅读器自动同步。
+// 只添加订阅，删除网站时不取消订阅
+
+// 外部阅读器
+type feedSubscriber interface {
+	Name() string
+	// 已经订阅的订阅源地址
+	Subscribed(ctx context.Context) (map[string]bool, error)
+	Subscribe(ctx context.Context, feedURL, title string) error
+}
+
+func (srv *Server) feedSubscribers() []feedSubscriber {
+	var subs []feedSubscriber
+	if srv.cfg.MinifluxURL != "" {
+		subs = append(subs, &minifluxClient{base: strings.TrimSuffix(srv.cfg.MinifluxURL, "/"), token: srv.cfg.MinifluxToken, category: srv.cfg.MinifluxCategory})
+	}
+	if srv.cfg.FreshRSSURL != "" {
+		subs = append(subs, &freshrssClient{base: strings.TrimSuffix(srv.cfg.FreshRSSURL, "/"), user: srv.cfg.FreshRSSUser, password: srv.cfg.FreshRSSPassword, category: srv.cfg.FreshRSSCategory})
+	}
+	return subs
+}
+
+// 网站配置变化时调用
+func (srv *Server) notifySubscribers() {
+	select {
+	case srv.subscribeTrigger <- struct{}{}:
+	default:
+	}
+}
+
+// 启动时和之后每隔 subscribeInterval 同步一次
+func (srv *Server) startSubscriber() {
+	subs := srv.feedSubscribers()
+	if len(subs) == 0 {
+		return
+	}
+	go func() {
+		var tick <-chan time.Time
+		if srv.cfg.SubscribeInterval > 0 {
+			ticker := time.NewTicker(srv.cfg.SubscribeInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			for _, sub := range subs {
+				srv.syncSubscriptions(sub)
+			}
+			select {
+			case <-tick:
+			case <-srv.subscribeTrigger:
+			case <-srv.appCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// 订阅阅读器中还没有的订阅源。需要认证的网站阅读器无法访问，跳过
+func (srv *Server) syncSubscriptions(sub feedSubscriber) {
+	ctx, cancel := context.WithTimeout(srv.appCtx, time.Minute)
+	defer cancel()
+
+	existing, err := sub.Subscribed(ctx)
+	if err != nil {
+		slog.Error("Failed to list reader subscriptions", "reader", sub.Name(), "error", err)
+		return
+	}
+	configs := srv.getBaseSiteConfigs()
+	sites := make([]string, 0, len(configs))
+	for site := range configs {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+
+	added := 0
+	for _, site := range sites {
+		if srv.feedProtected(site) {
+			continue
+		}
+		feedURL := srv.websubTopic(site, FormatRSS)
+		if existing[feedURL] {
+			continue
+		}
+		title := configs[site].Name
+		if title == "" {
+			title = site
+		}
+		if err := sub.Subscribe(ctx, feedURL, title); err != nil {
+			slog.Error("Failed to subscribe reader to feed", "reader", sub.Name(), "site", site, "error", err)
+			continue
+		}
+		added++
+		slog.Info("Subscribed reader to feed", "reader", sub.Name(), "site", site, "url", feedURL)
+	}
+	slog.Debug("Reader subscriptions synced", "reader", sub.Name(), "added", added)
+}
+
+// 发送请求，out 不为 nil 时解析 JSON 响应
+func readerRequest(req *http.Request, out interface{}) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &httpStatusError{Status: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Miniflux REST API，使用 API 令牌认证
+type minifluxClient struct {
+	base     string
+	token    string
+	category int
+}
+
+func (c *minifluxClient) Name() string { return "miniflux" }
+
+func (c *minifluxClient) request(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = strings.NewReader(string(data))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-Token", c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return readerRequest(req, out)
+}
+
+func (c *minifluxClient) Subscribed(ctx context.Context) (map[string]bool, error) {
+	var feeds []struct {
+		FeedURL string `json:"feed_url"`
+	}
+	if err := c.request(ctx, http.MethodGet, "/v1/feeds", nil, &feeds); err != nil {
+		return nil, err
+	}
+	urls := make(map[string]bool, len(feeds))
+	for _, f := range feeds {
+		urls[f.FeedURL] = true
+	}
+	return urls, nil
+}
+
+func (c *minifluxClient) Subscribe(ctx context.Context, feedURL, title string) error {
+	body := map[string]interface{}{"feed_url": feedURL}
+	if c.category > 0 {
+		body["category_id"] = c.category
+	}
+	return c.request(ctx, http.MethodPost, "/v1/feeds", body, nil)
+}
+
+// FreshRSS 的 Google Reader API，使用用户名和 API 密码登录
+type freshrssClient struct {
+	base     string
+	user     string
+	password string
+	category string
+	auth     string
+}
+
+func (c *freshrssClient) Name() string { return "freshrss" }
+
+// ClientLogin 登录，获取 Auth 令牌
+func (c *freshrssClient) login(ctx context.Context) error {
+	form := url.Values{"Email": {c.user}, "Passwd": {c.password}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.base+"/accounts/ClientLogin", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 != 2 {
+		return &httpStatusError{Status: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if auth, ok := strings.CutPrefix(strings.TrimSpace(line), "Auth="); ok {
+			c.auth = auth
+			return nil
+		}
+	}
+	return fmt.Errorf("no Auth token in ClientLogin response")
+}
+
+// 发送请求，令牌过期（401）时重新登录一次
+func (c *freshrssClient) request(ctx context.Context, method, path string, form url.Values, out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		if c.auth == "" {
+			if err := c.login(ctx); err != nil {
+				return err
+			}
+		}
+		var body io.Reader
+		if form != nil {
+			body = strings.NewReader(form.Encode())
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "GoogleLogin auth="+c.auth)
+		if form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		err = readerRequest(req, out)
+		if se, ok := err.(*httpStatusError); ok && se.Status == http.StatusUnauthorized && attempt == 0 {
+			c.auth = ""
+			continue
+		}
+		return err
+	}
+}
+
+func (c *freshrssClient) Subscribed(ctx context.Context) (map[string]bool, error) {
+	var list struct {
+		Subscriptions []struct {
+			URL string `json:"url"`
+		} `json:"subscriptions"`
+	}
+	if err := c.request(ctx, http.MethodGet, "/reader/api/0/subscription/list?output=json", nil, &list); err != nil {
+		return nil, err
+	}
+	urls := make(map[string]bool, len(list.Subscriptions))
+	for _, s := range list.Subscriptions {
+		urls[s.URL] = true
+	}
+	return urls, nil
+}
+
+// 修改订阅需要的 T 令牌
+func (c *freshrssClient) token(ctx context.Context) (string, error) {
+	if c.auth == "" {
+		if err := c.login(ctx); err != nil {
+			return "", err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+"/reader/api/0/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "GoogleLogin auth="+c.auth)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode/100 != 2 {
+		return "", &httpStatusError{Status: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (c *freshrssClient) Subscribe(ctx context.Context, feedURL, title string) error {
+	t, err := c.token(ctx)
+	if err != nil {
+		return err
+	}
+	form := url.Values{"ac": {"subscribe"}, "s": {"feed/" + feedURL}, "t": {title}, "T": {t}}
+	if c.category != "" {
+		form.Set("a", "user/-/label/"+c.category)
+	}
+	return c.request(ctx, http.MethodPost, "/reader/api/0/subscription/edit", form, nil)
+}
+
+// 检查外部阅读器的参数
+func (srv *Server) validateSubscribers() error {
+	if srv.cfg.MinifluxURL == "" && srv.cfg.FreshRSSURL == "" {
+		return nil
+	}
+	if srv.cfg.PublicURL == "" {
+		return fmt.Errorf("-miniflux-url and -freshrss-url require -public-url")
+	}
+	if srv.cfg.MinifluxURL != "" && srv.cfg.MinifluxToken == "" {
+		return fmt.Errorf("-miniflux-url requires -miniflux-token or MINIFLUX_API_TOKEN")
+	}
+	if srv.cfg.FreshRSSURL != "" && (srv.cfg.FreshRSSUser == "" || srv.cfg.FreshRSSPassword == "") {
+		return fmt.Errorf("-freshrss-url requires -freshrss-user and -freshrss-password or FRESHRSS_API_PASSWORD")
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"rss-zhuaqu/pkg/scraper"
+)
+
+// 摘要生成：流水线的 summarize 步骤把条目正文发送到 OpenAI 兼容的接口，用生成的摘要替换条目的摘要。
+// 摘要按 GUID 缓存，请求数和 token 数有每分钟、每天的上限，超出时保留原摘要，下次刷新再生成
+
+const (
+	// 缓存的摘要数，超过时淘汰最早生成的
+	summaryCacheLimit = 5000
+	// 正文少于这么多字符时不生成摘要
+	summaryMinInput = 200
+	// summarize 步骤默认的系统提示词
+	defaultLLMPrompt = "Summarize the following article in 2-3 sentences, in the same language as the article. Reply with the summary only."
+)
+
+// 缓存的摘要
+type summaryEntry struct {
+	Summary   string
+	CreatedAt time.Time
+}
+
+var errLLMBudget = errors.New("llm request or token budget exhausted")
+
+// 条目的 GUID，与 scrapeItems 中生成的相同
+func scrapedGUID(item scrapedItem) string {
+	if item.Link != "" {
+		return item.Link
+	}
+	return item.Title
+}
+
+// summarize 步骤：前 MaxItems 个条目（0 表示全部）以 Concurrency 的并发数（默认 2）生成摘要，
+// Prompt 覆盖 -llm-prompt。摘要基于条目当前的摘要，通常先用 detail 步骤抓取文章正文
+func (p *pipelineState) summarize(step PipelineStep) error {
+	if p.srv.cfg.LLMURL == "" {
+		return nil
+	}
+	n := len(p.items)
+	if step.MaxItems > 0 && step.MaxItems < n {
+		n = step.MaxItems
+	}
+	concurrency := step.Concurrency
+	if concurrency <= 0 {
+		concurrency = 2
+	}
+	prompt := step.Prompt
+	if prompt == "" {
+		prompt = p.srv.cfg.LLMPrompt
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	var budgetOnce sync.Once
+	for i := 0; i < n; i++ {
+		item := &p.items[i]
+		guid := scrapedGUID(*item)
+		if s, ok := p.srv.cachedSummary(guid); ok {
+			item.Description = s
+			continue
+		}
+		if len([]rune(item.Description)) < summaryMinInput {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			summary, err := p.srv.generateSummary(p.ctx, prompt, item.Title, item.Description)
+			switch {
+			case errors.Is(err, errLLMBudget):
+				budgetOnce.Do(func() {
+					slog.Warn("LLM budget exhausted, keeping original descriptions", "url", p.config.URL)
+				})
+				return
+			case err != nil:
+				if p.ctx.Err() == nil {
+					slog.Warn("Failed to summarize item", "url", item.Link, "error", err)
+				}
+				return
+			}
+			p.srv.storeSummary(guid, summary)
+			item.Description = summary
+		}()
+	}
+	wg.Wait()
+	return p.ctx.Err()
+}
+
+func (srv *Server) cachedSummary(guid string) (string, bool) {
+	srv.summaryMu.Lock()
+	defer srv.summaryMu.Unlock()
+	e, ok := srv.summaryCache[guid]
+	return e.Summary, ok
+}
+
+// 缓存摘要，超过上限时淘汰最早生成的
+func (srv *Server) storeSummary(guid, summary string) {
+	srv.summaryMu.Lock()
+	defer srv.summaryMu.Unlock()
+	srv.summaryCache[guid] = summaryEntry{Summary: summary, CreatedAt: time.Now()}
+	for len(srv.summaryCache) > summaryCacheLimit {
+		var oldest string
+		var oldestAt time.Time
+		for g, e := range srv.summaryCache {
+			if oldest == "" || e.CreatedAt.Before(oldestAt) {
+				oldest, oldestAt = g, e.CreatedAt
+			}
+		}
+		delete(srv.summaryCache, oldest)
+	}
+}
+
+// 检查点中保存的摘要缓存
+func (srv *Server) summaryEntries() map[string]summaryEntry {
+	srv.summaryMu.Lock()
+	defer srv.summaryMu.Unlock()
+	if len(srv.summaryCache) == 0 {
+		return nil
+	}
+	entries := make(map[string]summaryEntry, len(srv.summaryCache))
+	for g, e := range srv.summaryCache {
+		entries[g] = e
+	}
+	return entries
+}
+
+func (srv *Server) restoreSummaries(entries map[string]summaryEntry) {
+	srv.summaryMu.Lock()
+	defer srv.summaryMu.Unlock()
+	for g, e := range entries {
+		srv.summaryCache[g] = e
+	}
+}
+
+// 占用一次请求的额度，超出每分钟或每天的上限时返回 errLLMBudget
+func (srv *Server) reserveLLMRequest() error {
+	srv.llmBudgetMu.Lock()
+	defer srv.llmBudgetMu.Unlock()
+	now := time.Now()
+	if minute := now.Truncate(time.Minute); !minute.Equal(srv.llmBudget.minute) {
+		srv.llmBudget.minute, srv.llmBudget.perMin = minute, 0
+	}
+	if day := now.Format("2006-01-02"); day != srv.llmBudget.day {
+		srv.llmBudget.day, srv.llmBudget.requests, srv.llmBudget.tokens = day, 0, 0
+	}
+	if srv.cfg.LLMRatePerMinute > 0 && srv.llmBudget.perMin >= srv.cfg.LLMRatePerMinute ||
+		srv.cfg.LLMDailyRequests > 0 && srv.llmBudget.requests >= srv.cfg.LLMDailyRequests ||
+		srv.cfg.LLMDailyTokens > 0 && srv.llmBudget.tokens >= srv.cfg.LLMDailyTokens {
+		return errLLMBudget
+	}
+	srv.llmBudget.perMin++
+	srv.llmBudget.requests++
+	return nil
+}
+
+func (srv *Server) recordLLMTokens(n int) {
+	srv.llmBudgetMu.Lock()
+	srv.llmBudget.tokens += n
+	srv.llmBudgetMu.Unlock()
+}
+
+// 调用 chat completions 接口生成摘要
+func (srv *Server) generateSummary(ctx context.Context, prompt, title, text string) (string, error) {
+	if err := srv.reserveLLMRequest(); err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"model": srv.cfg.LLMModel,
+		"messages": []map[string]string{
+			{"role": "system", "content": prompt},
+			{"role": "user", "content": fmt.Sprintf("Title: %s\n\n%s", title, scraper.TruncateText(text, srv.cfg.LLMMaxInput))},
+		},
+		"max_tokens":  srv.cfg.LLMMaxTokens,
+		"temperature": 0.2,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(srv.cfg.LLMURL, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if srv.cfg.LLMAPIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+srv.cfg.LLMAPIKey)
+	}
+
+	var resp struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := readerRequest(req, &resp); err != nil {
+		return "", err
+	}
+	srv.recordLLMTokens(resp.Usage.TotalTokens)
+	if len(resp.Choices) == 0 {
+		return "", errors.New("llm response has no choices")
+	}
+	summary := scraper.NormalizeText(resp.Choices[0].Message.Content)
+	if summary == "" {
+		return "", errors.New("llm returned an empty summary")
+	}
+	return summary, nil
+}
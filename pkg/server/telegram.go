@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// 同一聊天两次发送的最小间隔，Telegram 限制群组每分钟 20 条
+const telegramChatInterval = 3 * time.Second
+
+// 单条消息的最大长度
+const telegramMaxMessage = 4096
+
+// 一次刷新的新条目合并为一条消息（过长时拆分），发送到匹配该网站的每个聊天
+func (srv *Server) telegramSink(site string, config SiteConfig, items []Item) []delivery {
+	if srv.cfg.TelegramToken == "" {
+		return nil
+	}
+	var ds []delivery
+	for _, route := range srv.cfg.TelegramChats {
+		if !route.Filter.match(site, config) {
+			continue
+		}
+		for _, text := range telegramMessages(site, config, items) {
+			chat, text, preview := route.Target, text, len(items) == 1
+			ds = append(ds, delivery{
+				sink:   "telegram",
+				target: chat,
+				site:   site,
+				send: func(ctx context.Context) error {
+					if err := srv.telegramPacer.wait(ctx, chat); err != nil {
+						return err
+					}
+					return srv.sendTelegramMessage(ctx, chat, text, preview)
+				},
+			})
+		}
+	}
+	return ds
+}
+
+// 消息内容（HTML）：网站名加上每个条目的标题链接，超过长度限制时拆分为多条
+func telegramMessages(site string, config SiteConfig, items []Item) []string {
+	name := config.Name
+	if name == "" {
+		name = site
+	}
+	header := "<b>" + html.EscapeString(name) + "</b>\n"
+
+	var msgs []string
+	var b strings.Builder
+	for _, item := range items {
+		title := item.Title
+		if title == "" {
+			title = item.Link
+		}
+		line := "• " + html.EscapeString(title) + "\n"
+		if item.Link != "" {
+			line = `• <a href="` + html.EscapeString(item.Link) + `">` + html.EscapeString(title) + "</a>\n"
+		}
+		if b.Len() > 0 && b.Len()+len(line) > telegramMaxMessage {
+			msgs = append(msgs, b.String())
+			b.Reset()
+		}
+		if b.Len() == 0 {
+			b.WriteString(header)
+		}
+		b.WriteString(line)
+	}
+	if b.Len() > 0 {
+		msgs = append(msgs, b.String())
+	}
+	return msgs
+}
+
+// Bot API 的响应
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// 调用 sendMessage。被限流（429）时按 retry_after 重试
+func (srv *Server) sendTelegramMessage(ctx context.Context, chat, text string, preview bool) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"chat_id":              chat,
+		"text":                 text,
+		"parse_mode":           "HTML",
+		"link_preview_options": map[string]bool{"is_disabled": !preview},
+	})
+	if err != nil {
+		return err
+	}
+	endpoint := strings.TrimSuffix(srv.cfg.TelegramAPI, "/") + "/bot" + srv.cfg.TelegramToken + "/sendMessage"
+	err = postBody(ctx, endpoint, "application/json", body, nil)
+
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		var resp telegramResponse
+		if json.Unmarshal([]byte(statusErr.Body), &resp) == nil && resp.Description != "" {
+			if resp.Parameters.RetryAfter > 0 {
+				statusErr.RetryAfter = time.Duration(resp.Parameters.RetryAfter) * time.Second
+			}
+			statusErr.Body = ""
+			return fmt.Errorf("telegram: %s: %w", resp.Description, statusErr)
+		}
+	}
+	return err
+}
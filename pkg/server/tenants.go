@@ -0,0 +1,470 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 多租户：每个 API 密钥（租户）拥有自己的网站配置，订阅地址中带有密钥（/u/<key>/rss?site=x）。
+// 租户的网站以 <租户 ID>:<网站> 的名称加入全局配置，缓存、存储和定时刷新与普通网站相同
+type tenant struct {
+	ID        string                `json:"id"`
+	Key       string                `json:"key"`
+	Sites     map[string]SiteConfig `json:"sites"`
+	CreatedAt time.Time             `json:"createdAt"`
+}
+
+// 租户 ID 和网站名的格式，普通网站的名称也在 validateSiteConfig 中检查
+var tenantNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// 租户网站在全局配置中的名称
+func tenantSiteName(id, site string) string {
+	return id + ":" + site
+}
+
+// 拆分租户网站的名称，普通网站返回 false
+func splitTenantSite(name string) (id, site string, ok bool) {
+	return strings.Cut(name, ":")
+}
+
+// 是否为租户的网站
+func isTenantSite(name string) bool {
+	_, _, ok := splitTenantSite(name)
+	return ok
+}
+
+func (srv *Server) tenantsEnabled() bool {
+	return srv.cfg.TenantsFile != ""
+}
+
+// 读取租户文件，文件不存在时没有租户
+func (srv *Server) loadTenants(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var list []tenant
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	srv.tenantsMu.Lock()
+	for _, t := range list {
+		srv.tenants[t.ID] = t
+	}
+	srv.tenantsMu.Unlock()
+	srv.applyTenantSites()
+	return nil
+}
+
+// 写入租户文件，调用方持有 tenantsMu
+func (srv *Server) saveTenants(path string) error {
+	data, err := json.MarshalIndent(srv.sortedTenants(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (srv *Server) sortedTenants() []tenant {
+	list := make([]tenant, 0, len(srv.tenants))
+	for _, t := range srv.tenants {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// 把所有租户的网站加入全局配置
+func (srv *Server) applyTenantSites() {
+	configs := make(map[string]SiteConfig)
+	srv.tenantsMu.RLock()
+	for id, t := range srv.tenants {
+		for site, sc := range t.Sites {
+			// 文件中的配置也按租户的限制检查，不符合的网站不加载
+			sc, err := validateTenantSite(site, sc)
+			if err != nil {
+				slog.Warn("Skipping invalid tenant site", "tenant", id, "error", err)
+				continue
+			}
+			configs[tenantSiteName(id, site)] = sc
+		}
+	}
+	srv.tenantsMu.RUnlock()
+	srv.setTenantSiteConfigs(configs)
+}
+
+// 按密钥查找租户
+func (srv *Server) tenantByKey(key string) (tenant, bool) {
+	if key == "" {
+		return tenant{}, false
+	}
+	srv.tenantsMu.RLock()
+	defer srv.tenantsMu.RUnlock()
+	for _, t := range srv.tenants {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(t.Key)) == 1 {
+			return t, true
+		}
+	}
+	return tenant{}, false
+}
+
+type tenantKey struct{}
+
+// 请求所属的租户：/u/<key>/ 下的请求，或者带有 X-API-Key 请求头
+func (srv *Server) requestTenant(r *http.Request) string {
+	if id, ok := r.Context().Value(tenantKey{}).(string); ok {
+		return id
+	}
+	if t, ok := srv.tenantByKey(r.Header.Get("X-API-Key")); ok {
+		return t.ID
+	}
+	return ""
+}
+
+// 网站是否出现在公开的网站列表中：租户的网站只对该租户和管理员可见
+func (srv *Server) siteVisible(r *http.Request, site string) bool {
+	id, _, ok := splitTenantSite(site)
+	return !ok || srv.requestTenant(r) == id || srv.adminAuthorized(r)
+}
+
+// /u/<key>/ 中的密钥
+func tenantKeyFromPath(path string) string {
+	rest, ok := strings.CutPrefix(path, "/u/")
+	if !ok {
+		return ""
+	}
+	key, _, _ := strings.Cut(rest, "/")
+	return key
+}
+
+// 访问日志中隐藏 /u/<key>/ 中的密钥
+func redactTenantPath(path string) string {
+	rest, ok := strings.CutPrefix(path, "/u/")
+	if !ok {
+		return path
+	}
+	if _, after, found := strings.Cut(rest, "/"); found {
+		return "/u/***/" + after
+	}
+	return "/u/***"
+}
+
+// /u/<key>/...：租户的订阅源（rss、rss/<file>、feed/<file>）和网站管理（sites）
+func (srv *Server) tenantHandler(w http.ResponseWriter, r *http.Request) {
+	if !srv.tenantsEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	t, ok := srv.tenantByKey(r.PathValue("key"))
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "Unknown API key")
+		return
+	}
+
+	r2 := r.Clone(context.WithValue(r.Context(), tenantKey{}, t.ID))
+	path := r.PathValue("path")
+	switch {
+	case path == "sites":
+		srv.tenantSitesHandler(w, r2, t)
+	case path == "rss":
+		// 网站名加上租户前缀，租户只能访问自己的网站
+		params := r2.URL.Query()
+		if site := params.Get("site"); site != "" {
+			params.Set("site", tenantSiteName(t.ID, site))
+		}
+		if sites := params.Get("sites"); sites != "" {
+			var names []string
+			for _, site := range splitList(sites) {
+				names = append(names, tenantSiteName(t.ID, site))
+			}
+			params.Set("sites", strings.Join(names, ","))
+		}
+		r2.URL.RawQuery = params.Encode()
+		srv.generateRSSHandler(w, r2)
+	case strings.HasPrefix(path, "rss/") || strings.HasPrefix(path, "feed/"):
+		_, file, _ := strings.Cut(path, "/")
+		r2.SetPathValue("file", tenantSiteName(t.ID, file))
+		srv.feedPathHandler(w, r2)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// 租户网站的配置和订阅地址
+type tenantSiteInfo struct {
+	Site   string            `json:"site"`
+	Config SiteConfig        `json:"config"`
+	Feeds  map[string]string `json:"feeds"`
+}
+
+// /u/<key>/sites：GET 列出租户的网站；PUT ?site=x 创建或替换网站配置；DELETE ?site=x 删除网站及其历史条目
+func (srv *Server) tenantSitesHandler(w http.ResponseWriter, r *http.Request, t tenant) {
+	site := r.URL.Query().Get("site")
+	base := srv.requestBaseURL(r) + "/u/" + url.PathEscape(t.Key)
+
+	switch r.Method {
+	case http.MethodGet:
+		resp := make([]tenantSiteInfo, 0, len(t.Sites))
+		for name, sc := range t.Sites {
+			resp = append(resp, tenantSiteInfo{Site: name, Config: sc, Feeds: siteFeedURLs(base, name)})
+		}
+		sort.Slice(resp, func(i, j int) bool { return resp[i].Site < resp[j].Site })
+		writeJSON(w, http.StatusOK, resp)
+
+	case http.MethodPut:
+		if !tenantNameRe.MatchString(site) {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Invalid 'site' parameter, use letters, digits, - and _")
+			return
+		}
+		var sc SiteConfig
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&sc); err != nil {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("Invalid site config: %v", err))
+			return
+		}
+		sc, err := validateTenantSite(site, sc)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+
+		srv.tenantsMu.Lock()
+		cur := srv.tenants[t.ID]
+		_, existed := cur.Sites[site]
+		if !existed && srv.cfg.TenantMaxSites > 0 && len(cur.Sites) >= srv.cfg.TenantMaxSites {
+			srv.tenantsMu.Unlock()
+			writeError(w, r, http.StatusForbidden, ErrCodeForbidden, fmt.Sprintf("Site limit reached (%d)", srv.cfg.TenantMaxSites))
+			return
+		}
+		sites := make(map[string]SiteConfig, len(cur.Sites)+1)
+		for name, c := range cur.Sites {
+			sites[name] = c
+		}
+		sites[site] = sc
+		err = srv.updateTenantSites(cur, sites)
+		srv.tenantsMu.Unlock()
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("Failed to save tenants: %v", err))
+			return
+		}
+		srv.applyTenantSites()
+		srv.initCache()
+
+		slog.Info("Saved tenant site", "tenant", t.ID, "site", site)
+		status := http.StatusOK
+		if !existed {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, tenantSiteInfo{Site: site, Config: sc, Feeds: siteFeedURLs(base, site)})
+
+	case http.MethodDelete:
+		srv.tenantsMu.Lock()
+		cur := srv.tenants[t.ID]
+		if _, ok := cur.Sites[site]; !ok {
+			srv.tenantsMu.Unlock()
+			writeError(w, r, http.StatusNotFound, ErrCodeSiteNotFound, fmt.Sprintf("Unknown site: %s", site))
+			return
+		}
+		sites := make(map[string]SiteConfig, len(cur.Sites))
+		for name, c := range cur.Sites {
+			if name != site {
+				sites[name] = c
+			}
+		}
+		err := srv.updateTenantSites(cur, sites)
+		srv.tenantsMu.Unlock()
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("Failed to save tenants: %v", err))
+			return
+		}
+		srv.applyTenantSites()
+		srv.deleteTenantSiteData(tenantSiteName(t.ID, site))
+		slog.Info("Deleted tenant site", "tenant", t.ID, "site", site)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// 替换租户的网站并写入文件，失败时恢复。调用方持有 tenantsMu
+func (srv *Server) updateTenantSites(t tenant, sites map[string]SiteConfig) error {
+	prev := srv.tenants[t.ID]
+	t.Sites = sites
+	srv.tenants[t.ID] = t
+	if err := srv.saveTenants(srv.cfg.TenantsFile); err != nil {
+		srv.tenants[t.ID] = prev
+		return err
+	}
+	return nil
+}
+
+// 租户网站的抓取限制：缓存有效期（也是定时刷新的间隔）和各项条目数、页数的上限
+const (
+	tenantMinCacheTTL       = 5 * time.Minute
+	tenantMaxCacheTTL       = 24 * time.Hour
+	tenantMaxItems          = 200
+	tenantMaxFeedItems      = 100
+	tenantMaxRetentionItems = 1000
+	tenantMaxPages          = 5
+	tenantMaxDetailItems    = 50
+)
+
+// 检查租户的网站配置，返回限制在服务端范围内的配置。租户的网站只能抓取 http/https 地址，
+// 不能使用影响运营方的字段：关键网站、通知、稍后读、上游订阅，以及消耗 LLM 和翻译额度的功能
+func validateTenantSite(site string, sc SiteConfig) (SiteConfig, error) {
+	if err := validateSiteConfig(site, sc); err != nil {
+		return sc, err
+	}
+	if !isHTTPURL(sc.URL) {
+		return sc, fmt.Errorf("site %s: URL must be an http or https URL", site)
+	}
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"Critical", sc.Critical},
+		{"Webhooks", len(sc.Webhooks) > 0},
+		{"Slack", len(sc.Slack) > 0},
+		{"Discord", len(sc.Discord) > 0},
+		{"ReadLater", len(sc.ReadLater) > 0},
+		{"Priority", sc.Priority != 0},
+		{"Translate", len(sc.Translate) > 0},
+		{"UpstreamFeed", sc.UpstreamFeed != ""},
+	} {
+		if f.set {
+			return sc, fmt.Errorf("site %s: %s is not allowed for tenant sites", site, f.name)
+		}
+	}
+
+	steps := make([]PipelineStep, len(sc.Pipeline))
+	for i, step := range sc.Pipeline {
+		switch step.Type {
+		case StepSummarize:
+			return sc, fmt.Errorf("site %s: summarize steps are not allowed for tenant sites", site)
+		case StepFetch:
+			if step.URL != "" && !isHTTPURL(step.URL) {
+				return sc, fmt.Errorf("site %s: step %d: URL must be an http or https URL", site, i+1)
+			}
+		case StepPaginate:
+			if step.MaxPages <= 0 || step.MaxPages > tenantMaxPages {
+				step.MaxPages = tenantMaxPages
+			}
+		case StepDetail:
+			if step.MaxItems <= 0 || step.MaxItems > tenantMaxDetailItems {
+				step.MaxItems = tenantMaxDetailItems
+			}
+		}
+		steps[i] = step
+	}
+	if sc.Pipeline != nil {
+		sc.Pipeline = steps
+	}
+
+	sc.CacheTTL = Duration(min(max(siteCacheTTL(sc), tenantMinCacheTTL), tenantMaxCacheTTL))
+	if sc.MaxItems <= 0 || sc.MaxItems > tenantMaxItems {
+		sc.MaxItems = tenantMaxItems
+	}
+	sc.FeedItems = min(sc.FeedItems, tenantMaxFeedItems)
+	if sc.RetentionItems <= 0 || sc.RetentionItems > tenantMaxRetentionItems {
+		sc.RetentionItems = tenantMaxRetentionItems
+	}
+	return sc, nil
+}
+
+// 删除网站的缓存和历史条目
+func (srv *Server) deleteTenantSiteData(name string) {
+	if err := srv.store.Delete(name, true); err != nil {
+		slog.Error("Failed to delete site data", "site", name, "error", err)
+	}
+}
+
+// /admin/tenants：GET 列出租户；POST ?id=x 创建租户并返回密钥；DELETE ?id=x 删除租户及其所有网站
+func (srv *Server) tenantsAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if !srv.tenantsEnabled() {
+		writeError(w, r, http.StatusConflict, ErrCodeConflict, "Multi-tenant mode is disabled, start the server with -tenants-file")
+		return
+	}
+	id := r.URL.Query().Get("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		srv.tenantsMu.RLock()
+		list := srv.sortedTenants()
+		srv.tenantsMu.RUnlock()
+		writeJSON(w, http.StatusOK, list)
+
+	case http.MethodPost:
+		if !tenantNameRe.MatchString(id) {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Invalid 'id' parameter, use letters, digits, - and _")
+			return
+		}
+		t := tenant{ID: id, Key: randomID() + randomID(), Sites: map[string]SiteConfig{}, CreatedAt: time.Now()}
+		srv.tenantsMu.Lock()
+		if _, ok := srv.tenants[id]; ok {
+			srv.tenantsMu.Unlock()
+			writeError(w, r, http.StatusConflict, ErrCodeConflict, fmt.Sprintf("Tenant already exists: %s", id))
+			return
+		}
+		srv.tenants[id] = t
+		err := srv.saveTenants(srv.cfg.TenantsFile)
+		if err != nil {
+			delete(srv.tenants, id)
+		}
+		srv.tenantsMu.Unlock()
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("Failed to save tenants: %v", err))
+			return
+		}
+		slog.Info("Created tenant", "tenant", id)
+		writeJSON(w, http.StatusCreated, t)
+
+	case http.MethodDelete:
+		srv.tenantsMu.Lock()
+		t, ok := srv.tenants[id]
+		var err error
+		if ok {
+			delete(srv.tenants, id)
+			if err = srv.saveTenants(srv.cfg.TenantsFile); err != nil {
+				srv.tenants[id] = t
+			}
+		}
+		srv.tenantsMu.Unlock()
+		if !ok {
+			writeError(w, r, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("Unknown tenant: %s", id))
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("Failed to save tenants: %v", err))
+			return
+		}
+		srv.applyTenantSites()
+		for site := range t.Sites {
+			srv.deleteTenantSiteData(tenantSiteName(id, site))
+		}
+		slog.Info("Deleted tenant", "tenant", id, "sites", len(t.Sites))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+	}
+}
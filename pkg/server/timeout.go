@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// 长连接推送不限制处理时间
+var untimedRoutes = map[string]bool{"/events": true, "/ws": true}
+
+// 记录处理函数是否已经写出响应
+type timeoutWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.wrote = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+// 供 http.ResponseController 访问底层的 ResponseWriter
+func (w *timeoutWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// 给请求的 context 加上 -request-timeout 的截止时间。
+// 处理函数超时返回且还没有写出响应时返回 504
+func (srv *Server) timeoutHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if srv.cfg.RequestTimeout <= 0 || untimedRoutes[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), srv.cfg.RequestTimeout)
+		defer cancel()
+		tw := &timeoutWriter{ResponseWriter: w}
+		next.ServeHTTP(tw, r.WithContext(ctx))
+		if !tw.wrote && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			srv.writeTimeout(w, r)
+		}
+	})
+}
+
+func (srv *Server) writeTimeout(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusGatewayTimeout, ErrCodeRequestTimeout, fmt.Sprintf("Request timed out after %s", srv.cfg.RequestTimeout))
+}
+
+// 请求触发的同步抓取使用的 context：抓取结果会被同时等待的其他请求共享，
+// 所以不随客户端断开而取消，但保留请求的 span 和截止时间
+func (srv *Server) fetchContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	base := withSpan(srv.appCtx, ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(base, deadline)
+	}
+	return context.WithCancel(base)
+}
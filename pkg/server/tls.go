@@ -0,0 +1,41 @@
+package server
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"net/http"
+)
+
+func (srv *Server) tlsEnabled() bool {
+	return srv.cfg.TLSCertFile != "" && srv.cfg.TLSKeyFile != ""
+}
+
+// HTTPS 服务的 TLS 配置
+func newTLSConfig() *tls.Config {
+	return &tls.Config{MinVersion: tls.VersionTLS12}
+}
+
+// 在 addr 上启动 HTTP 服务，把所有请求 301 重定向到 httpsPort 端口的 HTTPS
+func (srv *Server) startHTTPSRedirect(addr, httpsPort string) *http.Server {
+	hs := srv.tuneServer(&http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = r.Host
+			}
+			if httpsPort != "443" {
+				host = net.JoinHostPort(host, httpsPort)
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+		}),
+	}, false)
+	go func() {
+		slog.Info("Redirecting HTTP to HTTPS", "addr", addr)
+		if err := hs.ListenAndServe(); err != http.ErrServerClosed {
+			fatal("HTTPS redirect server failed", "error", err)
+		}
+	}()
+	return hs
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 订阅源访问令牌。令牌可以直接放在订阅地址中（?token=），
+// 也可以用来给订阅地址签名（?key=<ID>&expires=&sig=），吊销令牌后签名地址同时失效
+type feedToken struct {
+	ID        string    `json:"id"`
+	Site      string    `json:"site"` // * 表示所有网站
+	Note      string    `json:"note,omitempty"`
+	Token     string    `json:"token"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (t feedToken) allows(site string) bool {
+	return t.Site == "*" || t.Site == site
+}
+
+// 读取令牌文件，文件不存在时没有令牌
+func (srv *Server) loadFeedTokens(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var tokens []feedToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return err
+	}
+	srv.feedTokensMu.Lock()
+	defer srv.feedTokensMu.Unlock()
+	for _, t := range tokens {
+		srv.feedTokens[t.ID] = t
+	}
+	return nil
+}
+
+// 写入令牌文件，调用方持有 feedTokensMu
+func (srv *Server) saveFeedTokens(path string) error {
+	data, err := json.MarshalIndent(srv.sortedFeedTokens(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (srv *Server) sortedFeedTokens() []feedToken {
+	tokens := make([]feedToken, 0, len(srv.feedTokens))
+	for _, t := range srv.feedTokens {
+		tokens = append(tokens, t)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens
+}
+
+// 地址签名：HMAC-SHA256(令牌, site + "\n" + expires)
+func signFeed(token, site string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	fmt.Fprintf(mac, "%s\n%d", site, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 请求是否带有可以访问该网站的令牌或有效的签名
+func (srv *Server) validFeedToken(r *http.Request, site string) bool {
+	params := r.URL.Query()
+	srv.feedTokensMu.RLock()
+	defer srv.feedTokensMu.RUnlock()
+
+	if token := params.Get("token"); token != "" {
+		for _, t := range srv.feedTokens {
+			if t.allows(site) && subtle.ConstantTimeCompare([]byte(token), []byte(t.Token)) == 1 {
+				return true
+			}
+		}
+		return false
+	}
+
+	t, ok := srv.feedTokens[params.Get("key")]
+	if !ok || !t.allows(site) {
+		return false
+	}
+	expires, err := strconv.ParseInt(params.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(params.Get("sig")), []byte(signFeed(t.Token, site, expires)))
+}
+
+// 按令牌原文查找令牌，返回令牌 ID，不存在时返回空字符串
+func (srv *Server) knownFeedToken(token string) string {
+	if token == "" {
+		return ""
+	}
+	srv.feedTokensMu.RLock()
+	defer srv.feedTokensMu.RUnlock()
+	for _, t := range srv.feedTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t.Token)) == 1 {
+			return t.ID
+		}
+	}
+	return ""
+}
+
+// 网站是否需要令牌才能访问
+func (srv *Server) siteRequiresToken(site string) bool {
+	config, _ := srv.getSiteConfig(site)
+	return srv.cfg.RequireFeedToken || config.RequireToken
+}
+
+// /admin/tokens：GET 列出令牌；POST 为 site 创建令牌（note 为备注），
+// 带 id 时改为用该令牌生成有效期为 ttl（默认 24h）的签名地址；DELETE 按 id 吊销令牌
+func (srv *Server) tokensHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	switch r.Method {
+	case http.MethodGet:
+		srv.feedTokensMu.RLock()
+		tokens := srv.sortedFeedTokens()
+		srv.feedTokensMu.RUnlock()
+		writeJSON(w, http.StatusOK, tokens)
+
+	case http.MethodPost:
+		if id := params.Get("id"); id != "" {
+			srv.signedURLHandler(w, r, id)
+			return
+		}
+		site := params.Get("site")
+		if site == "" {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Missing 'site' parameter, use * for all sites")
+			return
+		}
+		if _, ok := srv.getSiteConfig(site); !ok && site != "*" {
+			writeError(w, r, http.StatusNotFound, ErrCodeSiteNotFound, fmt.Sprintf("Unknown site: %s", site))
+			return
+		}
+
+		t := feedToken{ID: randomID(), Site: site, Note: params.Get("note"), Token: randomID() + randomID(), CreatedAt: time.Now()}
+		srv.feedTokensMu.Lock()
+		srv.feedTokens[t.ID] = t
+		err := srv.saveFeedTokens(srv.cfg.TokenFile)
+		if err != nil {
+			delete(srv.feedTokens, t.ID)
+		}
+		srv.feedTokensMu.Unlock()
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("Failed to save tokens: %v", err))
+			return
+		}
+		slog.Info("Created feed token", "id", t.ID, "site", site)
+		writeJSON(w, http.StatusCreated, t)
+
+	case http.MethodDelete:
+		id := params.Get("id")
+		srv.feedTokensMu.Lock()
+		t, ok := srv.feedTokens[id]
+		var err error
+		if ok {
+			delete(srv.feedTokens, id)
+			if err = srv.saveFeedTokens(srv.cfg.TokenFile); err != nil {
+				srv.feedTokens[id] = t
+			}
+		}
+		srv.feedTokensMu.Unlock()
+		if !ok {
+			writeError(w, r, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("Unknown token: %s", id))
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("Failed to save tokens: %v", err))
+			return
+		}
+		slog.Info("Revoked feed token", "id", id, "site", t.Site)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// 用令牌为网站生成签名地址
+func (srv *Server) signedURLHandler(w http.ResponseWriter, r *http.Request, id string) {
+	params := r.URL.Query()
+	srv.feedTokensMu.RLock()
+	t, ok := srv.feedTokens[id]
+	srv.feedTokensMu.RUnlock()
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("Unknown token: %s", id))
+		return
+	}
+
+	site := params.Get("site")
+	if site == "" && t.Site != "*" {
+		site = t.Site
+	}
+	if site == "" || !t.allows(site) {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Token does not allow this site")
+		return
+	}
+	ttl := 24 * time.Hour
+	if v := params.Get("ttl"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Invalid 'ttl' parameter")
+			return
+		}
+		ttl = d
+	}
+
+	expires := time.Now().Add(ttl).Unix()
+	q := url.Values{}
+	q.Set("site", site)
+	q.Set("key", t.ID)
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("sig", signFeed(t.Token, site, expires))
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"url":     srv.requestBaseURL(r) + "/rss?" + q.Encode(),
+		"expires": time.Unix(expires, 0).UTC(),
+	})
+}
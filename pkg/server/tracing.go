@@ -0,0 +1,299 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rss-zhuaqu/pkg/hooks"
+)
+
+// OpenTelemetry 链路追踪：请求处理和抓取各阶段记录为 span，
+// 以 OTLP/HTTP JSON 格式批量发送到 -otlp-endpoint，上下文按 W3C traceparent 传播。
+// -otlp-endpoint 为空时不记录
+
+// span 的类型，取值同 OTLP
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+	spanKindClient   = 3
+)
+
+const (
+	traceBatchSize     = 512
+	traceFlushInterval = 5 * time.Second
+	traceQueueSize     = 4096
+)
+
+type span struct {
+	srv      *Server
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+
+	mu    sync.Mutex
+	attrs map[string]string
+	err   string
+}
+
+type spanKey struct{}
+
+func spanFromContext(ctx context.Context) *span {
+	s, _ := ctx.Value(spanKey{}).(*span)
+	return s
+}
+
+// 开始一个 span，父 span 从 ctx 中获取。未开启追踪时返回 nil，nil span 的方法都是空操作
+func (srv *Server) startSpan(ctx context.Context, name string, kind int) (context.Context, *span) {
+	if srv.cfg.OTLPEndpoint == "" {
+		return ctx, nil
+	}
+	s := &span{srv: srv, name: name, kind: kind, start: time.Now()}
+	if parent := spanFromContext(ctx); parent != nil {
+		s.traceID, s.parentID = parent.traceID, parent.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// 以请求头中的 traceparent 为父 span 开始服务端 span
+func (srv *Server) startServerSpan(r *http.Request, name string) (context.Context, *span) {
+	ctx := r.Context()
+	if srv.cfg.OTLPEndpoint == "" {
+		return ctx, nil
+	}
+	if parent, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+		ctx = context.WithValue(ctx, spanKey{}, parent)
+	}
+	return srv.startSpan(ctx, name, spanKindServer)
+}
+
+// 把 ctx 中的 span 带到 base 中，用于在后台 context 里延续请求的链路
+func withSpan(base, ctx context.Context) context.Context {
+	if s := spanFromContext(ctx); s != nil {
+		return context.WithValue(base, spanKey{}, s)
+	}
+	return base
+}
+
+func (s *span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.attrs == nil {
+		s.attrs = make(map[string]string)
+	}
+	s.attrs[key] = fmt.Sprint(value)
+	s.mu.Unlock()
+}
+
+func (s *span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err.Error()
+	s.mu.Unlock()
+}
+
+func (s *span) End() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	select {
+	case s.srv.traceQueue <- s:
+	default:
+		// 队列已满时丢弃，不阻塞请求
+	}
+}
+
+// W3C traceparent：00-<trace id>-<span id>-<flags>
+func (s *span) traceparent() string {
+	return "00-" + hex.EncodeToString(s.traceID[:]) + "-" + hex.EncodeToString(s.spanID[:]) + "-01"
+}
+
+// 在发出的请求中加入 traceparent
+func injectTraceparent(ctx context.Context, req *http.Request) {
+	if s := spanFromContext(ctx); s != nil {
+		req.Header.Set("traceparent", s.traceparent())
+	}
+}
+
+// 抓取中间件：在发出的请求中加入请求 context 中 span 的 traceparent
+func traceFetchMiddleware(next http.RoundTripper) http.RoundTripper {
+	return hooks.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if spanFromContext(req.Context()) != nil {
+			req = req.Clone(req.Context())
+			injectTraceparent(req.Context(), req)
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+func parseTraceparent(h string) (*span, bool) {
+	parts := strings.Split(h, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return nil, false
+	}
+	s := &span{}
+	if _, err := hex.Decode(s.traceID[:], []byte(parts[1])); err != nil {
+		return nil, false
+	}
+	if _, err := hex.Decode(s.spanID[:], []byte(parts[2])); err != nil {
+		return nil, false
+	}
+	return s, s.traceID != [16]byte{} && s.spanID != [8]byte{}
+}
+
+// 后台批量发送 span，满 traceBatchSize 个或每 traceFlushInterval 发送一次
+func (srv *Server) startTraceExporter() {
+	go func() {
+		ticker := time.NewTicker(traceFlushInterval)
+		defer ticker.Stop()
+
+		var batch []*span
+		for {
+			select {
+			case s := <-srv.traceQueue:
+				batch = append(batch, s)
+				if len(batch) < traceBatchSize {
+					continue
+				}
+			case <-ticker.C:
+			case done := <-srv.traceFlushes:
+				for len(srv.traceQueue) > 0 {
+					batch = append(batch, <-srv.traceQueue)
+				}
+				srv.exportSpans(batch)
+				batch = nil
+				close(done)
+				continue
+			}
+			srv.exportSpans(batch)
+			batch = nil
+		}
+	}()
+}
+
+// 发送队列中剩余的 span，退出前调用
+func (srv *Server) flushTraces() {
+	if srv.cfg.OTLPEndpoint == "" {
+		return
+	}
+	done := make(chan struct{})
+	srv.traceFlushes <- done
+	<-done
+}
+
+// OTLP JSON 中的 KeyValue
+type otlpAttr struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []otlpAttr `json:"attributes,omitempty"`
+	Status            struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+func newOTLPAttrs(m map[string]string) []otlpAttr {
+	attrs := make([]otlpAttr, 0, len(m))
+	for k, v := range m {
+		a := otlpAttr{Key: k}
+		a.Value.StringValue = v
+		attrs = append(attrs, a)
+	}
+	return attrs
+}
+
+func (srv *Server) exportSpans(batch []*span) {
+	if len(batch) == 0 {
+		return
+	}
+
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		o := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parentID != [8]byte{} {
+			o.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		s.mu.Lock()
+		o.Attributes = newOTLPAttrs(s.attrs)
+		if s.err != "" {
+			o.Status.Code, o.Status.Message = 2, s.err
+		}
+		s.mu.Unlock()
+		spans = append(spans, o)
+	}
+
+	body := map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": newOTLPAttrs(map[string]string{"service.name": srv.serviceName, "service.instance.id": srv.instanceID}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "rss-zhuaqu"},
+				"spans": spans,
+			}},
+		}},
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		slog.Error("Failed to encode spans", "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(srv.cfg.OTLPEndpoint, "/")+"/v1/traces", bytes.NewReader(data))
+	if err != nil {
+		slog.Warn("Failed to export spans", "spans", len(batch), "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		slog.Warn("Failed to export spans", "spans", len(batch), "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		slog.Warn("Failed to export spans", "spans", len(batch), "status", resp.Status)
+	}
+}
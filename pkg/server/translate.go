@@ -0,0 +1,304 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 机器翻译：lang 参数返回标题和摘要翻译为指定语言的订阅源，如 /rss?site=abc&lang=en。
+// 网站在 Translate 中列出允许的语言，译文按条目缓存，同一条目只翻译一次
+
+const (
+	TranslateDeepL          = "deepl"
+	TranslateGoogle         = "google"
+	TranslateLibreTranslate = "libretranslate"
+)
+
+// 缓存的译文条数，超过时淘汰最早翻译的
+const translationCacheLimit = 10000
+
+// 翻译服务，html 为 true 时保留文本中的 HTML 标签
+type translator interface {
+	Translate(ctx context.Context, texts []string, target string, html bool) ([]string, error)
+}
+
+// 根据参数创建翻译服务，启动时调用
+func (srv *Server) initTranslator() error {
+	switch srv.cfg.TranslateBackend {
+	case "":
+		return nil
+	case TranslateDeepL:
+		base := srv.cfg.TranslateURL
+		if base == "" {
+			// 付费账号为 https://api.deepl.com
+			base = "https://api-free.deepl.com"
+		}
+		srv.activeTranslator = &deeplTranslator{base: base, key: srv.cfg.TranslateKey}
+	case TranslateGoogle:
+		base := srv.cfg.TranslateURL
+		if base == "" {
+			base = "https://translation.googleapis.com"
+		}
+		srv.activeTranslator = &googleTranslator{base: base, key: srv.cfg.TranslateKey}
+	case TranslateLibreTranslate:
+		if srv.cfg.TranslateURL == "" {
+			return fmt.Errorf("-translate-url is required for libretranslate")
+		}
+		srv.activeTranslator = &libreTranslator{base: srv.cfg.TranslateURL, key: srv.cfg.TranslateKey}
+	default:
+		return fmt.Errorf("unknown -translate-backend %q, expected deepl, google or libretranslate", srv.cfg.TranslateBackend)
+	}
+	if srv.cfg.TranslateKey == "" && srv.cfg.TranslateBackend != TranslateLibreTranslate {
+		return fmt.Errorf("-translate-key is required for %s", srv.cfg.TranslateBackend)
+	}
+	return nil
+}
+
+// 语言代码，如 en、zh-CN、pt-BR
+var langPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,4})?$`)
+
+// 检查 lang 参数：翻译服务已配置，且每个网站都允许该语言
+func (srv *Server) checkTranslation(sites []string, lang string) error {
+	if srv.activeTranslator == nil {
+		return fmt.Errorf("translation is not enabled")
+	}
+	if !langPattern.MatchString(lang) {
+		return fmt.Errorf("invalid 'lang' parameter %q", lang)
+	}
+	for _, site := range sites {
+		config, _ := srv.getSiteConfig(site)
+		if !containsFold(config.Translate, lang) {
+			return fmt.Errorf("translation to %q is not enabled for site %s", lang, site)
+		}
+	}
+	return nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// 缓存的译文，Source 为原文的哈希，原文变化后重新翻译
+type translationEntry struct {
+	Source      string
+	Title       string
+	Description string
+	CreatedAt   time.Time
+}
+
+func translationKey(guid, lang string) string {
+	return strings.ToLower(lang) + "\x00" + guid
+}
+
+func translationSource(item Item) string {
+	sum := sha256.Sum256([]byte(item.Title + "\x00" + item.Description))
+	return hex.EncodeToString(sum[:8])
+}
+
+// 翻译条目的标题和摘要。只请求缓存中没有的条目，翻译失败时保留原文
+func (srv *Server) translateItems(ctx context.Context, items []Item, lang string) []Item {
+	out := make([]Item, len(items))
+	copy(out, items)
+
+	var pending []int
+	srv.translationMu.Lock()
+	for i, item := range out {
+		e, ok := srv.translationCache[translationKey(item.GUID, lang)]
+		if ok && e.Source == translationSource(item) {
+			out[i].Title, out[i].Description = e.Title, e.Description
+			continue
+		}
+		pending = append(pending, i)
+	}
+	srv.translationMu.Unlock()
+	if len(pending) == 0 {
+		return out
+	}
+
+	// 标题按纯文本、摘要按 HTML 翻译，空字段不发送
+	var titles, descs []string
+	for _, i := range pending {
+		titles = append(titles, out[i].Title)
+		if out[i].Description != "" {
+			descs = append(descs, out[i].Description)
+		}
+	}
+	ctx, sp := srv.startSpan(ctx, "translate", spanKindClient)
+	defer sp.End()
+	sp.SetAttr("lang", lang)
+	sp.SetAttr("items", len(pending))
+	tTitles, err := srv.activeTranslator.Translate(ctx, titles, lang, false)
+	var tDescs []string
+	if err == nil && len(descs) > 0 {
+		tDescs, err = srv.activeTranslator.Translate(ctx, descs, lang, true)
+	}
+	if err == nil && (len(tTitles) != len(titles) || len(tDescs) != len(descs)) {
+		err = fmt.Errorf("translation returned %d titles and %d descriptions, expected %d and %d", len(tTitles), len(tDescs), len(titles), len(descs))
+	}
+	if err != nil {
+		sp.SetError(err)
+		slog.Warn("Failed to translate items", "backend", srv.cfg.TranslateBackend, "lang", lang, "items", len(pending), "error", err)
+		return out
+	}
+
+	srv.translationMu.Lock()
+	defer srv.translationMu.Unlock()
+	for n, i := range pending {
+		source := translationSource(out[i])
+		out[i].Title = tTitles[n]
+		if out[i].Description != "" {
+			out[i].Description, tDescs = tDescs[0], tDescs[1:]
+		}
+		srv.translationCache[translationKey(out[i].GUID, lang)] = translationEntry{
+			Source:      source,
+			Title:       out[i].Title,
+			Description: out[i].Description,
+			CreatedAt:   time.Now(),
+		}
+	}
+	for len(srv.translationCache) > translationCacheLimit {
+		var oldest string
+		var oldestAt time.Time
+		for k, e := range srv.translationCache {
+			if oldest == "" || e.CreatedAt.Before(oldestAt) {
+				oldest, oldestAt = k, e.CreatedAt
+			}
+		}
+		delete(srv.translationCache, oldest)
+	}
+	return out
+}
+
+// 检查点中保存的译文缓存
+func (srv *Server) translationEntries() map[string]translationEntry {
+	srv.translationMu.Lock()
+	defer srv.translationMu.Unlock()
+	if len(srv.translationCache) == 0 {
+		return nil
+	}
+	entries := make(map[string]translationEntry, len(srv.translationCache))
+	for k, e := range srv.translationCache {
+		entries[k] = e
+	}
+	return entries
+}
+
+func (srv *Server) restoreTranslations(entries map[string]translationEntry) {
+	srv.translationMu.Lock()
+	defer srv.translationMu.Unlock()
+	for k, e := range entries {
+		srv.translationCache[k] = e
+	}
+}
+
+// 发送 JSON 请求并解析 JSON 响应
+func translateRequest(ctx context.Context, target string, header http.Header, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return readerRequest(req, out)
+}
+
+// DeepL API v2
+type deeplTranslator struct {
+	base string
+	key  string
+}
+
+func (t *deeplTranslator) Translate(ctx context.Context, texts []string, target string, html bool) ([]string, error) {
+	body := map[string]interface{}{"text": texts, "target_lang": strings.ToUpper(target)}
+	if html {
+		body["tag_handling"] = "html"
+	}
+	var resp struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	header := http.Header{"Authorization": {"DeepL-Auth-Key " + t.key}}
+	if err := translateRequest(ctx, strings.TrimSuffix(t.base, "/")+"/v2/translate", header, body, &resp); err != nil {
+		return nil, err
+	}
+	out := make([]string, len(resp.Translations))
+	for i, tr := range resp.Translations {
+		out[i] = tr.Text
+	}
+	return out, nil
+}
+
+// Google Cloud Translation API v2，使用 API 密钥
+type googleTranslator struct {
+	base string
+	key  string
+}
+
+func (t *googleTranslator) Translate(ctx context.Context, texts []string, target string, html bool) ([]string, error) {
+	format := "text"
+	if html {
+		format = "html"
+	}
+	body := map[string]interface{}{"q": texts, "target": target, "format": format}
+	var resp struct {
+		Data struct {
+			Translations []struct {
+				TranslatedText string `json:"translatedText"`
+			} `json:"translations"`
+		} `json:"data"`
+	}
+	header := http.Header{"X-Goog-Api-Key": {t.key}}
+	if err := translateRequest(ctx, strings.TrimSuffix(t.base, "/")+"/language/translate/v2", header, body, &resp); err != nil {
+		return nil, err
+	}
+	out := make([]string, len(resp.Data.Translations))
+	for i, tr := range resp.Data.Translations {
+		out[i] = tr.TranslatedText
+	}
+	return out, nil
+}
+
+// LibreTranslate，可以自建
+type libreTranslator struct {
+	base string
+	key  string
+}
+
+func (t *libreTranslator) Translate(ctx context.Context, texts []string, target string, html bool) ([]string, error) {
+	format := "text"
+	if html {
+		format = "html"
+	}
+	body := map[string]interface{}{"q": texts, "source": "auto", "target": target, "format": format}
+	if t.key != "" {
+		body["api_key"] = t.key
+	}
+	var resp struct {
+		TranslatedText []string `json:"translatedText"`
+	}
+	if err := translateRequest(ctx, strings.TrimSuffix(t.base, "/")+"/translate", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.TranslatedText, nil
+}
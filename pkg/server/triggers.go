@@ -0,0 +1,427 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 无代码自动化平台的触发器：Zapier 的轮询接口（按 id 去重的 JSON 数组）和 REST hooks 订阅，
+// 以及 IFTTT 服务协议的触发器接口
+
+// 轮询接口的默认条数和最大条数
+const (
+	defaultTriggerLimit = 50
+	maxTriggerLimit     = 200
+)
+
+// 触发器返回的条目，id 由网站名和 GUID 生成，不随刷新变化
+type triggerItem struct {
+	ID          string    `json:"id"`
+	Site        string    `json:"site"`
+	SiteName    string    `json:"site_name"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	Image       string    `json:"image,omitempty"`
+	Published   string    `json:"published,omitempty"`
+	Created     time.Time `json:"created"`
+}
+
+func newTriggerItem(site string, config SiteConfig, item Item, seen time.Time) triggerItem {
+	sum := sha256.Sum256([]byte(site + "\x00" + item.GUID))
+	name := config.Name
+	if name == "" {
+		name = site
+	}
+	ti := triggerItem{
+		ID:          hex.EncodeToString(sum[:12]),
+		Site:        site,
+		SiteName:    name,
+		Title:       item.Title,
+		URL:         item.Link,
+		Description: item.Description,
+		Published:   item.PubDate,
+		Created:     seen.UTC(),
+	}
+	if item.Thumbnail != nil {
+		ti.Image = item.Thumbnail.URL
+	}
+	return ti
+}
+
+// 触发器包括的网站：指定 site 时为该网站，否则为带 tag 标签（为空时不限）的网站；
+// 需要认证的网站只在使用管理令牌时包括
+func (srv *Server) triggerSites(r *http.Request, site, tag string) []string {
+	if site != "" {
+		return []string{site}
+	}
+	var sites []string
+	for name, config := range srv.getAllSiteConfig() {
+		if tag != "" && !slices.Contains(config.Tags, tag) {
+			continue
+		}
+		if !srv.siteVisible(r, name) || (srv.feedProtected(name) && !srv.adminAuthorized(r)) {
+			continue
+		}
+		sites = append(sites, name)
+	}
+	sort.Strings(sites)
+	return sites
+}
+
+// 各网站最近的条目，按首次出现时间从新到旧
+func (srv *Server) recentTriggerItems(sites []string, limit int) ([]triggerItem, error) {
+	type rec struct {
+		site string
+		storedItem
+	}
+	var recs []rec
+	for _, site := range sites {
+		stored, err := srv.store.QueryItems(site, ItemQuery{Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range stored {
+			recs = append(recs, rec{site, s})
+		}
+	}
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].FirstSeen > recs[j].FirstSeen })
+	if len(recs) > limit {
+		recs = recs[:limit]
+	}
+	items := make([]triggerItem, 0, len(recs))
+	for _, r := range recs {
+		config, _ := srv.getSiteConfig(r.site)
+		items = append(items, newTriggerItem(r.site, config, r.Item, time.Unix(r.FirstSeen, 0)))
+	}
+	return items, nil
+}
+
+// GET /api/triggers/items?site=x|tag=x&limit=50：Zapier 等轮询的最近条目，
+// 直接返回数组，平台按 id 去重
+func (srv *Server) triggerItemsHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	site := params.Get("site")
+	if site != "" {
+		if _, ok := srv.getSiteConfig(site); !ok {
+			writeError(w, r, http.StatusNotFound, ErrCodeSiteNotFound, "Site configuration not found")
+			return
+		}
+		if !srv.checkFeedAuth(w, r, site) {
+			return
+		}
+	}
+	limit := defaultTriggerLimit
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Invalid 'limit' parameter")
+			return
+		}
+		limit = min(n, maxTriggerLimit)
+	}
+
+	items, err := srv.recentTriggerItems(srv.triggerSites(r, site, params.Get("tag")), limit)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeStorage, "Failed to query items: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// REST hooks 订阅：新条目 POST 到 TargetURL，目标返回 410 时自动取消订阅
+type restHook struct {
+	ID        string    `json:"id"`
+	TargetURL string    `json:"target_url"`
+	Site      string    `json:"site,omitempty"`
+	Tag       string    `json:"tag,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 没有指定 Site 的订阅不包括租户的网站
+func (h restHook) match(site string, config SiteConfig) bool {
+	if h.Site == "" && isTenantSite(site) {
+		return false
+	}
+	return (h.Site == "" || h.Site == site) && (h.Tag == "" || slices.Contains(config.Tags, h.Tag))
+}
+
+// 读取订阅文件，文件不存在时没有订阅
+func (srv *Server) loadRestHooks(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var hooks []restHook
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		return err
+	}
+	srv.restHooksMu.Lock()
+	defer srv.restHooksMu.Unlock()
+	for _, h := range hooks {
+		srv.restHooks[h.ID] = h
+	}
+	return nil
+}
+
+// 写入订阅文件，调用方持有 restHooksMu
+func (srv *Server) saveRestHooks(path string) error {
+	data, err := json.MarshalIndent(srv.sortedRestHooks(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (srv *Server) sortedRestHooks() []restHook {
+	hooks := make([]restHook, 0, len(srv.restHooks))
+	for _, h := range srv.restHooks {
+		hooks = append(hooks, h)
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].CreatedAt.Before(hooks[j].CreatedAt) })
+	return hooks
+}
+
+func (srv *Server) deleteRestHook(id string) (bool, error) {
+	srv.restHooksMu.Lock()
+	defer srv.restHooksMu.Unlock()
+	if _, ok := srv.restHooks[id]; !ok {
+		return false, nil
+	}
+	delete(srv.restHooks, id)
+	return true, srv.saveRestHooks(srv.cfg.HooksFile)
+}
+
+// 新条目发送到匹配的 REST hooks，每个条目一个请求，请求体与轮询接口的条目相同
+func (srv *Server) restHookSink(site string, config SiteConfig, items []Item) []delivery {
+	srv.restHooksMu.RLock()
+	var hooks []restHook
+	for _, h := range srv.restHooks {
+		if h.match(site, config) {
+			hooks = append(hooks, h)
+		}
+	}
+	srv.restHooksMu.RUnlock()
+
+	now := time.Now()
+	var ds []delivery
+	for _, h := range hooks {
+		for _, item := range items {
+			body, err := json.Marshal(newTriggerItem(site, config, item, now))
+			if err != nil {
+				continue
+			}
+			h := h
+			ds = append(ds, delivery{
+				sink:   "resthook",
+				target: h.TargetURL,
+				site:   site,
+				send: func(ctx context.Context) error {
+					err := postBody(ctx, h.TargetURL, "application/json", body, nil)
+					var statusErr *httpStatusError
+					if errors.As(err, &statusErr) && statusErr.Status == http.StatusGone {
+						slog.Info("REST hook target is gone, unsubscribed", "id", h.ID, "target", h.TargetURL)
+						if _, err := srv.deleteRestHook(h.ID); err != nil {
+							slog.Error("Failed to save REST hooks", "file", srv.cfg.HooksFile, "error", err)
+						}
+						return nil
+					}
+					return err
+				},
+			})
+		}
+	}
+	return ds
+}
+
+// 管理 REST hooks 订阅的接口，需要管理令牌，访问范围同管理接口
+func isRestHooksPath(path string) bool {
+	return path == "/api/hooks" || strings.HasPrefix(path, "/api/hooks/")
+}
+
+// /api/hooks：GET 列出订阅；POST {"target_url","site","tag"} 订阅，返回 201 和订阅 ID；
+// DELETE /api/hooks/{id} 取消订阅。需要管理令牌
+func (srv *Server) restHooksHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		srv.restHooksMu.RLock()
+		hooks := srv.sortedRestHooks()
+		srv.restHooksMu.RUnlock()
+		writeJSON(w, http.StatusOK, hooks)
+	case http.MethodPost:
+		var req restHook
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Invalid JSON body: "+err.Error())
+			return
+		}
+		if !isHTTPURL(req.TargetURL) {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "'target_url' must be an http or https URL")
+			return
+		}
+		if req.Site != "" {
+			if _, ok := srv.getSiteConfig(req.Site); !ok {
+				writeError(w, r, http.StatusNotFound, ErrCodeSiteNotFound, "Site configuration not found")
+				return
+			}
+		}
+		hook := restHook{ID: randomID(), TargetURL: req.TargetURL, Site: req.Site, Tag: req.Tag, CreatedAt: time.Now().UTC()}
+		srv.restHooksMu.Lock()
+		srv.restHooks[hook.ID] = hook
+		err := srv.saveRestHooks(srv.cfg.HooksFile)
+		srv.restHooksMu.Unlock()
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeStorage, "Failed to save REST hooks: "+err.Error())
+			return
+		}
+		slog.Info("REST hook subscribed", "id", hook.ID, "target", hook.TargetURL, "site", hook.Site, "tag", hook.Tag)
+		writeJSON(w, http.StatusCreated, hook)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (srv *Server) restHookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.PathValue("id")
+	ok, err := srv.deleteRestHook(id)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeStorage, "Failed to save REST hooks: "+err.Error())
+		return
+	}
+	if !ok {
+		http.Error(w, "REST hook not found", http.StatusNotFound)
+		return
+	}
+	slog.Info("REST hook unsubscribed", "id", id)
+	writeJSON(w, http.StatusOK, map[string]string{"id": id})
+}
+
+// IFTTT 服务协议的错误响应
+func writeIFTTTError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{"errors": []map[string]string{{"message": message}}})
+}
+
+// 校验 IFTTT-Service-Key，不通过时返回 401
+func (srv *Server) iftttAuthorized(w http.ResponseWriter, r *http.Request) bool {
+	if srv.cfg.IFTTTServiceKey == "" {
+		http.NotFound(w, r)
+		return false
+	}
+	key := r.Header.Get("IFTTT-Service-Key")
+	if subtle.ConstantTimeCompare([]byte(key), []byte(srv.cfg.IFTTTServiceKey)) != 1 {
+		writeIFTTTError(w, http.StatusUnauthorized, "Invalid IFTTT-Service-Key")
+		return false
+	}
+	return true
+}
+
+// GET /ifttt/v1/status：IFTTT 检查服务是否可用
+func (srv *Server) iftttStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if srv.iftttAuthorized(w, r) {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// POST /ifttt/v1/test/setup：IFTTT 端点测试使用的触发器字段示例
+func (srv *Server) iftttTestSetupHandler(w http.ResponseWriter, r *http.Request) {
+	if !srv.iftttAuthorized(w, r) {
+		return
+	}
+	var site string
+	if sites := srv.triggerSites(r, "", ""); len(sites) > 0 {
+		site = sites[0]
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"samples": map[string]interface{}{
+				"triggers": map[string]interface{}{
+					"new_item": map[string]string{"site": site},
+				},
+			},
+		},
+	})
+}
+
+// IFTTT 触发器返回的条目，meta 中的 id 用于去重
+type iftttItem struct {
+	triggerItem
+	Meta struct {
+		ID        string `json:"id"`
+		Timestamp int64  `json:"timestamp"`
+	} `json:"meta"`
+}
+
+// POST /ifttt/v1/triggers/new_item：请求体为 {"triggerFields":{"site":"x"},"limit":50}，
+// site 为空时包括所有公开的网站
+func (srv *Server) iftttNewItemHandler(w http.ResponseWriter, r *http.Request) {
+	if !srv.iftttAuthorized(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeIFTTTError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	var req struct {
+		TriggerFields struct {
+			Site string `json:"site"`
+			Tag  string `json:"tag"`
+		} `json:"triggerFields"`
+		Limit *int `json:"limit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeIFTTTError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	limit := defaultTriggerLimit
+	if req.Limit != nil {
+		limit = min(max(*req.Limit, 0), maxTriggerLimit)
+	}
+	site := req.TriggerFields.Site
+	if site != "" {
+		if _, ok := srv.getSiteConfig(site); !ok || srv.feedProtected(site) {
+			writeIFTTTError(w, http.StatusBadRequest, "Unknown site "+strconv.Quote(site))
+			return
+		}
+	}
+
+	data := []iftttItem{}
+	if limit > 0 {
+		items, err := srv.recentTriggerItems(srv.triggerSites(r, site, req.TriggerFields.Tag), limit)
+		if err != nil {
+			writeIFTTTError(w, http.StatusInternalServerError, "Failed to query items")
+			return
+		}
+		for _, item := range items {
+			it := iftttItem{triggerItem: item}
+			it.Meta.ID = item.ID
+			it.Meta.Timestamp = item.Created.Unix()
+			data = append(data, it)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// 在 [d*(1-refreshJitter), d] 中随机取等待时间，只会提前，不会让缓存过期
+func (srv *Server) jitter(d time.Duration) time.Duration {
+	if srv.cfg.RefreshJitter <= 0 || d <= 0 {
+		return d
+	}
+	return d - time.Duration(rand.Float64()*srv.cfg.RefreshJitter*float64(d))
+}
+
+func (srv *Server) countRequest(site string) {
+	srv.requestCountsMu.Lock()
+	srv.requestCounts[site]++
+	srv.requestCountsMu.Unlock()
+}
+
+func (srv *Server) siteRequests(site string) int64 {
+	srv.requestCountsMu.Lock()
+	defer srv.requestCountsMu.Unlock()
+	return srv.requestCounts[site]
+}
+
+// 用存储中的请求次数初始化计数
+func (srv *Server) restoreRequests(site string, n int64) {
+	srv.requestCountsMu.Lock()
+	if n > srv.requestCounts[site] {
+		srv.requestCounts[site] = n
+	}
+	srv.requestCountsMu.Unlock()
+}
+
+// 按请求次数从多到少预热网站，最多同时抓取 warmupConcurrency 个，
+// 每个网站预热完成后调用 done
+func (srv *Server) warmup(sites []string, done func(site string)) {
+	sort.Slice(sites, func(i, j int) bool {
+		ri, rj := srv.siteRequests(sites[i]), srv.siteRequests(sites[j])
+		if ri != rj {
+			return ri > rj
+		}
+		return sites[i] < sites[j]
+	})
+
+	workers := srv.cfg.WarmupConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	queue := make(chan string)
+	srv.warmupQueued.Add(int64(len(sites)))
+	go func() {
+		for _, site := range sites {
+			queue <- site
+		}
+		close(queue)
+	}()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for site := range queue {
+				srv.warmupQueued.Add(-1)
+				config, _ := srv.getSiteConfig(site)
+				srv.loadSiteImage(site, config)
+				if srv.refreshesEnabled() {
+					srv.refreshCache(site)
+				}
+				done(site)
+			}
+		}()
+	}
+}
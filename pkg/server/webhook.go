@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"rss-zhuaqu/pkg/feed"
+)
+
+// 发送给 webhook 的新条目，每个条目一个请求
+type webhookPayload struct {
+	Event    string            `json:"event"`
+	ID       string            `json:"id"`
+	Site     string            `json:"site"`
+	SiteName string            `json:"site_name"`
+	SiteURL  string            `json:"site_url"`
+	FeedURL  string            `json:"feed_url,omitempty"`
+	Item     feed.JSONFeedItem `json:"item"`
+	Time     time.Time         `json:"time"`
+}
+
+// 网站新条目的 webhook 目标：全局的加上网站自己的，去掉重复
+func (srv *Server) siteWebhooks(site string, config SiteConfig) []string {
+	return mergeTargets(globalTargets(site, srv.cfg.WebhookURLs), config.Webhooks)
+}
+
+func (srv *Server) webhookSink(site string, config SiteConfig, items []Item) []delivery {
+	targets := srv.siteWebhooks(site, config)
+	if len(targets) == 0 {
+		return nil
+	}
+	var feedURL string
+	if srv.cfg.PublicURL != "" {
+		feedURL = srv.websubTopic(site, FormatRSS)
+	}
+
+	var ds []delivery
+	for _, item := range items {
+		payload := webhookPayload{
+			Event:    "new_item",
+			ID:       randomID(),
+			Site:     site,
+			SiteName: config.Name,
+			SiteURL:  config.URL,
+			FeedURL:  feedURL,
+			Item:     feed.NewJSONFeedItem(item),
+			Time:     time.Now().UTC(),
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			continue
+		}
+		header := http.Header{}
+		header.Set("X-RSS-Event", payload.Event)
+		header.Set("X-RSS-Delivery", payload.ID)
+		if srv.cfg.WebhookSecret != "" {
+			header.Set("X-RSS-Signature-256", "sha256="+srv.signWebhook(body))
+		}
+		for _, target := range targets {
+			target := target
+			ds = append(ds, delivery{
+				sink:   "webhook",
+				target: target,
+				site:   site,
+				send: func(ctx context.Context) error {
+					return postBody(ctx, target, "application/json", body, header)
+				},
+			})
+		}
+	}
+	return ds
+}
+
+// HMAC-SHA256(-webhook-secret, 请求体) 的十六进制
+func (srv *Server) signWebhook(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(srv.cfg.WebhookSecret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,393 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"rss-zhuaqu/pkg/feed"
+)
+
+// WebSocket（RFC 6455）的最小实现，只支持服务端、不支持扩展和子协议
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xa
+)
+
+// 客户端消息的最大长度
+const wsMaxMessage = 64 << 10
+
+// 向客户端发送 ping 的间隔
+var wsPingInterval = 30 * time.Second
+
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	// 回复 pong 和发送消息可能同时进行
+	mu sync.Mutex
+}
+
+// 请求头中逗号分隔的值是否包含 token（不区分大小写）
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 完成 WebSocket 握手并接管连接，失败时已写入错误响应。
+// 浏览器跨域连接需要 Origin 与服务同源或被 CORS 配置允许
+func (srv *Server) upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "Expected a WebSocket upgrade request", http.StatusBadRequest)
+		return nil, errors.New("not a websocket request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "Missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("missing websocket key")
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if (err != nil || !strings.EqualFold(u.Host, r.Host)) && srv.allowedOrigin(r.URL.Path, origin) == "" {
+			http.Error(w, "Origin not allowed", http.StatusForbidden)
+			return nil, errors.New("origin not allowed")
+		}
+	}
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "WebSocket is not supported", http.StatusInternalServerError)
+		return nil, err
+	}
+	// 连接被接管后不再受服务器读写超时限制
+	conn.SetDeadline(time.Time{})
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	brw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, br: brw.Reader}, nil
+}
+
+// 读取一条完整的消息，自动回复 ping，返回消息的 opcode 和内容。收到 close 时返回 wsOpClose
+func (c *wsConn) readMessage() (byte, []byte, error) {
+	var msgOp byte
+	var msg []byte
+	for {
+		var h [2]byte
+		if _, err := io.ReadFull(c.br, h[:]); err != nil {
+			return 0, nil, err
+		}
+		fin := h[0]&0x80 != 0
+		op := h[0] & 0x0f
+		n := uint64(h[1] & 0x7f)
+		switch n {
+		case 126:
+			var b [2]byte
+			if _, err := io.ReadFull(c.br, b[:]); err != nil {
+				return 0, nil, err
+			}
+			n = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			if _, err := io.ReadFull(c.br, b[:]); err != nil {
+				return 0, nil, err
+			}
+			n = binary.BigEndian.Uint64(b[:])
+		}
+		if h[1]&0x80 == 0 {
+			return 0, nil, errors.New("client frames must be masked")
+		}
+		if n > wsMaxMessage || uint64(len(msg))+n > wsMaxMessage {
+			return 0, nil, errors.New("message too large")
+		}
+
+		var mask [4]byte
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return 0, nil, err
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			return 0, nil, err
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch op {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			return wsOpClose, payload, nil
+		case wsOpContinuation:
+			if msgOp == 0 {
+				return 0, nil, errors.New("unexpected continuation frame")
+			}
+		case wsOpText, wsOpBinary:
+			if msgOp != 0 {
+				return 0, nil, errors.New("expected continuation frame")
+			}
+			msgOp = op
+		default:
+			return 0, nil, fmt.Errorf("unknown opcode %d", op)
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return msgOp, msg, nil
+		}
+	}
+}
+
+// 发送一个不分片的帧，服务端发送的帧不加掩码
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	header := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// 发送 close 帧后关闭连接
+func (c *wsConn) close(code uint16, reason string) {
+	payload := binary.BigEndian.AppendUint16(nil, code)
+	c.writeFrame(wsOpClose, append(payload, reason...))
+	c.conn.Close()
+}
+
+// 客户端发送的订阅请求
+type wsRequest struct {
+	// subscribe 或 unsubscribe
+	Action string   `json:"action"`
+	Sites  []string `json:"sites"`
+	Tags   []string `json:"tags"`
+	// 订阅时补发该事件 ID 之后的最近事件
+	LastID *int64 `json:"lastId"`
+}
+
+// 发送给客户端的消息：item、subscribed 或 error
+type wsMessage struct {
+	Type  string             `json:"type"`
+	ID    int64              `json:"id,omitempty"`
+	Site  string             `json:"site,omitempty"`
+	Item  *feed.JSONFeedItem `json:"item,omitempty"`
+	Sites []string           `json:"sites,omitempty"`
+	Error string             `json:"error,omitempty"`
+}
+
+func (c *wsConn) send(msg wsMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsOpText, data)
+}
+
+func (c *wsConn) sendItem(ev itemEvent) error {
+	item := feed.NewJSONFeedItem(ev.Item)
+	return c.send(wsMessage{Type: "item", ID: ev.ID, Site: ev.Site, Item: &item})
+}
+
+// 按请求中的网站和标签找出网站，检查网站存在且有访问权限。标签只展开为有权限的网站
+func (srv *Server) resolveWSSites(r *http.Request, req wsRequest) ([]string, error) {
+	if len(req.Sites) == 0 && len(req.Tags) == 0 {
+		return nil, errors.New("missing 'sites' or 'tags'")
+	}
+	names := slices.Clone(req.Sites)
+	for _, site := range names {
+		if _, ok := srv.getSiteConfig(site); !ok {
+			return nil, fmt.Errorf("unknown site: %s", site)
+		}
+		if !srv.feedAuthorized(r, site) {
+			return nil, fmt.Errorf("unauthorized: %s", site)
+		}
+	}
+	for _, tag := range req.Tags {
+		found := false
+		for site, config := range srv.getAllSiteConfig() {
+			if slices.Contains(config.Tags, tag) && srv.feedAuthorized(r, site) {
+				names = append(names, site)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no sites with tag: %s", tag)
+		}
+	}
+	return names, nil
+}
+
+// GET /ws：WebSocket 推送新条目。连接后发送
+// {"action":"subscribe","sites":["a"],"tags":["news"]} 订阅，unsubscribe 取消订阅，
+// 也可以在连接地址中用 site、sites 或 tag 参数直接订阅。
+// 需要认证的网站使用握手请求中的令牌或 Basic 认证
+func (srv *Server) websocketHandler(w http.ResponseWriter, r *http.Request) {
+	sites := make(map[string]bool)
+	if q := r.URL.Query(); q.Has("site") || q.Has("sites") || q.Has("tag") {
+		if sites = srv.eventSites(w, r); sites == nil {
+			return
+		}
+	}
+
+	c, err := srv.upgradeWebSocket(w, r)
+	if err != nil {
+		slog.Debug("WebSocket upgrade failed", "error", err)
+		return
+	}
+	defer c.conn.Close()
+
+	ch, _ := srv.subscribeItems(maps.Clone(sites), -1)
+	defer srv.unsubscribeItems(ch)
+
+	// 读取客户端消息，连接关闭或出错时结束
+	requests := make(chan wsRequest)
+	readDone := make(chan error, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			op, data, err := c.readMessage()
+			if err == nil && op == wsOpClose {
+				err = io.EOF
+			}
+			if err != nil {
+				readDone <- err
+				return
+			}
+			var req wsRequest
+			if op != wsOpText || json.Unmarshal(data, &req) != nil {
+				c.send(wsMessage{Type: "error", Error: "expected a JSON text message"})
+				continue
+			}
+			select {
+			case requests <- req:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				c.close(1008, "client too slow")
+				return
+			}
+			err = c.sendItem(ev)
+		case req := <-requests:
+			err = srv.handleWSRequest(c, r, ch, sites, req)
+		case <-ping.C:
+			err = c.writeFrame(wsOpPing, nil)
+		case err := <-readDone:
+			if err != io.EOF {
+				slog.Debug("WebSocket read failed", "error", err)
+			}
+			c.close(1000, "")
+			return
+		case <-srv.streamsClosing:
+			c.close(1001, "server shutting down")
+			return
+		}
+		if err != nil {
+			slog.Debug("WebSocket write failed", "error", err)
+			return
+		}
+	}
+}
+
+// 处理订阅或取消订阅，sites 为当前订阅的网站，原地修改
+func (srv *Server) handleWSRequest(c *wsConn, r *http.Request, ch chan itemEvent, sites map[string]bool, req wsRequest) error {
+	if req.Action != "subscribe" && req.Action != "unsubscribe" {
+		return c.send(wsMessage{Type: "error", Error: fmt.Sprintf("unknown action: %q", req.Action)})
+	}
+	names, err := srv.resolveWSSites(r, req)
+	if err != nil {
+		return c.send(wsMessage{Type: "error", Error: err.Error()})
+	}
+	for _, site := range names {
+		if req.Action == "subscribe" {
+			sites[site] = true
+		} else {
+			delete(sites, site)
+		}
+	}
+
+	// 订阅表中保存副本，sites 之后还会被修改
+	lastID := int64(-1)
+	if req.LastID != nil && req.Action == "subscribe" {
+		lastID = *req.LastID
+	}
+	missed := srv.resubscribeItems(ch, maps.Clone(sites), lastID)
+
+	subscribed := make([]string, 0, len(sites))
+	for site := range sites {
+		subscribed = append(subscribed, site)
+	}
+	sort.Strings(subscribed)
+	if err := c.send(wsMessage{Type: "subscribed", Sites: subscribed}); err != nil {
+		return err
+	}
+	for _, ev := range missed {
+		if err := c.sendItem(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 网站的订阅源是否通过 WebSub 推送。需要认证的订阅源 hub 无法获取，不推送
+func (srv *Server) websubEnabled(site string) bool {
+	return srv.cfg.WebSubHub != "" && !srv.feedProtected(site)
+}
+
+// 网站各格式订阅源的 topic，即订阅源中声明的 self 地址
+func (srv *Server) websubTopic(site, format string) string {
+	base := strings.TrimSuffix(srv.cfg.PublicURL, "/")
+	switch format {
+	case FormatAtom:
+		return base + "/rss/" + url.PathEscape(site) + ".atom"
+	case FormatJSON:
+		return base + "/feed/" + url.PathEscape(site) + ".json"
+	}
+	return base + "/rss?site=" + url.QueryEscape(site)
+}
+
+// 网站订阅源的内容变化后通知 hub，每种格式的 topic 各发送一次
+func (srv *Server) pingWebSubHub(site string) {
+	if !srv.websubEnabled(site) {
+		return
+	}
+	for _, format := range []string{FormatRSS, FormatAtom, FormatJSON} {
+		topic := srv.websubTopic(site, format)
+		if err := srv.publishToHub(topic); err != nil {
+			slog.Warn("Failed to notify WebSub hub", "site", site, "topic", topic, "error", err)
+			continue
+		}
+		slog.Debug("Notified WebSub hub", "site", site, "topic", topic)
+	}
+}
+
+// 向 hub 发送 hub.mode=publish 请求
+func (srv *Server) publishToHub(topic string) error {
+	ctx, cancel := context.WithTimeout(srv.appCtx, 30*time.Second)
+	defer cancel()
+
+	form := url.Values{"hub.mode": {"publish"}, "hub.url": {topic}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, srv.cfg.WebSubHub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("hub returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,421 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebSub 订阅：网站配置了 UpstreamFeed 且上游订阅源声明了 hub 时，作为 WebSub 订阅者订阅该订阅源，
+// 收到 hub 的推送后立即刷新网站，条目仍然按网站的选择器、流水线抓取和处理
+
+const (
+	// 检查订阅的间隔
+	upstreamCheckInterval = 5 * time.Minute
+	// 发出订阅请求后这么久还没有收到验证请求时重新订阅
+	upstreamVerifyTimeout = 10 * time.Minute
+	// 上游订阅源没有声明 hub 时，隔这么久再检查一次
+	upstreamRediscover = time.Hour
+	// 推送内容的最大长度
+	maxWebSubPayload = 4 << 20
+)
+
+// 网站对上游订阅源的订阅
+type upstreamSubscription struct {
+	// 网站配置中的 UpstreamFeed，以及从中发现的 hub 和 topic（订阅源的 self 地址）
+	Feed  string
+	Hub   string
+	Topic string
+	// 验证推送签名的密钥
+	Secret string
+
+	RequestedAt time.Time
+	// hub 验证订阅后的到期时间，为零时订阅还没有生效
+	Expires time.Time
+	Lease   time.Duration
+}
+
+// 网站配置变化时调用
+func (srv *Server) notifyUpstreamSubscriber() {
+	select {
+	case srv.upstreamSubscribeTrigger <- struct{}{}:
+	default:
+	}
+}
+
+// 启动时和之后每隔 upstreamCheckInterval 检查一次订阅，订阅快到期时续订
+func (srv *Server) startUpstreamSubscriber() {
+	go func() {
+		ticker := time.NewTicker(upstreamCheckInterval)
+		defer ticker.Stop()
+		warned := false
+		for {
+			if srv.cfg.PublicURL != "" {
+				srv.syncUpstreamSubscriptions()
+			} else if !warned && len(srv.upstreamFeeds()) > 0 {
+				slog.Warn("Sites have UpstreamFeed but -public-url is not set, WebSub subscriptions are disabled")
+				warned = true
+			}
+			select {
+			case <-ticker.C:
+			case <-srv.upstreamSubscribeTrigger:
+			case <-srv.appCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// 配置了 UpstreamFeed 的网站
+func (srv *Server) upstreamFeeds() map[string]string {
+	feeds := make(map[string]string)
+	for site, config := range srv.getAllSiteConfig() {
+		if config.UpstreamFeed != "" {
+			feeds[site] = config.UpstreamFeed
+		}
+	}
+	return feeds
+}
+
+func (srv *Server) websubCallbackURL(site string) string {
+	return strings.TrimSuffix(srv.cfg.PublicURL, "/") + "/websub/callback/" + url.PathEscape(site)
+}
+
+// 订阅新增的网站，取消删除的网站，续订快到期的订阅
+func (srv *Server) syncUpstreamSubscriptions() {
+	ctx, cancel := context.WithTimeout(srv.appCtx, 2*time.Minute)
+	defer cancel()
+	feeds := srv.upstreamFeeds()
+
+	srv.upstreamSubsMu.Lock()
+	var removed []*upstreamSubscription
+	var removedSites []string
+	for site, sub := range srv.upstreamSubs {
+		if feeds[site] != sub.Feed {
+			delete(srv.upstreamSubs, site)
+			if !sub.Expires.IsZero() {
+				removed = append(removed, sub)
+				removedSites = append(removedSites, site)
+			}
+		}
+	}
+	srv.upstreamSubsMu.Unlock()
+	for i, sub := range removed {
+		if err := srv.requestSubscription(ctx, removedSites[i], sub, "unsubscribe"); err != nil {
+			slog.Warn("Failed to unsubscribe from upstream hub", "site", removedSites[i], "hub", sub.Hub, "error", err)
+		}
+	}
+
+	sites := make([]string, 0, len(feeds))
+	for site := range feeds {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+	for _, site := range sites {
+		srv.upstreamSubsMu.Lock()
+		sub := srv.upstreamSubs[site]
+		var cur upstreamSubscription
+		if sub != nil {
+			cur = *sub
+		}
+		srv.upstreamSubsMu.Unlock()
+		if !subscriptionDue(sub, cur) {
+			continue
+		}
+
+		// 没有订阅或上次没有发现 hub 时重新读取上游订阅源
+		if sub == nil || cur.Hub == "" {
+			hub, topic, err := srv.discoverHub(ctx, feeds[site])
+			cur = upstreamSubscription{Feed: feeds[site], Hub: hub, Topic: topic, Secret: randomID() + randomID(), RequestedAt: time.Now()}
+			if err != nil || hub == "" {
+				if err == nil {
+					slog.Info("Upstream feed declares no WebSub hub, rechecking later", "site", site, "feed", feeds[site])
+				} else {
+					slog.Warn("Failed to discover WebSub hub", "site", site, "feed", feeds[site], "error", err)
+				}
+				cur.Hub = ""
+				srv.storeUpstreamSub(site, cur)
+				continue
+			}
+		}
+		cur.RequestedAt = time.Now()
+		cur.Lease = srv.cfg.WebSubLease
+		srv.storeUpstreamSub(site, cur)
+		if err := srv.requestSubscription(ctx, site, &cur, "subscribe"); err != nil {
+			slog.Warn("Failed to subscribe to upstream hub", "site", site, "hub", cur.Hub, "topic", cur.Topic, "error", err)
+			continue
+		}
+		slog.Info("Requested WebSub subscription", "site", site, "hub", cur.Hub, "topic", cur.Topic)
+	}
+}
+
+func (srv *Server) storeUpstreamSub(site string, sub upstreamSubscription) {
+	srv.upstreamSubsMu.Lock()
+	defer srv.upstreamSubsMu.Unlock()
+	// 验证可能已经先到达，保留其中的到期时间
+	if old := srv.upstreamSubs[site]; old != nil && old.Topic == sub.Topic && old.Secret == sub.Secret && old.Expires.After(sub.Expires) {
+		sub.Expires = old.Expires
+	}
+	srv.upstreamSubs[site] = &sub
+}
+
+// 是否需要（重新）订阅：还没有订阅、没有 hub 且超过重新检查的时间、
+// 订阅请求一直没有验证，或者剩余有效期不到四分之一
+func subscriptionDue(sub *upstreamSubscription, cur upstreamSubscription) bool {
+	switch {
+	case sub == nil:
+		return true
+	case cur.Hub == "":
+		return time.Since(cur.RequestedAt) > upstreamRediscover
+	case cur.Expires.IsZero():
+		return time.Since(cur.RequestedAt) > upstreamVerifyTimeout
+	}
+	return time.Until(cur.Expires) < cur.Lease/4
+}
+
+// 读取上游订阅源，从 Link 响应头、Atom/RSS 的 link 元素或 JSON Feed 的 hubs 中找到 hub 和 self 地址
+func (srv *Server) discoverHub(ctx context.Context, feedURL string) (hub, topic string, err error) {
+	resp, err := srv.pageScraper().Get(ctx, feedURL)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	topic = feedURL
+	for _, v := range resp.Header.Values("Link") {
+		for _, link := range strings.Split(v, ",") {
+			target, params, ok := strings.Cut(link, ";")
+			if !ok {
+				continue
+			}
+			target = strings.Trim(strings.TrimSpace(target), "<>")
+			for _, rel := range strings.Fields(linkRel(params)) {
+				switch rel {
+				case "hub":
+					hub = target
+				case "self":
+					topic = target
+				}
+			}
+		}
+	}
+	if hub != "" {
+		return hub, topic, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxWebSubPayload))
+	if err != nil {
+		return "", "", err
+	}
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+		var jf struct {
+			FeedURL string `json:"feed_url"`
+			Hubs    []struct {
+				Type string `json:"type"`
+				URL  string `json:"url"`
+			} `json:"hubs"`
+		}
+		if err := json.Unmarshal(trimmed, &jf); err != nil {
+			return "", "", err
+		}
+		for _, h := range jf.Hubs {
+			if strings.EqualFold(h.Type, "WebSub") {
+				hub = h.URL
+			}
+		}
+		if jf.FeedURL != "" {
+			topic = jf.FeedURL
+		}
+		return hub, topic, nil
+	}
+
+	// 只查看第一个条目之前的 link 元素（atom:link 或 Atom 的 link）
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if el.Name.Local == "item" || el.Name.Local == "entry" {
+			break
+		}
+		if el.Name.Local != "link" {
+			continue
+		}
+		var rel, href string
+		for _, a := range el.Attr {
+			switch a.Name.Local {
+			case "rel":
+				rel = a.Value
+			case "href":
+				href = a.Value
+			}
+		}
+		switch {
+		case href == "":
+		case rel == "hub":
+			hub = href
+		case rel == "self":
+			topic = href
+		}
+	}
+	return hub, topic, nil
+}
+
+// Link 头参数中的 rel
+func linkRel(params string) string {
+	for _, p := range strings.Split(params, ";") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(p), "rel="); ok {
+			return strings.Trim(v, `"`)
+		}
+	}
+	return ""
+}
+
+// 向 hub 发送订阅或取消订阅请求，hub 之后会异步请求回调地址验证
+func (srv *Server) requestSubscription(ctx context.Context, site string, sub *upstreamSubscription, mode string) error {
+	form := url.Values{
+		"hub.mode":     {mode},
+		"hub.topic":    {sub.Topic},
+		"hub.callback": {srv.websubCallbackURL(site)},
+	}
+	if mode == "subscribe" {
+		form.Set("hub.lease_seconds", strconv.Itoa(int(sub.Lease.Seconds())))
+		form.Set("hub.secret", sub.Secret)
+	}
+	return postBody(ctx, sub.Hub, "application/x-www-form-urlencoded", []byte(form.Encode()), nil)
+}
+
+// /websub/callback/{site}：GET 为 hub 的验证请求，POST 为内容推送
+func (srv *Server) websubCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	site := r.PathValue("site")
+	switch r.Method {
+	case http.MethodGet:
+		srv.verifyUpstreamSubscription(w, r, site)
+	case http.MethodPost:
+		srv.receiveUpstreamPing(w, r, site)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// 确认订阅时记录到期时间并原样返回 hub.challenge；不是本服务请求的订阅返回 404
+func (srv *Server) verifyUpstreamSubscription(w http.ResponseWriter, r *http.Request, site string) {
+	q := r.URL.Query()
+	mode, topic := q.Get("hub.mode"), q.Get("hub.topic")
+
+	srv.upstreamSubsMu.Lock()
+	sub := srv.upstreamSubs[site]
+	active := sub != nil && sub.Hub != "" && sub.Topic == topic
+	switch {
+	case mode == "subscribe" && active:
+		lease := sub.Lease
+		if secs, err := strconv.Atoi(q.Get("hub.lease_seconds")); err == nil && secs > 0 {
+			lease = time.Duration(secs) * time.Second
+		}
+		sub.Lease, sub.Expires = lease, time.Now().Add(lease)
+		srv.upstreamSubsMu.Unlock()
+		slog.Info("WebSub subscription verified", "site", site, "topic", topic, "lease", lease)
+	case mode == "unsubscribe" && !active:
+		// 网站已经删除或更换了上游订阅源
+		srv.upstreamSubsMu.Unlock()
+	case mode == "denied" && active:
+		sub.Expires = time.Time{}
+		srv.upstreamSubsMu.Unlock()
+		slog.Warn("WebSub subscription denied by hub", "site", site, "topic", topic, "reason", q.Get("hub.reason"))
+		w.WriteHeader(http.StatusOK)
+		return
+	default:
+		srv.upstreamSubsMu.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	io.WriteString(w, q.Get("hub.challenge"))
+}
+
+// 校验推送的签名，通过后立即在后台刷新网站。签名不正确的推送按规范忽略，但仍返回 2xx
+func (srv *Server) receiveUpstreamPing(w http.ResponseWriter, r *http.Request, site string) {
+	srv.upstreamSubsMu.Lock()
+	var secret string
+	if sub := srv.upstreamSubs[site]; sub != nil && !sub.Expires.IsZero() {
+		secret = sub.Secret
+	}
+	srv.upstreamSubsMu.Unlock()
+	if secret == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebSubPayload))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+	if err := verifyHubSignature(r.Header.Get("X-Hub-Signature"), secret, body); err != nil {
+		slog.Warn("Ignoring WebSub notification with invalid signature", "site", site, "error", err)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+
+	if !srv.refreshesEnabled() || srv.siteDisabled(site) || srv.maintenanceMode() {
+		return
+	}
+	slog.Info("WebSub notification received, refreshing site", "site", site)
+	go srv.refreshCache(site)
+}
+
+// X-Hub-Signature: <算法>=<HMAC 十六进制>
+func verifyHubSignature(header, secret string, body []byte) error {
+	algo, sig, ok := strings.Cut(header, "=")
+	if !ok {
+		return errors.New("missing X-Hub-Signature")
+	}
+	var h func() hash.Hash
+	switch algo {
+	case "sha1":
+		h = sha1.New
+	case "sha256":
+		h = sha256.New
+	case "sha384":
+		h = sha512.New384
+	case "sha512":
+		h = sha512.New
+	default:
+		return fmt.Errorf("unsupported signature algorithm %q", algo)
+	}
+	want, err := hex.DecodeString(sig)
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), want) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
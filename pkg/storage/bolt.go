@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"rss-zhuaqu/pkg/feed"
+)
+
+var (
+	boltItemsBucket = []byte("items")
+	boltCacheBucket = []byte("cache")
+)
+
+// 基于 bbolt 的存储，纯 Go 实现，适合无法使用 SQLite 的部署环境。
+// 条目按网站存放在 items 下的子 bucket 中，缓存存放在 cache 中
+type Bolt struct {
+	db *bolt.DB
+}
+
+// 打开（或创建）bolt 数据库文件
+func OpenBolt(path string) (*Bolt, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltItemsBucket, boltCacheBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Bolt{db: db}, nil
+}
+
+func (s *Bolt) Close() error {
+	return s.db.Close()
+}
+
+func (s *Bolt) SaveItems(site string, items []feed.Item, seen time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(boltItemsBucket).CreateBucketIfNotExists([]byte(site))
+		if err != nil {
+			return err
+		}
+
+		for i, item := range items {
+			var old *StoredItem
+			if v := b.Get([]byte(item.GUID)); v != nil {
+				var rec StoredItem
+				if json.Unmarshal(v, &rec) == nil {
+					old = &rec
+				}
+			}
+
+			data, err := json.Marshal(mergeStoredItem(old, item, i, seen))
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(item.GUID), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Bolt) PutItems(site string, recs []StoredItem) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(boltItemsBucket).CreateBucketIfNotExists([]byte(site))
+		if err != nil {
+			return err
+		}
+
+		for _, rec := range recs {
+			data, err := json.Marshal(rec)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(rec.Item.GUID), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Bolt) DeleteItems(site string, guids []string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltItemsBucket).Bucket([]byte(site))
+		if b == nil {
+			return nil
+		}
+		for _, guid := range guids {
+			if err := b.Delete([]byte(guid)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Bolt) records(site string) ([]StoredItem, error) {
+	var recs []StoredItem
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltItemsBucket).Bucket([]byte(site))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var rec StoredItem
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			recs = append(recs, rec)
+			return nil
+		})
+	})
+	return recs, err
+}
+
+func (s *Bolt) ItemHistory(site string, limit int) ([]feed.Item, error) {
+	recs, err := s.records(site)
+	if err != nil {
+		return nil, err
+	}
+	return sortStoredItems(recs, limit), nil
+}
+
+func (s *Bolt) LookupItems(site string, guids []string) ([]feed.Item, error) {
+	var items []feed.Item
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltItemsBucket).Bucket([]byte(site))
+		if b == nil {
+			return nil
+		}
+		for _, guid := range guids {
+			v := b.Get([]byte(guid))
+			if v == nil {
+				continue
+			}
+			var rec StoredItem
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			items = append(items, rec.Item)
+		}
+		return nil
+	})
+	return items, err
+}
+
+func (s *Bolt) QueryItems(site string, q ItemQuery) ([]StoredItem, error) {
+	recs, err := s.records(site)
+	if err != nil {
+		return nil, err
+	}
+	return queryStoredItems(recs, q), nil
+}
+
+func (s *Bolt) Get(site string) (FeedCache, bool, error) {
+	var fc FeedCache
+	var ok bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltCacheBucket).Get([]byte(site))
+		if v == nil {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(v, &fc)
+	})
+	return fc, ok, err
+}
+
+func (s *Bolt) Set(site string, fc FeedCache) error {
+	data, err := json.Marshal(fc)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltCacheBucket).Put([]byte(site), data)
+	})
+}
+
+func (s *Bolt) Delete(site string, items bool) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltCacheBucket).Delete([]byte(site)); err != nil {
+			return err
+		}
+		if !items {
+			return nil
+		}
+		err := tx.Bucket(boltItemsBucket).DeleteBucket([]byte(site))
+		if errors.Is(err, bolt.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+func (s *Bolt) ListSites() ([]string, error) {
+	var sites []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltCacheBucket).ForEach(func(k, v []byte) error {
+			sites = append(sites, string(k))
+			return nil
+		})
+	})
+	return sites, err
+}
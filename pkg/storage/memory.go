@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"rss-zhuaqu/pkg/feed"
+)
+
+// 进程内存储，重启后数据丢失。订阅源缓存超过 maxBytes 时，
+// 按最近被请求的时间淘汰最久未被请求的网站
+type Memory struct {
+	// 订阅源缓存占用的最大字节数（估算值），0 表示不限制
+	maxBytes int64
+
+	mu     sync.RWMutex
+	feeds  map[string]FeedCache
+	items  map[string]map[string]StoredItem
+	sizes  map[string]int64
+	access map[string]time.Time
+	bytes  int64
+}
+
+// 创建进程内存储，maxBytes 为订阅源缓存占用的最大字节数，0 表示不限制
+func NewMemory(maxBytes int64) *Memory {
+	return &Memory{
+		maxBytes: maxBytes,
+		feeds:    make(map[string]FeedCache),
+		items:    make(map[string]map[string]StoredItem),
+		sizes:    make(map[string]int64),
+		access:   make(map[string]time.Time),
+	}
+}
+
+func (s *Memory) Get(site string) (FeedCache, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fc, ok := s.feeds[site]
+	return fc, ok, nil
+}
+
+// 记录客户端请求网站订阅源的时间，缓存还不存在时同样记录（请求会触发抓取）。
+// 后台刷新、管理页面等内部读取不调用，不影响淘汰顺序
+func (s *Memory) Touch(site string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.access[site] = time.Now()
+}
+
+func (s *Memory) Set(site string, fc FeedCache) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size := FeedSize(fc)
+	s.bytes += size - s.sizes[site]
+	s.feeds[site] = fc
+	s.sizes[site] = size
+	s.evict()
+	return nil
+}
+
+func (s *Memory) Delete(site string, items bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bytes -= s.sizes[site]
+	delete(s.feeds, site)
+	delete(s.sizes, site)
+	delete(s.access, site)
+	if items {
+		delete(s.items, site)
+	}
+	return nil
+}
+
+// 淘汰最久未被请求的网站，直到占用的内存不超过上限。至少保留一个网站
+func (s *Memory) evict() {
+	for s.maxBytes > 0 && s.bytes > s.maxBytes && len(s.feeds) > 1 {
+		var oldest string
+		var oldestAt time.Time
+		for site := range s.feeds {
+			if at := s.access[site]; oldest == "" || at.Before(oldestAt) {
+				oldest, oldestAt = site, at
+			}
+		}
+
+		s.bytes -= s.sizes[oldest]
+		delete(s.feeds, oldest)
+		delete(s.sizes, oldest)
+		delete(s.access, oldest)
+		slog.Info("Evicted cache", "site", oldest, "cache_bytes", s.bytes)
+	}
+}
+
+// 已缓存订阅源占用的内存（估算值）
+func (s *Memory) Bytes() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.bytes
+}
+
+func (s *Memory) ListSites() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sites := make([]string, 0, len(s.feeds))
+	for site := range s.feeds {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+	return sites, nil
+}
+
+func (s *Memory) SaveItems(site string, items []feed.Item, seen time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := s.items[site]
+	if history == nil {
+		history = make(map[string]StoredItem)
+		s.items[site] = history
+	}
+	for i, item := range items {
+		var old *StoredItem
+		if rec, ok := history[item.GUID]; ok {
+			old = &rec
+		}
+		history[item.GUID] = mergeStoredItem(old, item, i, seen)
+	}
+	return nil
+}
+
+func (s *Memory) PutItems(site string, recs []StoredItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := s.items[site]
+	if history == nil {
+		history = make(map[string]StoredItem)
+		s.items[site] = history
+	}
+	for _, rec := range recs {
+		history[rec.Item.GUID] = rec
+	}
+	return nil
+}
+
+func (s *Memory) DeleteItems(site string, guids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, guid := range guids {
+		delete(s.items[site], guid)
+	}
+	return nil
+}
+
+func (s *Memory) records(site string) []StoredItem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	recs := make([]StoredItem, 0, len(s.items[site]))
+	for _, rec := range s.items[site] {
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func (s *Memory) ItemHistory(site string, limit int) ([]feed.Item, error) {
+	return sortStoredItems(s.records(site), limit), nil
+}
+
+func (s *Memory) LookupItems(site string, guids []string) ([]feed.Item, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var items []feed.Item
+	for _, guid := range guids {
+		if rec, ok := s.items[site][guid]; ok {
+			items = append(items, rec.Item)
+		}
+	}
+	return items, nil
+}
+
+func (s *Memory) QueryItems(site string, q ItemQuery) ([]StoredItem, error) {
+	return queryStoredItems(s.records(site), q), nil
+}
+
+func (s *Memory) Close() error {
+	return nil
+}
+
+// 文件存储：数据保存在内存中，定期以及关闭时写入 JSON 文件
+type File struct {
+	*Memory
+	path string
+}
+
+// 文件存储的 JSON 格式
+type fileData struct {
+	Feeds map[string]FeedCache
+	Items map[string]map[string]StoredItem
+}
+
+// 打开 JSON 文件存储，文件不存在时在 Flush 时创建。maxBytes 同 NewMemory
+func OpenFile(path string, maxBytes int64) (*File, error) {
+	s := &File{Memory: NewMemory(maxBytes), path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var saved fileData
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	for site, fc := range saved.Feeds {
+		s.Set(site, fc)
+	}
+	if saved.Items != nil {
+		s.items = saved.Items
+	}
+	return s, nil
+}
+
+// 写入文件。先写临时文件再重命名，避免写到一半时进程退出导致文件损坏
+func (s *File) Flush() error {
+	s.mu.RLock()
+	data, err := json.Marshal(fileData{Feeds: s.feeds, Items: s.items})
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *File) Close() error {
+	return s.Flush()
+}
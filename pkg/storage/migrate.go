@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 内置的数据库迁移，文件名格式同 golang-migrate：<版本>_<名称>.up.sql
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// 读取目录下的迁移，按版本排序
+func loadMigrations(dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, path.Join("migrations", dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []migration
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, ".up.sql") {
+			continue
+		}
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file name %s", name)
+		}
+		data, err := fs.ReadFile(migrationFiles, path.Join("migrations", dir, name))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{version: version, name: name, sql: string(data)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// 数据库当前的版本，没有迁移记录时为 0
+func schemaVersion(db *sql.DB) (int, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    BIGINT NOT NULL PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// 执行 dir 下尚未执行的迁移，返回执行的数量。每个迁移在一个事务中执行，
+// 语句以行末的分号分隔（MySQL 不支持一次执行多条语句）
+func runMigrations(db *sql.DB, dir string) (int, error) {
+	migrations, err := loadMigrations(dir)
+	if err != nil {
+		return 0, err
+	}
+	current, err := schemaVersion(db)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return applied, fmt.Errorf("migration %s: %w", m.name, err)
+		}
+		slog.Info("Applied migration", "dir", dir, "migration", m.name)
+		applied++
+	}
+	return applied, nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range strings.Split(m.sql, ";\n") {
+		if strings.TrimSpace(stmt) == "" {
+			continue
+		}
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(fmt.Sprintf(`INSERT INTO schema_migrations (version, applied_at) VALUES (%d, %d)`,
+		m.version, time.Now().Unix()))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// 打开数据库时调用：autoMigrate 为 true 时执行 migrations/<dir> 下的迁移，否则检查是否有未执行的迁移
+func PrepareSchema(db *sql.DB, dir string, autoMigrate bool) error {
+	if autoMigrate {
+		_, err := runMigrations(db, dir)
+		return err
+	}
+
+	migrations, err := loadMigrations(dir)
+	if err != nil {
+		return err
+	}
+	current, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if latest := migrations[len(migrations)-1].version; current < latest {
+		return fmt.Errorf("database schema is at version %d, %d required, run the migrate command", current, latest)
+	}
+	return nil
+}
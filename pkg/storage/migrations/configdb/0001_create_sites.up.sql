@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS sites (
+	name       VARCHAR(255) NOT NULL PRIMARY KEY,
+	config     TEXT         NOT NULL,
+	updated_at BIGINT       NOT NULL
+);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS items (
+	site        TEXT    NOT NULL,
+	guid        TEXT    NOT NULL,
+	title       TEXT    NOT NULL,
+	link        TEXT    NOT NULL,
+	description TEXT    NOT NULL,
+	pub_date    TEXT    NOT NULL,
+	thumbnail   TEXT    NOT NULL,
+	position    INTEGER NOT NULL,
+	first_seen  INTEGER NOT NULL,
+	last_seen   INTEGER NOT NULL,
+	PRIMARY KEY (site, guid)
+);
+CREATE INDEX IF NOT EXISTS items_site_first_seen ON items (site, first_seen DESC, position);
+CREATE TABLE IF NOT EXISTS feeds (
+	site TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rss-zhuaqu/pkg/feed"
+)
+
+// 基于 Redis 的存储，多个实例共享抓取结果和历史条目
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+// Redis 中缓存的保留时间，过期的订阅源在此期间仍可作为旧缓存返回
+const redisCacheRetention = 7 * 24 * time.Hour
+
+// 连接 Redis，addr 支持 host:port 或 redis:// URL，所有键以 prefix 开头
+func OpenRedis(addr, prefix string) (*Redis, error) {
+	opts, err := redis.ParseURL(addr)
+	if err != nil {
+		opts = &redis.Options{Addr: addr}
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &Redis{client: client, prefix: prefix}, nil
+}
+
+func (s *Redis) feedKey(site string) string {
+	return s.prefix + "feed:" + site
+}
+
+func (s *Redis) itemsKey(site string) string {
+	return s.prefix + "items:" + site
+}
+
+func (s *Redis) Get(site string) (FeedCache, bool, error) {
+	data, err := s.client.Get(context.Background(), s.feedKey(site)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return FeedCache{}, false, nil
+	}
+	if err != nil {
+		return FeedCache{}, false, err
+	}
+
+	var fc FeedCache
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return FeedCache{}, false, err
+	}
+	return fc, true, nil
+}
+
+func (s *Redis) Set(site string, fc FeedCache) error {
+	data, err := json.Marshal(fc)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(context.Background(), s.feedKey(site), data, redisCacheRetention).Err()
+}
+
+func (s *Redis) Delete(site string, items bool) error {
+	keys := []string{s.feedKey(site)}
+	if items {
+		keys = append(keys, s.itemsKey(site))
+	}
+	return s.client.Del(context.Background(), keys...).Err()
+}
+
+func (s *Redis) ListSites() ([]string, error) {
+	ctx := context.Background()
+	var sites []string
+
+	iter := s.client.Scan(ctx, 0, s.feedKey("*"), 100).Iterator()
+	for iter.Next(ctx) {
+		sites = append(sites, strings.TrimPrefix(iter.Val(), s.feedKey("")))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(sites)
+	return sites, nil
+}
+
+// 历史条目保存在每个网站一个的 hash 中，字段为 GUID
+func (s *Redis) SaveItems(site string, items []feed.Item, seen time.Time) error {
+	ctx := context.Background()
+	key := s.itemsKey(site)
+
+	var guids []string
+	for _, item := range items {
+		guids = append(guids, item.GUID)
+	}
+	if len(guids) == 0 {
+		return nil
+	}
+	existing, err := s.client.HMGet(ctx, key, guids...).Result()
+	if err != nil {
+		return err
+	}
+
+	values := make([]interface{}, 0, len(items)*2)
+	for i, item := range items {
+		var old *StoredItem
+		if v, ok := existing[i].(string); ok {
+			var rec StoredItem
+			if json.Unmarshal([]byte(v), &rec) == nil {
+				old = &rec
+			}
+		}
+
+		data, err := json.Marshal(mergeStoredItem(old, item, i, seen))
+		if err != nil {
+			return err
+		}
+		values = append(values, item.GUID, data)
+	}
+	return s.client.HSet(ctx, key, values...).Err()
+}
+
+func (s *Redis) PutItems(site string, recs []StoredItem) error {
+	if len(recs) == 0 {
+		return nil
+	}
+
+	values := make([]interface{}, 0, len(recs)*2)
+	for _, rec := range recs {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		values = append(values, rec.Item.GUID, data)
+	}
+	return s.client.HSet(context.Background(), s.itemsKey(site), values...).Err()
+}
+
+func (s *Redis) DeleteItems(site string, guids []string) error {
+	if len(guids) == 0 {
+		return nil
+	}
+	return s.client.HDel(context.Background(), s.itemsKey(site), guids...).Err()
+}
+
+func (s *Redis) records(site string) ([]StoredItem, error) {
+	all, err := s.client.HGetAll(context.Background(), s.itemsKey(site)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	recs := make([]StoredItem, 0, len(all))
+	for _, v := range all {
+		var rec StoredItem
+		if err := json.Unmarshal([]byte(v), &rec); err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+func (s *Redis) ItemHistory(site string, limit int) ([]feed.Item, error) {
+	recs, err := s.records(site)
+	if err != nil {
+		return nil, err
+	}
+	return sortStoredItems(recs, limit), nil
+}
+
+func (s *Redis) LookupItems(site string, guids []string) ([]feed.Item, error) {
+	if len(guids) == 0 {
+		return nil, nil
+	}
+	values, err := s.client.HMGet(context.Background(), s.itemsKey(site), guids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []feed.Item
+	for _, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var rec StoredItem
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			return nil, err
+		}
+		items = append(items, rec.Item)
+	}
+	return items, nil
+}
+
+func (s *Redis) QueryItems(site string, q ItemQuery) ([]StoredItem, error) {
+	recs, err := s.records(site)
+	if err != nil {
+		return nil, err
+	}
+	return queryStoredItems(recs, q), nil
+}
+
+func (s *Redis) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *Redis) Close() error {
+	return s.client.Close()
+}
+
+// 只有持有者（值相同）才能释放锁
+var redisUnlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+func (s *Redis) lockKey(site string) string {
+	return s.prefix + "lock:" + site
+}
+
+// 用 SET NX PX 实现的刷新锁，值为随机令牌
+func (s *Redis) TryLock(site string, ttl time.Duration) (func(), bool, error) {
+	ctx := context.Background()
+	key, token := s.lockKey(site), lockToken()
+
+	ok, err := s.client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	unlock := func() {
+		if err := redisUnlockScript.Run(ctx, s.client, []string{key}, token).Err(); err != nil {
+			slog.Error("Failed to release refresh lock", "site", site, "error", err)
+		}
+	}
+	return unlock, true, nil
+}
+
+// 随机的锁令牌
+func lockToken() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// 只有 leader 本身（值相同）才能续约
+var redisRenewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+func (s *Redis) leaderKey() string {
+	return s.prefix + "leader"
+}
+
+// 键不存在时成为 leader，已是 leader 时续约
+func (s *Redis) Campaign(id string, ttl time.Duration) (bool, error) {
+	ctx := context.Background()
+	ok, err := s.client.SetNX(ctx, s.leaderKey(), id, ttl).Result()
+	if err != nil || ok {
+		return ok, err
+	}
+	n, err := redisRenewScript.Run(ctx, s.client, []string{s.leaderKey()}, id, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (s *Redis) Resign(id string) error {
+	return redisUnlockScript.Run(context.Background(), s.client, []string{s.leaderKey()}, id).Err()
+}
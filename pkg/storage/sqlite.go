@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"rss-zhuaqu/pkg/feed"
+)
+
+// 基于 SQLite 的存储
+type SQLite struct {
+	db *sql.DB
+}
+
+// 打开（或创建）SQLite 数据库，autoMigrate 为 false 时有未执行的迁移则返回错误
+func OpenSQLite(path string, autoMigrate bool) (*SQLite, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite 只允许一个写连接，避免并发刷新时出现 database is locked
+	db.SetMaxOpenConns(1)
+
+	if err := PrepareSchema(db, "sqlite", autoMigrate); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLite{db: db}, nil
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLite) Ping() error {
+	return s.db.Ping()
+}
+
+func (s *SQLite) Get(site string) (FeedCache, bool, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM feeds WHERE site = ?`, site).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return FeedCache{}, false, nil
+	}
+	if err != nil {
+		return FeedCache{}, false, err
+	}
+
+	var fc FeedCache
+	if err := json.Unmarshal([]byte(data), &fc); err != nil {
+		return FeedCache{}, false, err
+	}
+	return fc, true, nil
+}
+
+func (s *SQLite) Set(site string, fc FeedCache) error {
+	data, err := json.Marshal(fc)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO feeds (site, data) VALUES (?, ?)
+		ON CONFLICT (site) DO UPDATE SET data = excluded.data`, site, string(data))
+	return err
+}
+
+func (s *SQLite) Delete(site string, items bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM feeds WHERE site = ?`, site); err != nil {
+		return err
+	}
+	if items {
+		if _, err := tx.Exec(`DELETE FROM items WHERE site = ?`, site); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) ListSites() ([]string, error) {
+	rows, err := s.db.Query(`SELECT site FROM feeds ORDER BY site`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sites []string
+	for rows.Next() {
+		var site string
+		if err := rows.Scan(&site); err != nil {
+			return nil, err
+		}
+		sites = append(sites, site)
+	}
+	return sites, rows.Err()
+}
+
+func (s *SQLite) SaveItems(site string, items []feed.Item, seen time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO items (site, guid, title, link, description, pub_date, thumbnail, position, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (site, guid) DO UPDATE SET
+			title = excluded.title,
+			link = excluded.link,
+			description = excluded.description,
+			pub_date = CASE WHEN items.pub_date = '' THEN excluded.pub_date ELSE items.pub_date END,
+			thumbnail = excluded.thumbnail,
+			last_seen = excluded.last_seen`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i, item := range items {
+		var thumbnail string
+		if item.Thumbnail != nil {
+			thumbnail = item.Thumbnail.URL
+		}
+		_, err := stmt.Exec(site, item.GUID, item.Title, item.Link, item.Description, item.PubDate,
+			thumbnail, i, seen.Unix(), seen.Unix())
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) PutItems(site string, recs []StoredItem) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO items (site, guid, title, link, description, pub_date, thumbnail, position, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, rec := range recs {
+		item := rec.Item
+		var thumbnail string
+		if item.Thumbnail != nil {
+			thumbnail = item.Thumbnail.URL
+		}
+		_, err := stmt.Exec(site, item.GUID, item.Title, item.Link, item.Description, item.PubDate,
+			thumbnail, rec.Position, rec.FirstSeen, rec.LastSeen)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) DeleteItems(site string, guids []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`DELETE FROM items WHERE site = ? AND guid = ?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, guid := range guids {
+		if _, err := stmt.Exec(site, guid); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) ItemHistory(site string, limit int) ([]feed.Item, error) {
+	rows, err := s.db.Query(`
+		SELECT guid, title, link, description, pub_date, thumbnail
+		FROM items WHERE site = ?
+		ORDER BY first_seen DESC, position
+		LIMIT ?`, site, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []feed.Item
+	for rows.Next() {
+		var item feed.Item
+		var thumbnail string
+		if err := rows.Scan(&item.GUID, &item.Title, &item.Link, &item.Description, &item.PubDate, &thumbnail); err != nil {
+			return nil, err
+		}
+		if thumbnail != "" {
+			item.Thumbnail = &feed.MediaThumbnail{URL: thumbnail}
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *SQLite) LookupItems(site string, guids []string) ([]feed.Item, error) {
+	stmt, err := s.db.Prepare(`
+		SELECT guid, title, link, description, pub_date, thumbnail
+		FROM items WHERE site = ? AND guid = ?`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var items []feed.Item
+	for _, guid := range guids {
+		var item feed.Item
+		var thumbnail string
+		err := stmt.QueryRow(site, guid).Scan(&item.GUID, &item.Title, &item.Link, &item.Description, &item.PubDate, &thumbnail)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if thumbnail != "" {
+			item.Thumbnail = &feed.MediaThumbnail{URL: thumbnail}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (s *SQLite) QueryItems(site string, q ItemQuery) ([]StoredItem, error) {
+	query := `SELECT guid, title, link, description, pub_date, thumbnail, position, first_seen, last_seen
+		FROM items WHERE site = ?`
+	args := []interface{}{site}
+	if !q.Since.IsZero() {
+		query += ` AND pub_date >= ?`
+		args = append(args, q.Since.Format(feed.PubDateLayout))
+	}
+	if !q.Until.IsZero() {
+		query += ` AND pub_date != '' AND pub_date < ?`
+		args = append(args, q.Until.Format(feed.PubDateLayout))
+	}
+	if q.Query != "" {
+		like := "%" + likeEscaper.Replace(q.Query) + "%"
+		query += ` AND (title LIKE ? ESCAPE '\' OR description LIKE ? ESCAPE '\')`
+		args = append(args, like, like)
+	}
+	query += ` ORDER BY first_seen DESC, position`
+	if q.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, q.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recs []StoredItem
+	for rows.Next() {
+		var rec StoredItem
+		var thumbnail string
+		err := rows.Scan(&rec.Item.GUID, &rec.Item.Title, &rec.Item.Link, &rec.Item.Description, &rec.Item.PubDate,
+			&thumbnail, &rec.Position, &rec.FirstSeen, &rec.LastSeen)
+		if err != nil {
+			return nil, err
+		}
+		if thumbnail != "" {
+			rec.Item.Thumbnail = &feed.MediaThumbnail{URL: thumbnail}
+		}
+		recs = append(recs, rec)
+	}
+	return recs, rows.Err()
+}
+
+// 转义 LIKE 中的通配符
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
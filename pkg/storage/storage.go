@@ -0,0 +1,260 @@
+// Package storage 保存订阅源缓存和每个网站抓取到过的全部条目，
+// 支持进程内存、JSON 文件、SQLite、bolt 和 Redis
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"rss-zhuaqu/pkg/feed"
+)
+
+// 存储接口，保存订阅源缓存和每个网站抓取到过的全部条目
+type Storage interface {
+	// 读取网站的订阅源缓存
+	Get(site string) (FeedCache, bool, error)
+	// 写入网站的订阅源缓存
+	Set(site string, fc FeedCache) error
+	// 删除网站的订阅源缓存，items 为 true 时同时删除历史条目
+	Delete(site string, items bool) error
+	// 列出有缓存的网站
+	ListSites() ([]string, error)
+	// 保存本次抓取到的条目：新条目记录首次出现时间，已有条目更新内容和最后出现时间，
+	// 但保留第一次记录的发布时间
+	SaveItems(site string, items []feed.Item, seen time.Time) error
+	// 原样写入历史条目（用于导入快照），覆盖同 GUID 的已有条目
+	PutItems(site string, recs []StoredItem) error
+	// 删除指定 GUID 的历史条目
+	DeleteItems(site string, guids []string) error
+	// 按首次出现时间从新到旧列出网站的历史条目，同一次抓取的条目保持页面上的顺序
+	ItemHistory(site string, limit int) ([]feed.Item, error)
+	// 按 GUID 读取网站的历史条目，顺序同 guids，没有记录的 GUID 跳过
+	LookupItems(site string, guids []string) ([]feed.Item, error)
+	// 按条件查询网站的历史条目，排序同 ItemHistory
+	QueryItems(site string, q ItemQuery) ([]StoredItem, error)
+	Close() error
+}
+
+// 历史条目查询条件
+type ItemQuery struct {
+	// 发布时间不早于 Since，为零值时不限制
+	Since time.Time
+	// 发布时间早于 Until，为零值时不限制；设置后不返回没有发布时间的条目
+	Until time.Time
+	// 标题或摘要中包含的关键词（不区分大小写），为空时不限制
+	Query string
+	Limit int
+}
+
+// 支持跨实例刷新锁的共享存储
+type Locker interface {
+	// 尝试获取网站的刷新锁，ttl 后自动释放，成功时返回释放函数
+	TryLock(site string, ttl time.Duration) (unlock func(), ok bool, err error)
+}
+
+// 支持 leader 选举的共享存储
+type LeaderElector interface {
+	// 竞选或续约 leader，返回本实例（id）是否为 leader
+	Campaign(id string, ttl time.Duration) (bool, error)
+	// 让出 leader
+	Resign(id string) error
+}
+
+// 需要定期写入磁盘的存储
+type Flusher interface {
+	Flush() error
+}
+
+// 按最近被请求的时间淘汰缓存的存储
+type Toucher interface {
+	// 记录网站的订阅源刚被客户端请求过
+	Touch(site string)
+}
+
+// 缓存结构
+type FeedCache struct {
+	Feed feed.RSSFeed
+	// 刷新时间
+	UpdatedAt time.Time
+	ExpireAt  time.Time
+	Skipped   SkipStats
+	// 网站累计被请求的次数，用于决定启动时的预热顺序
+	Requests int64
+	// 订阅源内容的哈希，内容不变时 lastBuildDate 和 ETag 保持不变
+	Hash string
+	// monitor 类型网站上次看到的内容
+	MonitorValue string `json:",omitempty"`
+	// 上次查找到的网站图标，重启后不必重新查找
+	SiteImage *SiteImage `json:",omitempty"`
+}
+
+// 网站图标的查找结果
+type SiteImage struct {
+	// 图标地址，为空表示网站没有图标
+	URL string
+	// 查找时的网站地址，网站地址变化后重新查找
+	Page      string
+	CheckedAt time.Time
+}
+
+// 条目被跳过的原因及次数
+type SkipStats map[string]int
+
+func (s SkipStats) Add(reason string) {
+	s[reason]++
+}
+
+// 被跳过的条目总数
+func (s SkipStats) Total() int {
+	var n int
+	for _, c := range s {
+		n += c
+	}
+	return n
+}
+
+func (s SkipStats) String() string {
+	var reasons []string
+	for r, c := range s {
+		reasons = append(reasons, fmt.Sprintf("%s=%d", r, c))
+	}
+	sort.Strings(reasons)
+	return strings.Join(reasons, ", ")
+}
+
+// 打开存储的选项
+type Option func(*options)
+
+type options struct {
+	redisPrefix string
+	memoryLimit int64
+	autoMigrate bool
+}
+
+// Redis 中键的前缀，默认为 rss-spider:
+func WithRedisPrefix(prefix string) Option {
+	return func(o *options) { o.redisPrefix = prefix }
+}
+
+// memory/file 存储中订阅源缓存占用的最大字节数（估算值），默认 0 表示不限制
+func WithMemoryLimit(bytes int64) Option {
+	return func(o *options) { o.memoryLimit = bytes }
+}
+
+// 打开 SQLite 数据库时是否自动执行迁移，默认开启；关闭后有未执行的迁移时返回错误
+func WithAutoMigrate(auto bool) Option {
+	return func(o *options) { o.autoMigrate = auto }
+}
+
+// 打开指定类型的存储：memory、file、sqlite、bolt 或 redis，path 为文件路径或 Redis 地址
+func Open(kind, path string, opts ...Option) (Storage, error) {
+	o := options{redisPrefix: "rss-spider:", autoMigrate: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if kind != "memory" && path == "" {
+		return nil, fmt.Errorf("storage %s requires -db", kind)
+	}
+
+	switch kind {
+	case "memory":
+		return NewMemory(o.memoryLimit), nil
+	case "file":
+		return OpenFile(path, o.memoryLimit)
+	case "sqlite":
+		return OpenSQLite(path, o.autoMigrate)
+	case "bolt":
+		return OpenBolt(path)
+	case "redis":
+		return OpenRedis(path, o.redisPrefix)
+	}
+	return nil, fmt.Errorf("unknown storage %q", kind)
+}
+
+// 键值型存储（内存、文件、bolt、Redis）中条目的存储格式
+type StoredItem struct {
+	Item      feed.Item
+	Position  int
+	FirstSeen int64
+	LastSeen  int64
+}
+
+// 合并本次抓取到的条目，已有条目保留首次出现时间、位置和发布时间，
+// 避免日期不稳定的网站每次刷新都产生新的发布时间
+func mergeStoredItem(old *StoredItem, item feed.Item, position int, seen time.Time) StoredItem {
+	rec := StoredItem{Item: item, Position: position, FirstSeen: seen.Unix(), LastSeen: seen.Unix()}
+	if old != nil {
+		rec.Position = old.Position
+		rec.FirstSeen = old.FirstSeen
+		if old.Item.PubDate != "" {
+			rec.Item.PubDate = old.Item.PubDate
+		}
+	}
+	return rec
+}
+
+// 按首次出现时间从新到旧排序，取前 limit 条
+func sortStoredItems(recs []StoredItem, limit int) []feed.Item {
+	recs = sortRecords(recs, limit)
+	items := make([]feed.Item, len(recs))
+	for i, rec := range recs {
+		items[i] = rec.Item
+	}
+	return items
+}
+
+func sortRecords(recs []StoredItem, limit int) []StoredItem {
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].FirstSeen != recs[j].FirstSeen {
+			return recs[i].FirstSeen > recs[j].FirstSeen
+		}
+		return recs[i].Position < recs[j].Position
+	})
+	if limit > 0 && len(recs) > limit {
+		recs = recs[:limit]
+	}
+	return recs
+}
+
+// 在内存中按条件筛选条目，用于键值型存储
+func queryStoredItems(recs []StoredItem, q ItemQuery) []StoredItem {
+	since, until := "", ""
+	if !q.Since.IsZero() {
+		since = q.Since.Format(feed.PubDateLayout)
+	}
+	if !q.Until.IsZero() {
+		until = q.Until.Format(feed.PubDateLayout)
+	}
+	query := strings.ToLower(q.Query)
+
+	matched := recs[:0:0]
+	for _, rec := range recs {
+		if since != "" && rec.Item.PubDate < since {
+			continue
+		}
+		if until != "" && (rec.Item.PubDate == "" || rec.Item.PubDate >= until) {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(rec.Item.Title), query) &&
+			!strings.Contains(strings.ToLower(rec.Item.Description), query) {
+			continue
+		}
+		matched = append(matched, rec)
+	}
+	return sortRecords(matched, q.Limit)
+}
+
+// 估算订阅源缓存占用的内存
+func FeedSize(fc FeedCache) int64 {
+	ch := fc.Feed.Channel
+	size := int64(256 + len(ch.Title) + len(ch.Link) + len(ch.Description))
+	for _, item := range ch.Items {
+		size += int64(64 + len(item.Title) + len(item.Link) + len(item.Description) + len(item.PubDate) + len(item.GUID))
+		if item.Thumbnail != nil {
+			size += int64(len(item.Thumbnail.URL))
+		}
+	}
+	return size
+}